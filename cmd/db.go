@@ -84,11 +84,17 @@ var (
 		},
 	}
 
-	useMigra    bool
-	usePgAdmin  bool
-	usePgSchema bool
-	schema      []string
-	file        string
+	useMigra     bool
+	usePgAdmin   bool
+	usePgSchema  bool
+	schema       []string
+	file         string
+	targetSchema string
+	snapshot     string
+	diffFormat   = utils.EnumFlag{
+		Allowed: []string{diff.FormatSql, utils.OutputJson},
+		Value:   diff.FormatSql,
+	}
 
 	dbDiffCmd = &cobra.Command{
 		Use:   "diff",
@@ -97,12 +103,21 @@ var (
 			if usePgAdmin {
 				return diff.RunPgAdmin(cmd.Context(), schema, file, flags.DbConfig, afero.NewOsFs())
 			}
-			differ := diff.DiffSchemaMigra
+			// Defaults to the engine in config.toml unless overridden by flags
+			var differ diff.DiffFunc
 			if usePgSchema {
 				differ = diff.DiffPgSchema
 				fmt.Fprintln(os.Stderr, "WARNING: --use-pg-schema flag is experimental and may not include all entities, such as RLS policies, enums, and grants.")
+			} else if cmd.Flags().Changed("use-migra") {
+				differ = diff.DiffSchemaMigra
+			}
+			if len(snapshot) > 0 {
+				return diff.RunSnapshot(cmd.Context(), schema, file, diffFormat.Value, snapshot, differ, afero.NewOsFs())
 			}
-			return diff.Run(cmd.Context(), schema, file, flags.DbConfig, differ, afero.NewOsFs())
+			if len(targetSchema) > 0 {
+				return diff.RunTargetSchema(cmd.Context(), schema, file, diffFormat.Value, targetSchema, differ, afero.NewOsFs())
+			}
+			return diff.Run(cmd.Context(), schema, file, diffFormat.Value, flags.DbConfig, differ, afero.NewOsFs())
 		},
 	}
 
@@ -110,6 +125,7 @@ var (
 	useCopy      bool
 	roleOnly     bool
 	keepComments bool
+	consistent   bool
 	excludeTable []string
 
 	dbDumpCmd = &cobra.Command{
@@ -121,7 +137,11 @@ var (
 			}
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return dump.Run(cmd.Context(), file, flags.DbConfig, schema, excludeTable, dataOnly, roleOnly, keepComments, useCopy, dryRun, afero.NewOsFs())
+			var opts []dump.DumpOption
+			if consistent {
+				opts = append(opts, dump.WithConsistentSnapshot)
+			}
+			return dump.Run(cmd.Context(), file, flags.DbConfig, schema, excludeTable, dataOnly, roleOnly, keepComments, useCopy, dryRun, afero.NewOsFs(), opts...)
 		},
 		PostRun: func(cmd *cobra.Command, args []string) {
 			if len(file) > 0 {
@@ -241,6 +261,15 @@ func init() {
 	dbDiffCmd.MarkFlagsMutuallyExclusive("db-url", "linked", "local")
 	diffFlags.StringVarP(&file, "file", "f", "", "Saves schema diff to a new migration file.")
 	diffFlags.StringSliceVarP(&schema, "schema", "s", []string{}, "Comma separated list of schema to include.")
+	diffFlags.Var(&diffFormat, "format", "Output format of the schema diff.")
+	diffFlags.StringVar(&targetSchema, "target-schema", "", "Diffs local migrations against the schema in the specified sql file.")
+	dbDiffCmd.MarkFlagsMutuallyExclusive("target-schema", "use-pgadmin")
+	dbDiffCmd.MarkFlagsMutuallyExclusive("target-schema", "db-url")
+	dbDiffCmd.MarkFlagsMutuallyExclusive("target-schema", "linked")
+	diffFlags.StringVar(&snapshot, "snapshot", "", "Diffs the schema in the specified sql file against local migrations.")
+	dbDiffCmd.MarkFlagsMutuallyExclusive("snapshot", "target-schema", "use-pgadmin", "db-url", "linked")
+	dbDiffCmd.MarkFlagsMutuallyExclusive("format", "file")
+	dbDiffCmd.MarkFlagsMutuallyExclusive("format", "use-pgadmin")
 	dbCmd.AddCommand(dbDiffCmd)
 	// Build dump command
 	dumpFlags := dbDumpCmd.Flags()
@@ -251,6 +280,7 @@ func init() {
 	dumpFlags.BoolVar(&roleOnly, "role-only", false, "Dumps only cluster roles.")
 	dbDumpCmd.MarkFlagsMutuallyExclusive("role-only", "data-only")
 	dumpFlags.BoolVar(&keepComments, "keep-comments", false, "Keeps commented lines from pg_dump output.")
+	dumpFlags.BoolVar(&consistent, "consistent", false, "Waits for a serializable snapshot so concurrent changes cannot produce an inconsistent dump.")
 	dbDumpCmd.MarkFlagsMutuallyExclusive("keep-comments", "data-only")
 	dumpFlags.StringVarP(&file, "file", "f", "", "File path to save the dumped contents.")
 	dumpFlags.String("db-url", "", "Dumps from the database specified by the connection string (must be percent-encoded).")
@@ -1,20 +1,28 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 
+	"github.com/go-errors/errors"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/supabase/cli/internal/db/diff"
 	"github.com/supabase/cli/internal/migration/list"
 	"github.com/supabase/cli/internal/migration/new"
+	"github.com/supabase/cli/internal/migration/prune"
+	"github.com/supabase/cli/internal/migration/rename"
+	"github.com/supabase/cli/internal/migration/reorder"
 	"github.com/supabase/cli/internal/migration/repair"
 	"github.com/supabase/cli/internal/migration/squash"
 	"github.com/supabase/cli/internal/migration/up"
+	"github.com/supabase/cli/internal/migration/verify"
 	"github.com/supabase/cli/internal/utils"
 	"github.com/supabase/cli/internal/utils/flags"
+	"github.com/supabase/cli/internal/utils/s3fs"
 )
 
 var (
@@ -30,11 +38,17 @@ var (
 		},
 	}
 
+	migrationsUrl string
+
 	migrationListCmd = &cobra.Command{
 		Use:   "list",
 		Short: "List local and remote migrations",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return list.Run(cmd.Context(), flags.DbConfig, afero.NewOsFs())
+			fsys, err := newMigrationFs(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return list.Run(cmd.Context(), flags.DbConfig, fsys)
 		},
 	}
 
@@ -65,16 +79,118 @@ var (
 		},
 	}
 
-	migrationVersion string
+	migrationFixOrderCmd = &cobra.Command{
+		Use:   "fix-order",
+		Short: "Renumber migrations that are out of order with git history",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return reorder.Run(cmd.Context(), flags.DbConfig, afero.NewOsFs())
+		},
+	}
+
+	migrationRenameCmd = &cobra.Command{
+		Use:   "rename <version> <name>",
+		Short: "Rename a migration file and its remote history entry",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rename.Run(cmd.Context(), args[0], args[1], flags.DbConfig, afero.NewOsFs())
+		},
+		PostRun: func(cmd *cobra.Command, args []string) {
+			fmt.Println("Finished " + utils.Aqua("supabase migration rename") + ".")
+		},
+	}
+
+	migrationPruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "Delete remote migration history without a local migration file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return prune.Run(cmd.Context(), flags.DbConfig, afero.NewOsFs())
+		},
+		PostRun: func(cmd *cobra.Command, args []string) {
+			fmt.Println("Finished " + utils.Aqua("supabase migration prune") + ".")
+		},
+	}
+
+	migrationVerifyBaselineCmd = &cobra.Command{
+		Use:   "verify-baseline",
+		Short: "Diff the baseline migration against the remote database schema",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return verify.Run(cmd.Context(), "", flags.DbConfig, afero.NewOsFs())
+		},
+	}
+
+	migrationVersion  string
+	squashListOnly    bool
+	squashEstimate    bool
+	squashUndo        bool
+	squashRestore     string
+	squashPostProcess []string
+	squashParams      squash.RunParams
+	squashFormat      = utils.EnumFlag{
+		Allowed: []string{
+			squash.FormatSql,
+			squash.FormatCustom,
+			squash.FormatMinified,
+			squash.FormatPretty,
+		},
+		Value: squash.FormatSql,
+	}
+
+	squashDiffFormat = utils.EnumFlag{
+		Allowed: []string{diff.FormatSql, utils.OutputJson},
+		Value:   diff.FormatSql,
+	}
+
+	squashData = utils.EnumFlag{
+		Allowed: []string{squash.DataSection, squash.DataSeed},
+	}
+
+	squashMetricsFormat = utils.EnumFlag{
+		Allowed: []string{squash.MetricsPrometheus, squash.MetricsJson},
+		Value:   squash.MetricsPrometheus,
+	}
 
 	migrationSquashCmd = &cobra.Command{
-		Use:   "squash",
+		Use:   "squash [version]",
 		Short: "Squash migrations to a single file",
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return squash.Run(cmd.Context(), migrationVersion, flags.DbConfig, afero.NewOsFs())
+			// A positional version of - reads the version from stdin
+			if len(args) > 0 {
+				if cmd.Flags().Changed("version") {
+					return errors.New("version must be specified either as an argument or with --version")
+				}
+				migrationVersion = args[0]
+			}
+			if squashUndo {
+				return squash.RunUndo(afero.NewOsFs())
+			}
+			if len(squashRestore) > 0 {
+				return squash.RunRestore(squashRestore, afero.NewOsFs())
+			}
+			fsys, err := newMigrationFs(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if squashListOnly {
+				return squash.RunList(migrationVersion, fsys)
+			}
+			if squashEstimate {
+				return squash.RunEstimate(migrationVersion, flags.DbConfig, fsys)
+			}
+			squashParams.Format = squashFormat.Value
+			squashParams.DiffFormat = squashDiffFormat.Value
+			squashParams.Data = squashData.Value
+			squashParams.MetricsFormat = squashMetricsFormat.Value
+			for _, command := range squashPostProcess {
+				squashParams.PostProcessors = append(squashParams.PostProcessors, squash.NewExecProcessor(cmd.Context(), command))
+			}
+			return squash.Run(cmd.Context(), migrationVersion, flags.DbConfig, squashParams, fsys)
 		},
 		PostRun: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Finished " + utils.Aqua("supabase migration squash") + ".")
+			// Keeps stdout clean for piping the squashed migration
+			if !squashListOnly && !squashEstimate && !squashUndo && len(squashRestore) == 0 && !squashParams.Stdout {
+				fmt.Println("Finished " + utils.Aqua("supabase migration squash") + ".")
+			}
 		},
 	}
 
@@ -82,7 +198,11 @@ var (
 		Use:   "up",
 		Short: "Apply pending migrations to local database",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return up.Run(cmd.Context(), includeAll, flags.DbConfig, afero.NewOsFs())
+			fsys, err := newMigrationFs(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return up.Run(cmd.Context(), includeAll, flags.DbConfig, fsys)
 		},
 		PostRun: func(cmd *cobra.Command, args []string) {
 			fmt.Println("Local database is up to date.")
@@ -90,9 +210,28 @@ var (
 	}
 )
 
+// Overlays migrations downloaded from object storage with the local project directory.
+func newMigrationFs(ctx context.Context) (afero.Fs, error) {
+	fsys := afero.NewOsFs()
+	if len(migrationsUrl) == 0 {
+		return fsys, nil
+	}
+	client, err := s3fs.NewClientFromEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := s3fs.Load(ctx, client, migrationsUrl, utils.MigrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	// Writes, such as the squashed migration, always go to the local directory
+	return afero.NewCopyOnWriteFs(remote, fsys), nil
+}
+
 func init() {
 	// Build list command
 	listFlags := migrationListCmd.Flags()
+	listFlags.StringVar(&migrationsUrl, "migrations-url", "", "Reads migrations from object storage, ie. s3://bucket/prefix.")
 	listFlags.String("db-url", "", "Lists migrations of the database specified by the connection string (must be percent-encoded).")
 	listFlags.Bool("linked", true, "Lists migrations applied to the linked project.")
 	listFlags.Bool("local", false, "Lists migrations applied to the local database.")
@@ -113,13 +252,141 @@ func init() {
 	cobra.CheckErr(viper.BindPFlag("DB_PASSWORD", repairFlags.Lookup("password")))
 	migrationRepairCmd.MarkFlagsMutuallyExclusive("db-url", "password")
 	migrationCmd.AddCommand(migrationRepairCmd)
+	// Build fix-order command
+	fixOrderFlags := migrationFixOrderCmd.Flags()
+	fixOrderFlags.String("db-url", "", "Checks remote history of the database specified by the connection string (must be percent-encoded).")
+	fixOrderFlags.Bool("linked", true, "Checks remote history of the linked project.")
+	fixOrderFlags.Bool("local", false, "Checks remote history of the local database.")
+	migrationFixOrderCmd.MarkFlagsMutuallyExclusive("db-url", "linked", "local")
+	fixOrderFlags.StringVarP(&dbPassword, "password", "p", "", "Password to your remote Postgres database.")
+	cobra.CheckErr(viper.BindPFlag("DB_PASSWORD", fixOrderFlags.Lookup("password")))
+	migrationFixOrderCmd.MarkFlagsMutuallyExclusive("db-url", "password")
+	migrationCmd.AddCommand(migrationFixOrderCmd)
+	// Build rename command
+	renameFlags := migrationRenameCmd.Flags()
+	renameFlags.String("db-url", "", "Renames migration history of the database specified by the connection string (must be percent-encoded).")
+	renameFlags.Bool("linked", true, "Renames migration history of the linked project.")
+	renameFlags.Bool("local", false, "Renames migration history of the local database.")
+	migrationRenameCmd.MarkFlagsMutuallyExclusive("db-url", "linked", "local")
+	renameFlags.StringVarP(&dbPassword, "password", "p", "", "Password to your remote Postgres database.")
+	cobra.CheckErr(viper.BindPFlag("DB_PASSWORD", renameFlags.Lookup("password")))
+	migrationRenameCmd.MarkFlagsMutuallyExclusive("db-url", "password")
+	migrationCmd.AddCommand(migrationRenameCmd)
+	// Build prune command
+	pruneFlags := migrationPruneCmd.Flags()
+	pruneFlags.String("db-url", "", "Prunes migration history of the database specified by the connection string (must be percent-encoded).")
+	pruneFlags.Bool("linked", true, "Prunes migration history of the linked project.")
+	pruneFlags.Bool("local", false, "Prunes migration history of the local database.")
+	migrationPruneCmd.MarkFlagsMutuallyExclusive("db-url", "linked", "local")
+	pruneFlags.StringVarP(&dbPassword, "password", "p", "", "Password to your remote Postgres database.")
+	cobra.CheckErr(viper.BindPFlag("DB_PASSWORD", pruneFlags.Lookup("password")))
+	migrationPruneCmd.MarkFlagsMutuallyExclusive("db-url", "password")
+	migrationCmd.AddCommand(migrationPruneCmd)
+	// Build verify-baseline command
+	verifyFlags := migrationVerifyBaselineCmd.Flags()
+	verifyFlags.String("db-url", "", "Verifies baseline against the database specified by the connection string (must be percent-encoded).")
+	verifyFlags.Bool("linked", true, "Verifies baseline against the linked project.")
+	verifyFlags.Bool("local", false, "Verifies baseline against the local database.")
+	migrationVerifyBaselineCmd.MarkFlagsMutuallyExclusive("db-url", "linked", "local")
+	verifyFlags.StringVarP(&dbPassword, "password", "p", "", "Password to your remote Postgres database.")
+	cobra.CheckErr(viper.BindPFlag("DB_PASSWORD", verifyFlags.Lookup("password")))
+	migrationVerifyBaselineCmd.MarkFlagsMutuallyExclusive("db-url", "password")
+	migrationCmd.AddCommand(migrationVerifyBaselineCmd)
 	// Build squash command
 	squashFlags := migrationSquashCmd.Flags()
-	squashFlags.StringVar(&migrationVersion, "version", "", "Squash up to the specified version.")
+	squashFlags.StringVar(&migrationVersion, "version", "", "Squash up to the specified version, or - to read it from stdin.")
+	squashFlags.BoolVar(&squashListOnly, "list", false, "Lists the migrations that would be squashed without running Docker.")
+	squashFlags.BoolVar(&squashUndo, "undo", false, "Restores migration files from the last git commit.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("list", "undo")
+	squashFlags.StringVar(&squashRestore, "restore", "", "Undoes the file changes of the last squash recorded in the specified audit log.")
+	squashFlags.BoolVar(&squashEstimate, "estimate", false, "Estimates the files and size to be squashed from local files without running Docker.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("estimate", "list", "undo", "restore")
+	squashFlags.BoolVar(&squashParams.Push, "push", false, "Pushes the squashed migration to the target database after baselining.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("estimate", "push")
+	squashFlags.UintVar(&squashParams.KeepRecent, "keep-recent", 0, "Keeps the specified number of most recent migrations unsquashed.")
+	squashFlags.BoolVar(&squashParams.Stdout, "stdout", false, "Writes the squashed migration to stdout without modifying migration files.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("stdout", "list")
+	squashFlags.BoolVar(&squashParams.Checksum, "checksum", false, "Writes a SHA-256 checksum of the squashed migration.")
+	squashFlags.StringVar(&squashParams.SignKey, "sign-key", "", "Signs the squashed migration with the specified GPG key.")
+	squashFlags.Var(&squashFormat, "format", "Output format of the squashed schema.")
+	squashFlags.BoolVar(&squashParams.QuoteAllIdentifiers, "quote-all-identifiers", false, "Quotes all identifiers in the custom format dump for portability across Postgres versions. Plain sql is always quoted.")
+	squashFlags.StringVar(&squashParams.OnComplete, "on-complete", "", "Posts a JSON summary of the squash to the specified webhook URL, or pipes it to the specified command.")
+	squashFlags.BoolVar(&squashParams.NormalizeDefaults, "normalize-defaults", false, "Rewrites equivalent column defaults, such as CURRENT_TIMESTAMP and now(), to a single form.")
+	squashFlags.BoolVar(&squashParams.WithDown, "with-down", false, "Writes a best effort down migration dropping the objects created by the squashed migration.")
+	squashFlags.StringVar(&squashParams.HistoryRole, "history-role", "", "Updates the migration history table as the specified role, which the database user must be a member of.")
+	squashFlags.BoolVar(&squashParams.PartialBaseline, "partial-baseline", false, "Replaces only the migration history of squashed migrations, keeping other rows before the squashed version.")
+	squashFlags.BoolVar(&squashParams.NoSecurityLabels, "no-security-labels", false, "Omits security labels, such as those set by anon or pgsodium, when they differ between environments.")
+	squashFlags.BoolVar(&squashParams.Textual, "textual", false, "Concatenates migration files without running Docker.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("no-security-labels", "textual")
+	squashFlags.BoolVar(&squashParams.FdwCredentials, "fdw-credentials", false, "Keeps user mapping credentials of foreign servers instead of redacting them.")
+	squashFlags.BoolVar(&squashParams.NoManagedDiff, "no-managed-diff", false, "Skips diffing changes to auth and storage schemas.")
+	squashFlags.Var(&squashDiffFormat, "diff-format", "Prints the managed schema diff to stdout in the specified format.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("no-managed-diff", "diff-format")
+	squashFlags.BoolVar(&squashParams.IgnoreWhitespace, "ignore-whitespace", false, "Ignores whitespace-only changes when diffing auth and storage schemas.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("no-managed-diff", "ignore-whitespace")
+	squashFlags.BoolVar(&squashParams.FromEmpty, "from-empty", false, "Dumps all schemas, including auth and storage, into a self-contained baseline instead of diffing managed schemas.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("from-empty", "diff-format")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("from-empty", "ignore-whitespace")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("from-empty", "textual")
+	squashFlags.StringVar(&squashParams.Base, "base", "", "Only squashes migrations added on top of the specified git branch.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("base", "push")
+	squashFlags.StringVar(&squashParams.Since, "since", "", "Only squashes migrations from the specified version, diffing the schema changes they made.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("since", "base")
+	squashFlags.Var(&squashData, "data", "Preserves data statements from squashed migrations in a DATA section or seed.sql.")
+	squashFlags.BoolVar(&squashParams.TextualFallback, "textual-fallback", false, "Concatenates migration files if Docker is unavailable, instead of failing.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("textual-fallback", "textual")
+	squashFlags.BoolVar(&squashParams.Full, "full", false, "Includes tablespaces and comments on roles for a full self-hosted restore.")
+	squashFlags.StringSliceVar(&squashParams.ExcludeSchemas, "exclude-schema", []string{}, "Comma separated list of schemas to exclude from the squashed dump.")
+	squashFlags.StringVar(&squashParams.Owner, "owner", "", "Only squashes objects owned by the specified role.")
+	squashFlags.BoolVar(&squashParams.Pretty, "pretty", false, "Adds section headers grouping the squashed schema by object type.")
+	squashFlags.StringVar(&squashParams.Release, "release", "", "Records the release identifier in the squashed migration and the baseline history row.")
+	squashFlags.BoolVar(&squashParams.Metadata, "metadata", false, "Starts the squashed migration with comments recording the CLI and Postgres versions, time and merged versions.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("metadata", "textual")
+	squashFlags.StringVar(&squashParams.Rename, "rename", "", "Renames the squashed migration while keeping its version.")
+	squashFlags.Lookup("rename").NoOptDefVal = "squashed_baseline"
+	squashFlags.UintSliceVar(&squashParams.VerifyVersions, "verify-versions", []uint{}, "Comma separated list of Postgres major versions to apply the squashed migration on, ie. 13,14,15.")
+	squashFlags.UintVar(&squashParams.VerifyParallel, "verify-parallel", 1, "Maximum number of shadow databases verifying Postgres versions at once.")
+	squashFlags.BoolVar(&squashParams.AssertObjects, "assert-objects", false, "Aborts if applying the squashed migration creates fewer objects than the merged migrations.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("assert-objects", "textual")
+	squashFlags.StringArrayVar(&squashPostProcess, "post-process", []string{}, "Pipes the squashed migration through the specified command before writing, in the order given.")
+	squashFlags.BoolVar(&squashParams.Validate, "validate", false, "Checks the squashed migration with the Postgres parser before writing.")
+	squashFlags.StringVar(&squashParams.GenTypes, "gen-types", "", "Writes TypeScript types generated from the squashed schema to the specified file.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("gen-types", "textual")
+	squashFlags.BoolVar(&squashParams.CheckTypes, "check-types", false, "Fails if types generated from the squashed schema differ from the --gen-types file, without writing it.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("check-types", "textual")
+	squashFlags.StringVar(&squashParams.MetricsPath, "metrics-file", "", "Writes phase durations, shadow memory and statements applied to the specified file.")
+	squashFlags.Var(&squashMetricsFormat, "metrics-format", "Format of the metrics file.")
+	squashFlags.DurationVar(&squashParams.Timeout, "timeout", 0, "Fails the squash if it does not finish within the specified duration, ie. 10m.")
+	squashFlags.StringVar(&squashParams.ShadowName, "shadow-name", "", "Names the shadow database container for inspecting its logs.")
+	squashFlags.BoolVar(&squashParams.Force, "force", false, "Writes the squashed migration even if it exceeds db.squash.max_file_size.")
+	squashFlags.BoolVar(&squashParams.AllowDirty, "allow-dirty", false, "Squashes even if the migrations directory has uncommitted changes.")
+	squashFlags.BoolVar(&squashParams.ContinueOnError, "continue-on-error", false, "Reports all failing statements instead of stopping at the first error.")
+	squashFlags.BoolVar(&squashParams.Profile, "profile", false, "Prints the time taken to apply each migration.")
+	squashFlags.BoolVar(&squashParams.Cache, "cache", false, "Restores unchanged earlier migrations from a cached dump, requires --no-managed-diff.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("cache", "textual")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("cache", "since")
+	squashFlags.BoolVar(&squashParams.Analyze, "analyze", false, "Runs ANALYZE on the shadow database before dumping the squashed schema.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("analyze", "textual")
+	squashFlags.BoolVar(&squashParams.Seed, "seed", false, "Applies seed.sql to the shadow database before dumping the squashed schema.")
+	squashFlags.StringVar(&squashParams.Role, "role", "postgres", "Applies migrations to the shadow database as the specified role.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("list", "push")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("stdout", "push")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("stdout", "rename")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("stdout", "checksum")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("stdout", "sign-key")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("stdout", "gen-types")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("stdout", "metrics-file")
+	squashFlags.StringVar(&migrationsUrl, "migrations-url", "", "Reads migrations from object storage, ie. s3://bucket/prefix.")
 	squashFlags.String("db-url", "", "Squashes migrations of the database specified by the connection string (must be percent-encoded).")
 	squashFlags.Bool("linked", false, "Squashes the migration history of the linked project.")
 	squashFlags.Bool("local", true, "Squashes the migration history of the local database.")
 	migrationSquashCmd.MarkFlagsMutuallyExclusive("db-url", "linked", "local")
+	squashFlags.StringVar(&squashParams.Branch, "branch", "", "Applies the squashed baseline to the specified preview branch of the linked project.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("branch", "db-url")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("branch", "linked")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("branch", "stdout")
+	squashFlags.BoolVar(&squashParams.Verify, "verify", false, "Diffs the squashed baseline against the schema of the baselined database.")
+	migrationSquashCmd.MarkFlagsMutuallyExclusive("verify", "stdout")
 	squashFlags.StringVarP(&dbPassword, "password", "p", "", "Password to your remote Postgres database.")
 	cobra.CheckErr(viper.BindPFlag("DB_PASSWORD", squashFlags.Lookup("password")))
 	migrationSquashCmd.MarkFlagsMutuallyExclusive("db-url", "password")
@@ -127,6 +394,7 @@ func init() {
 	// Build up command
 	upFlags := migrationUpCmd.Flags()
 	upFlags.BoolVar(&includeAll, "include-all", false, "Include all migrations not found on remote history table.")
+	upFlags.StringVar(&migrationsUrl, "migrations-url", "", "Reads migrations from object storage, ie. s3://bucket/prefix.")
 	upFlags.String("db-url", "", "Applies migrations to the database specified by the connection string (must be percent-encoded).")
 	upFlags.Bool("linked", false, "Applies pending migrations to the linked project.")
 	upFlags.Bool("local", true, "Applies pending migrations to the local database.")
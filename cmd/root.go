@@ -15,6 +15,7 @@ import (
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/supabase/cli/internal/migration/squash"
 	"github.com/supabase/cli/internal/services"
 	"github.com/supabase/cli/internal/utils"
 	"github.com/supabase/cli/internal/utils/flags"
@@ -91,6 +92,7 @@ var (
 				return errors.New("must set the --experimental flag to run this command")
 			}
 			cmd.SilenceUsage = true
+			utils.SetColorProfile(viper.GetBool("NO_COLOR"))
 			// Change workdir
 			fsys := afero.NewOsFs()
 			if err := utils.ChangeWorkDir(fsys); err != nil {
@@ -148,11 +150,18 @@ func recoverAndExit() {
 		return
 	}
 	var msg string
+	code := 1
 	switch err := err.(type) {
 	case string:
 		msg = err
 	case error:
 		msg = err.Error()
+		// Squash exits with distinct codes for scripts to branch on, while errors of child
+		// processes, such as *exec.ExitError, keep the default code
+		var exitErr *squash.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		}
 	default:
 		msg = fmt.Sprintf("%#v", err)
 	}
@@ -170,7 +179,7 @@ func recoverAndExit() {
 			fmt.Fprintln(os.Stderr, "Quote the crash ID above when filing a bug report: https://github.com/supabase/cli/issues/new/choose")
 		}
 	}
-	os.Exit(1)
+	os.Exit(code)
 }
 
 func init() {
@@ -196,7 +205,10 @@ func init() {
 	flags.Bool("experimental", false, "enable experimental features")
 	flags.Var(&utils.DNSResolver, "dns-resolver", "lookup domain names using the specified resolver")
 	flags.BoolVar(&createTicket, "create-ticket", false, "create a support ticket for any CLI error")
+	flags.Bool("no-color", false, "disable colored output")
+	flags.Bool("yes", false, "answer yes to destructive prompts")
 	cobra.CheckErr(viper.BindPFlags(flags))
+	cobra.CheckErr(viper.BindPFlag("NO_COLOR", flags.Lookup("no-color")))
 
 	rootCmd.SetVersionTemplate("{{.Version}}\n")
 	rootCmd.AddGroup(&cobra.Group{ID: groupQuickStart, Title: "Quick Start:"})
@@ -5,10 +5,13 @@ import (
 	"fmt"
 
 	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
 	"github.com/supabase/cli/internal/migration/list"
 	"github.com/supabase/cli/internal/utils"
 )
 
+var ErrBranchNotFound = errors.New("preview branch not found")
+
 func Run(ctx context.Context, branchId string) error {
 	resp, err := utils.GetSupabase().GetBranchDetailsWithResponse(ctx, branchId)
 	if err != nil {
@@ -43,3 +46,41 @@ func Run(ctx context.Context, branchId string) error {
 	)
 	return list.RenderTable(table)
 }
+
+// Resolves the database connection of a preview branch by its name or id.
+func GetDbConfig(ctx context.Context, ref, branch string) (pgconn.Config, error) {
+	var config pgconn.Config
+	resp, err := utils.GetSupabase().GetBranchesWithResponse(ctx, ref)
+	if err != nil {
+		return config, errors.Errorf("failed to list preview branches: %w", err)
+	}
+	if resp.JSON200 == nil {
+		return config, errors.New("Unexpected error listing preview branches: " + string(resp.Body))
+	}
+	var branchId string
+	for _, b := range *resp.JSON200 {
+		if b.Name == branch || b.Id == branch {
+			branchId = b.Id
+			break
+		}
+	}
+	if len(branchId) == 0 {
+		return config, errors.Errorf("%w: %s", ErrBranchNotFound, branch)
+	}
+	detail, err := utils.GetSupabase().GetBranchDetailsWithResponse(ctx, branchId)
+	if err != nil {
+		return config, errors.Errorf("failed to retrieve preview branch: %w", err)
+	}
+	if detail.JSON200 == nil {
+		return config, errors.New("Unexpected error retrieving preview branch: " + string(detail.Body))
+	}
+	if detail.JSON200.DbUser == nil || detail.JSON200.DbPass == nil {
+		return config, errors.Errorf("missing database credentials of preview branch %s: %s", branch, detail.JSON200.Status)
+	}
+	config.Host = detail.JSON200.DbHost
+	config.Port = uint16(detail.JSON200.DbPort)
+	config.User = *detail.JSON200.DbUser
+	config.Password = *detail.JSON200.DbPass
+	config.Database = "postgres"
+	return config, nil
+}
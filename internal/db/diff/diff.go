@@ -30,11 +30,14 @@ import (
 
 type DiffFunc func(context.Context, string, string, []string) (string, error)
 
-func Run(ctx context.Context, schema []string, file string, config pgconn.Config, differ DiffFunc, fsys afero.Fs, options ...func(*pgx.ConnConfig)) (err error) {
+func Run(ctx context.Context, schema []string, file, format string, config pgconn.Config, differ DiffFunc, fsys afero.Fs, options ...func(*pgx.ConnConfig)) (err error) {
 	// Sanity checks.
 	if err := utils.LoadConfigFS(fsys); err != nil {
 		return err
 	}
+	if differ == nil {
+		differ = GetDiffer(utils.Config.Db.Diff.Engine)
+	}
 	// 1. Load all user defined schemas
 	if len(schema) == 0 {
 		schema, err = loadSchema(ctx, config, options...)
@@ -49,7 +52,11 @@ func Run(ctx context.Context, schema []string, file string, config pgconn.Config
 	}
 	branch := keys.GetGitBranch(fsys)
 	fmt.Fprintln(os.Stderr, "Finished "+utils.Aqua("supabase db diff")+" on branch "+utils.Aqua(branch)+".\n")
-	if err := SaveDiff(out, file, fsys); err != nil {
+	if format == utils.OutputJson {
+		if err := WriteJson(out, os.Stdout); err != nil {
+			return err
+		}
+	} else if err := SaveDiff(out, file, fsys); err != nil {
 		return err
 	}
 	drops := findDropStatements(out)
@@ -60,6 +67,18 @@ func Run(ctx context.Context, schema []string, file string, config pgconn.Config
 	return nil
 }
 
+// Resolves the diff engine configured by db.diff.engine, defaulting to migra.
+func GetDiffer(engine utils.DiffEngine) DiffFunc {
+	switch engine {
+	case utils.DiffPgSchema:
+		fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "pg-schema diff engine is experimental and may not include all entities, such as RLS policies, enums, and grants.")
+		return DiffPgSchema
+	case utils.DiffDump:
+		return DiffSchemaDump
+	}
+	return DiffSchemaMigra
+}
+
 // https://github.com/djrobstep/migra/blob/master/migra/statements.py#L6
 var dropStatementPattern = regexp.MustCompile(`(?i)drop\s+`)
 
@@ -83,6 +102,7 @@ func loadSchema(ctx context.Context, config pgconn.Config, options ...func(*pgx.
 		return nil, err
 	}
 	defer conn.Close(context.Background())
+	WarnLocaleMismatch(ctx, conn, os.Stderr)
 	return LoadUserSchemas(ctx, conn)
 }
 
@@ -103,25 +123,111 @@ func LoadUserSchemas(ctx context.Context, conn *pgx.Conn) ([]string, error) {
 	return reset.ListSchemas(ctx, conn, exclude...)
 }
 
-func CreateShadowDatabase(ctx context.Context) (string, error) {
-	config := start.NewContainerConfig()
-	hostPort := strconv.FormatUint(uint64(utils.Config.Db.ShadowPort), 10)
+// Starts a shadow database container, passing any extra args to the postgres command.
+// The container is named by db.shadow.container_name when configured.
+func CreateShadowDatabase(ctx context.Context, args ...string) (string, error) {
+	return CreateShadowContainer(ctx, NewShadowOptions(), args...)
+}
+
+// Overrides the configured shadow database so that several can run side by side.
+type ShadowOptions struct {
+	Image        string
+	MajorVersion uint
+	Port         uint16
+	// Optional container name, removing any stale container of the same name
+	Name string
+}
+
+func NewShadowOptions() ShadowOptions {
+	return ShadowOptions{
+		Image:        utils.Config.Db.Image,
+		MajorVersion: utils.Config.Db.MajorVersion,
+		Port:         uint16(utils.Config.Db.ShadowPort),
+		Name:         utils.Config.Db.Shadow.ContainerName,
+	}
+}
+
+func CreateShadowContainer(ctx context.Context, opts ShadowOptions, args ...string) (string, error) {
+	if len(opts.Name) > 0 {
+		if err := checkShadowName(ctx, opts.Name); err != nil {
+			return "", err
+		}
+	}
+	config := start.NewContainerConfigFor(opts.Image, opts.MajorVersion)
+	if args := getInitdbArgs(); len(args) > 0 {
+		config.Env = append(config.Env, "POSTGRES_INITDB_ARGS="+strings.Join(args, " "))
+	}
+	if len(args) > 0 {
+		if len(config.Cmd) == 0 {
+			config.Cmd = []string{"postgres"}
+		}
+		config.Cmd = append(config.Cmd, args...)
+	}
+	hostPort := strconv.FormatUint(uint64(opts.Port), 10)
 	hostConfig := container.HostConfig{
 		PortBindings: nat.PortMap{"5432/tcp": []nat.PortBinding{{HostPort: hostPort}}},
 		AutoRemove:   true,
 	}
 	networkingConfig := network.NetworkingConfig{}
-	if utils.Config.Db.MajorVersion <= 14 {
+	if opts.MajorVersion <= 14 {
 		config.Entrypoint = nil
 		hostConfig.Tmpfs = map[string]string{"/docker-entrypoint-initdb.d": ""}
 	}
-	return utils.DockerStart(ctx, config, hostConfig, networkingConfig, "")
+	return utils.DockerStart(ctx, config, hostConfig, networkingConfig, opts.Name)
+}
+
+func getInitdbArgs() []string {
+	shadow := utils.Config.Db.Shadow
+	if len(shadow.Encoding) == 0 && len(shadow.LcCollate) == 0 && len(shadow.LcCtype) == 0 {
+		return nil
+	}
+	// Later env takes precedence so we must repeat the default locale
+	args := []string{"--lc-collate=C.UTF-8", "--lc-ctype=C.UTF-8"}
+	if len(shadow.LcCollate) > 0 {
+		args[0] = "--lc-collate=" + shadow.LcCollate
+	}
+	if len(shadow.LcCtype) > 0 {
+		args[1] = "--lc-ctype=" + shadow.LcCtype
+	}
+	if len(shadow.Encoding) > 0 {
+		args = append(args, "--encoding="+shadow.Encoding)
+	}
+	return args
+}
+
+const SELECT_DATABASE_LOCALE = "SELECT pg_encoding_to_char(encoding), datcollate, datctype FROM pg_database WHERE datname = current_database()"
+
+// Warns if the connected database uses a different locale from the configured shadow database.
+func WarnLocaleMismatch(ctx context.Context, conn *pgx.Conn, w io.Writer) {
+	shadow := utils.Config.Db.Shadow
+	if len(shadow.Encoding) == 0 && len(shadow.LcCollate) == 0 && len(shadow.LcCtype) == 0 {
+		return
+	}
+	var encoding, collate, ctype string
+	if err := conn.QueryRow(ctx, SELECT_DATABASE_LOCALE).Scan(&encoding, &collate, &ctype); err != nil {
+		logger := utils.GetDebugLogger()
+		fmt.Fprintln(logger, "failed to query database locale:", err)
+		return
+	}
+	check := func(name, expected, actual string) {
+		if len(expected) > 0 && !strings.EqualFold(expected, actual) {
+			fmt.Fprintf(w, "%s shadow database %s %s does not match %s on target database.\n", utils.Yellow("WARNING:"), name, utils.Aqua(expected), utils.Aqua(actual))
+		}
+	}
+	check("encoding", shadow.Encoding, encoding)
+	check("lc_collate", shadow.LcCollate, collate)
+	check("lc_ctype", shadow.LcCtype, ctype)
 }
 
 func ConnectShadowDatabase(ctx context.Context, timeout time.Duration, options ...func(*pgx.ConnConfig)) (conn *pgx.Conn, err error) {
+	return ConnectShadowPort(ctx, uint16(utils.Config.Db.ShadowPort), timeout, options...)
+}
+
+// Connects to a shadow database bound to the given host port instead of the configured one.
+func ConnectShadowPort(ctx context.Context, port uint16, timeout time.Duration, options ...func(*pgx.ConnConfig)) (conn *pgx.Conn, err error) {
 	// Retry until connected, cancelled, or timeout
 	policy := backoff.WithMaxRetries(backoff.NewConstantBackOff(time.Second), uint64(timeout.Seconds()))
-	config := pgconn.Config{Port: uint16(utils.Config.Db.ShadowPort)}
+	config := pgconn.Config{Port: port}
 	connect := func() (*pgx.Conn, error) {
 		return utils.ConnectLocalPostgres(ctx, config, options...)
 	}
@@ -138,7 +244,7 @@ func MigrateShadowDatabase(ctx context.Context, container string, fsys afero.Fs,
 		return err
 	}
 	defer conn.Close(context.Background())
-	if err := start.SetupDatabase(ctx, conn, container[:12], os.Stderr, fsys); err != nil {
+	if err := start.SetupShadowDatabase(ctx, conn, container[:12], os.Stderr, fsys); err != nil {
 		return err
 	}
 	return apply.MigrateUp(ctx, conn, migrations, fsys)
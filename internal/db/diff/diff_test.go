@@ -1,16 +1,19 @@
 package diff
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgerrcode"
 	"github.com/spf13/afero"
@@ -97,7 +100,7 @@ func TestRun(t *testing.T) {
 		conn := pgtest.NewConn()
 		defer conn.Close(t)
 		// Run test
-		err := Run(context.Background(), []string{"public"}, "file", dbConfig, DiffSchemaMigra, fsys, conn.Intercept)
+		err := Run(context.Background(), []string{"public"}, "file", "", dbConfig, DiffSchemaMigra, fsys, conn.Intercept)
 		// Check error
 		assert.NoError(t, err)
 		assert.Empty(t, apitest.ListUnmatchedRequests())
@@ -115,7 +118,7 @@ func TestRun(t *testing.T) {
 		// Setup in-memory fs
 		fsys := afero.NewMemMapFs()
 		// Run test
-		err := Run(context.Background(), []string{"public"}, "", pgconn.Config{}, DiffSchemaMigra, fsys)
+		err := Run(context.Background(), []string{"public"}, "", "", pgconn.Config{}, DiffSchemaMigra, fsys)
 		// Check error
 		assert.ErrorIs(t, err, os.ErrNotExist)
 	})
@@ -132,7 +135,7 @@ func TestRun(t *testing.T) {
 		conn.Query(reset.LIST_SCHEMAS, escapedSchemas).
 			ReplyError(pgerrcode.DuplicateTable, `relation "test" already exists`)
 		// Run test
-		err := Run(context.Background(), []string{}, "", dbConfig, DiffSchemaMigra, fsys, conn.Intercept)
+		err := Run(context.Background(), []string{}, "", "", dbConfig, DiffSchemaMigra, fsys, conn.Intercept)
 		// Check error
 		assert.ErrorContains(t, err, `ERROR: relation "test" already exists (SQLSTATE 42P07)`)
 	})
@@ -150,7 +153,67 @@ func TestRun(t *testing.T) {
 			Get("/v" + utils.Docker.ClientVersion() + "/images/" + utils.GetRegistryImageUrl(utils.Pg15Image) + "/json").
 			ReplyError(errors.New("network error"))
 		// Run test
-		err := Run(context.Background(), []string{"public"}, "file", dbConfig, DiffSchemaMigra, fsys)
+		err := Run(context.Background(), []string{"public"}, "file", "", dbConfig, DiffSchemaMigra, fsys)
+		// Check error
+		assert.ErrorContains(t, err, "network error")
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
+
+func TestRunTargetSchema(t *testing.T) {
+	t.Run("throws error on missing target schema", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		// Run test
+		err := RunTargetSchema(context.Background(), []string{"public"}, "", "", "schema.sql", DiffSchemaMigra, fsys)
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("throws error on failure to create shadow", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		require.NoError(t, afero.WriteFile(fsys, "schema.sql", []byte("create table test()"), 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/images/" + utils.GetRegistryImageUrl(utils.Pg15Image) + "/json").
+			ReplyError(errors.New("network error"))
+		// Run test
+		err := RunTargetSchema(context.Background(), []string{"public"}, "", "", "schema.sql", DiffSchemaMigra, fsys)
+		// Check error
+		assert.ErrorContains(t, err, "network error")
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
+
+func TestRunSnapshot(t *testing.T) {
+	t.Run("throws error on missing snapshot", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		// Run test
+		err := RunSnapshot(context.Background(), []string{"public"}, "", "", "schema.sql", DiffSchemaMigra, fsys)
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("throws error on failure to create shadow", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		require.NoError(t, afero.WriteFile(fsys, "schema.sql", []byte("create table test()"), 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/images/" + utils.GetRegistryImageUrl(utils.Pg15Image) + "/json").
+			ReplyError(errors.New("network error"))
+		// Run test
+		err := RunSnapshot(context.Background(), []string{"public"}, "", "", "schema.sql", DiffSchemaMigra, fsys)
 		// Check error
 		assert.ErrorContains(t, err, "network error")
 		assert.Empty(t, apitest.ListUnmatchedRequests())
@@ -377,3 +440,154 @@ func TestDropStatements(t *testing.T) {
 	drops := findDropStatements("create table t(); drop table t; alter table t drop column c")
 	assert.Equal(t, []string{"drop table t", "alter table t drop column c"}, drops)
 }
+
+func TestGetDiffer(t *testing.T) {
+	t.Run("defaults to migra", func(t *testing.T) {
+		differ := GetDiffer("")
+		assert.Equal(t, reflect.ValueOf(DiffSchemaMigra).Pointer(), reflect.ValueOf(differ).Pointer())
+	})
+
+	t.Run("selects pg-schema engine", func(t *testing.T) {
+		differ := GetDiffer(utils.DiffPgSchema)
+		assert.Equal(t, reflect.ValueOf(DiffPgSchema).Pointer(), reflect.ValueOf(differ).Pointer())
+	})
+
+	t.Run("selects dump engine", func(t *testing.T) {
+		differ := GetDiffer(utils.DiffDump)
+		assert.Equal(t, reflect.ValueOf(DiffSchemaDump).Pointer(), reflect.ValueOf(differ).Pointer())
+	})
+}
+
+func TestShadowLocale(t *testing.T) {
+	t.Run("skips initdb args by default", func(t *testing.T) {
+		assert.Empty(t, getInitdbArgs())
+	})
+
+	t.Run("overrides initdb locale", func(t *testing.T) {
+		utils.Config.Db.Shadow.Encoding = "LATIN1"
+		utils.Config.Db.Shadow.LcCollate = "C"
+		defer func() {
+			utils.Config.Db.Shadow.Encoding = ""
+			utils.Config.Db.Shadow.LcCollate = ""
+		}()
+		// Run test
+		args := getInitdbArgs()
+		// Check output
+		assert.Equal(t, []string{"--lc-collate=C", "--lc-ctype=C.UTF-8", "--encoding=LATIN1"}, args)
+	})
+
+	t.Run("warns on locale mismatch", func(t *testing.T) {
+		utils.Config.Db.Shadow.LcCollate = "C"
+		defer func() {
+			utils.Config.Db.Shadow.LcCollate = ""
+		}()
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(SELECT_DATABASE_LOCALE).
+			Reply("SELECT 1", []interface{}{"UTF8", "en_US.UTF-8", "en_US.UTF-8"})
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectByConfig(ctx, dbConfig, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		var out bytes.Buffer
+		WarnLocaleMismatch(ctx, mock, &out)
+		// Check output
+		assert.Contains(t, out.String(), "WARNING:")
+		assert.Contains(t, out.String(), "lc_collate")
+		assert.NotContains(t, out.String(), "lc_ctype")
+	})
+}
+
+func TestCheckShadowName(t *testing.T) {
+	utils.Config.ProjectId = "test"
+
+	t.Run("ignores missing container", func(t *testing.T) {
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow/json").
+			Reply(http.StatusNotFound)
+		// Run test
+		err := checkShadowName(context.Background(), "test-shadow")
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("throws error on running container", func(t *testing.T) {
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow/json").
+			Reply(http.StatusOK).
+			JSON(types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{
+					ID:    "test-id",
+					State: &types.ContainerState{Running: true},
+				},
+				Config: &container.Config{Labels: map[string]string{utils.CliProjectLabel: "test"}},
+			})
+		// Run test
+		err := checkShadowName(context.Background(), "test-shadow")
+		// Check error
+		assert.ErrorIs(t, err, ErrShadowRunning)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("throws error on name collision", func(t *testing.T) {
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow/json").
+			Reply(http.StatusOK).
+			JSON(types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: "test-id"},
+				Config:            &container.Config{Labels: map[string]string{utils.CliProjectLabel: "other"}},
+			})
+		// Run test
+		err := checkShadowName(context.Background(), "test-shadow")
+		// Check error
+		assert.ErrorIs(t, err, ErrShadowExists)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
+
+func TestEnsureDatabase(t *testing.T) {
+	t.Run("creates missing database", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(SELECT_DATABASE_NAME, "app").
+			Reply("SELECT 0").
+			Query(`CREATE DATABASE "app"`).
+			Reply("CREATE DATABASE")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		assert.NoError(t, EnsureDatabase(ctx, mock, "app"))
+	})
+
+	t.Run("skips existing database", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(SELECT_DATABASE_NAME, "postgres").
+			Reply("SELECT 1", []interface{}{"postgres"})
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		assert.NoError(t, EnsureDatabase(ctx, mock, "postgres"))
+	})
+}
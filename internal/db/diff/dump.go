@@ -0,0 +1,67 @@
+package diff
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
+	"github.com/supabase/cli/internal/db/dump"
+)
+
+// Dumps both databases with pg_dump and keeps the lines added to target, so the output uses the
+// statements of pg_dump. Objects dropped from target cannot be expressed as added lines.
+func DiffSchemaDump(ctx context.Context, source, target string, schema []string) (string, error) {
+	var before, after bytes.Buffer
+	if err := dumpUrl(ctx, source, schema, &before); err != nil {
+		return "", err
+	}
+	if err := dumpUrl(ctx, target, schema, &after); err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := DiffLines(&before, &after, &out); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func dumpUrl(ctx context.Context, url string, schema []string, w io.Writer) error {
+	config, err := pgconn.ParseConfig(url)
+	if err != nil {
+		return errors.Errorf("failed to parse connection string: %w", err)
+	}
+	return dump.DumpSchema(ctx, *config, schema, false, false, w)
+}
+
+// Writes the lines of after that are not matched in order by lines of before, assuming that
+// before is mostly a subset of after.
+func DiffLines(before, after io.Reader, w io.Writer) error {
+	anchor := bufio.NewReader(before)
+	expected, err := anchor.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return errors.Errorf("failed to read dump: %w", err)
+	}
+	r := bufio.NewReader(after)
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			if len(expected) > 0 && strings.TrimSuffix(line, "\n") == strings.TrimSuffix(expected, "\n") {
+				var readErr error
+				if expected, readErr = anchor.ReadString('\n'); readErr != nil && !errors.Is(readErr, io.EOF) {
+					return errors.Errorf("failed to read dump: %w", readErr)
+				}
+			} else if _, err := io.WriteString(w, strings.TrimSuffix(line, "\n")+"\n"); err != nil {
+				return errors.Errorf("failed to write line: %w", err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return errors.Errorf("failed to read dump: %w", err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffLines(t *testing.T) {
+	t.Run("writes added lines", func(t *testing.T) {
+		before := "CREATE SCHEMA a;\nCREATE TABLE a.t ();\n"
+		after := "CREATE SCHEMA a;\nCREATE SCHEMA b;\nCREATE TABLE a.t ();\nCREATE TABLE b.t ();"
+		var out strings.Builder
+		// Run test
+		err := DiffLines(strings.NewReader(before), strings.NewReader(after), &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "CREATE SCHEMA b;\nCREATE TABLE b.t ();\n", out.String())
+	})
+
+	t.Run("writes nothing on identical dumps", func(t *testing.T) {
+		dump := "CREATE SCHEMA a;\n"
+		var out strings.Builder
+		// Run test
+		err := DiffLines(strings.NewReader(dump), strings.NewReader(dump), &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("writes all lines on empty before", func(t *testing.T) {
+		var out strings.Builder
+		// Run test
+		err := DiffLines(strings.NewReader(""), strings.NewReader("a\nb\n"), &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "a\nb\n", out.String())
+	})
+}
@@ -0,0 +1,92 @@
+package diff
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/supabase/cli/internal/utils/parser"
+)
+
+const FormatSql = "sql"
+
+const (
+	ActionAdded   = "added"
+	ActionRemoved = "removed"
+	ActionChanged = "changed"
+)
+
+// Describes a single schema object touched by the diff.
+type ObjectDiff struct {
+	Action string `json:"action"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Sql    string `json:"sql"`
+}
+
+var (
+	ddlPattern = regexp.MustCompile(`(?is)^(CREATE|DROP|ALTER)\s+(OR\s+REPLACE\s+)?(?:UNIQUE\s+)?(?:CONSTRAINT\s+)?` +
+		`(MATERIALIZED\s+VIEW|FOREIGN\s+TABLE|EVENT\s+TRIGGER|[A-Z]+)\s+` +
+		`(?:CONCURRENTLY\s+)?(?:IF\s+(?:NOT\s+)?EXISTS\s+)?(?:ONLY\s+)?((?:"[^"]+"|[\w$]+)(?:\.(?:"[^"]+"|[\w$]+))*)`)
+	prefixPattern = regexp.MustCompile(`(?i)^(GRANT|REVOKE|COMMENT|SET)\b`)
+	spacePattern  = regexp.MustCompile(`\s+`)
+)
+
+// Parses the sql diff into a list of changed objects by type and name.
+func ParseObjectDiff(out string) ([]ObjectDiff, error) {
+	stats, err := parser.SplitAndTrim(strings.NewReader(out))
+	if err != nil {
+		return nil, err
+	}
+	result := []ObjectDiff{}
+	for _, sql := range stats {
+		if obj, ok := parseStatement(sql); ok {
+			result = append(result, obj)
+		}
+	}
+	return result, nil
+}
+
+func parseStatement(sql string) (ObjectDiff, bool) {
+	if matches := ddlPattern.FindStringSubmatch(sql); len(matches) > 4 {
+		obj := ObjectDiff{
+			Action: ActionChanged,
+			Type:   strings.ToLower(spacePattern.ReplaceAllString(matches[3], " ")),
+			Name:   strings.ReplaceAll(matches[4], `"`, ""),
+			Sql:    sql,
+		}
+		switch strings.ToUpper(matches[1]) {
+		case "CREATE":
+			// Diff tools emit OR REPLACE for new functions and views too, since a diff only
+			// creates objects missing from the source
+			obj.Action = ActionAdded
+		case "DROP":
+			obj.Action = ActionRemoved
+		}
+		return obj, true
+	}
+	if matches := prefixPattern.FindStringSubmatch(sql); len(matches) > 1 {
+		return ObjectDiff{
+			Action: ActionChanged,
+			Type:   strings.ToLower(matches[1]),
+			Sql:    sql,
+		}, true
+	}
+	return ObjectDiff{}, false
+}
+
+// Writes the sql diff as a JSON array of changed objects.
+func WriteJson(out string, w io.Writer) error {
+	objects, err := ParseObjectDiff(out)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(objects); err != nil {
+		return errors.Errorf("failed to encode json: %w", err)
+	}
+	return nil
+}
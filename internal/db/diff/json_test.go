@@ -0,0 +1,41 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseObjectDiff(t *testing.T) {
+	t.Run("parses objects by action", func(t *testing.T) {
+		out := `create table "public"."todos" (id bigint);
+drop view if exists "public"."old_view";
+alter table "public"."todos" add column "done" boolean;
+CREATE OR REPLACE FUNCTION public.now_utc() RETURNS timestamp LANGUAGE sql AS $$ select now() $$;
+create materialized view "public"."stats" as select 1;
+grant select on table "public"."todos" to "anon";
+`
+		// Run test
+		objects, err := ParseObjectDiff(out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []ObjectDiff{
+			{Action: ActionAdded, Type: "table", Name: "public.todos", Sql: `create table "public"."todos" (id bigint)`},
+			{Action: ActionRemoved, Type: "view", Name: "public.old_view", Sql: `drop view if exists "public"."old_view"`},
+			{Action: ActionChanged, Type: "table", Name: "public.todos", Sql: `alter table "public"."todos" add column "done" boolean`},
+			{Action: ActionAdded, Type: "function", Name: "public.now_utc", Sql: `CREATE OR REPLACE FUNCTION public.now_utc() RETURNS timestamp LANGUAGE sql AS $$ select now() $$`},
+			{Action: ActionAdded, Type: "materialized view", Name: "public.stats", Sql: `create materialized view "public"."stats" as select 1`},
+			{Action: ActionChanged, Type: "grant", Sql: `grant select on table "public"."todos" to "anon"`},
+		}, objects)
+	})
+
+	t.Run("writes empty array on no changes", func(t *testing.T) {
+		var out bytes.Buffer
+		// Run test
+		err := WriteJson("", &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "[]\n", out.String())
+	})
+}
@@ -0,0 +1,51 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var (
+	ErrShadowExists  = errors.New("shadow container name is already in use")
+	ErrShadowRunning = errors.New("shadow container is still running")
+)
+
+// Checks that no container is using the configured shadow name. Since shadow containers are
+// auto removed on exit, one that belongs to this project must still be used by another run.
+func checkShadowName(ctx context.Context, name string) error {
+	resp, err := utils.Docker.ContainerInspect(ctx, name)
+	if errdefs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Errorf("failed to inspect docker container: %w", err)
+	}
+	if resp.Config == nil || resp.Config.Labels[utils.CliProjectLabel] != utils.Config.ProjectId {
+		utils.CmdSuggestion = fmt.Sprintf("Configure a different %s in %s", utils.Aqua("db.shadow.container_name"), utils.Bold(utils.ConfigPath))
+		return errors.Errorf("%w: %s", ErrShadowExists, name)
+	}
+	utils.CmdSuggestion = fmt.Sprintf("Wait for the other run to finish or stop it with %s", utils.Aqua("docker rm -f "+name))
+	return errors.Errorf("%w: %s", ErrShadowRunning, name)
+}
+
+const SELECT_DATABASE_NAME = "SELECT datname FROM pg_database WHERE datname = $1"
+
+// A fresh shadow only has the default database, so a configured database is created before migrating.
+func EnsureDatabase(ctx context.Context, conn *pgx.Conn, name string) error {
+	var datname string
+	if err := conn.QueryRow(ctx, SELECT_DATABASE_NAME, name).Scan(&datname); err == nil {
+		return nil
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return errors.Errorf("failed to check database: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Creating database", utils.Aqua(name), "in shadow...")
+	if _, err := conn.Exec(ctx, "CREATE DATABASE "+pgx.Identifier{name}.Sanitize()); err != nil {
+		return errors.Errorf("failed to create database: %w", err)
+	}
+	return nil
+}
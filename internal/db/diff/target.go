@@ -0,0 +1,111 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/db/start"
+	"github.com/supabase/cli/internal/migration/apply"
+	"github.com/supabase/cli/internal/utils"
+)
+
+const targetDatabase = "target_schema"
+
+// Diffs local migrations against the desired schema in a sql file, instead of a live database.
+func RunTargetSchema(ctx context.Context, schema []string, file, format, targetPath string, differ DiffFunc, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	return runSchemaFile(ctx, schema, file, format, targetPath, false, differ, fsys, options...)
+}
+
+// Diffs a committed schema snapshot against local migrations, showing the changes made since the snapshot.
+func RunSnapshot(ctx context.Context, schema []string, file, format, snapshotPath string, differ DiffFunc, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	return runSchemaFile(ctx, schema, file, format, snapshotPath, true, differ, fsys, options...)
+}
+
+func runSchemaFile(ctx context.Context, schema []string, file, format, path string, reverse bool, differ DiffFunc, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	if err := utils.LoadConfigFS(fsys); err != nil {
+		return err
+	}
+	target, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return errors.Errorf("failed to read target schema: %w", err)
+	}
+	if differ == nil {
+		differ = GetDiffer(utils.Config.Db.Diff.Engine)
+	}
+	out, err := diffSchemaFile(ctx, schema, string(target), reverse, os.Stderr, fsys, differ, options...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Finished "+utils.Aqua("supabase db diff")+" against "+utils.Bold(path)+".\n")
+	if format == utils.OutputJson {
+		return WriteJson(out, os.Stdout)
+	}
+	return SaveDiff(out, file, fsys)
+}
+
+// Diffs from migrations to the target schema, or from the target schema to migrations if reverse is set.
+func diffSchemaFile(ctx context.Context, schema []string, target string, reverse bool, w io.Writer, fsys afero.Fs, differ DiffFunc, options ...func(*pgx.ConnConfig)) (string, error) {
+	fmt.Fprintln(w, "Creating shadow database...")
+	shadow, err := CreateShadowDatabase(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer utils.DockerRemove(shadow)
+	if !start.WaitForHealthyService(ctx, shadow, start.HealthTimeout) {
+		return "", errors.New(start.ErrDatabase)
+	}
+	if err := MigrateShadowDatabase(ctx, shadow, fsys, options...); err != nil {
+		return "", err
+	}
+	config := pgconn.Config{
+		Host:     utils.Config.Hostname,
+		Port:     uint16(utils.Config.Db.ShadowPort),
+		User:     "postgres",
+		Password: utils.Config.Db.Password,
+		Database: "postgres",
+	}
+	targetConfig := config
+	targetConfig.Database = targetDatabase
+	fmt.Fprintln(w, "Loading target schema...")
+	if schema, err = loadTargetSchema(ctx, schema, target, targetConfig, options...); err != nil {
+		return "", err
+	}
+	fmt.Fprintln(w, "Diffing schemas:", strings.Join(schema, ","))
+	source, dest := utils.ToPostgresURL(config), utils.ToPostgresURL(targetConfig)
+	if reverse {
+		source, dest = dest, source
+	}
+	return differ(ctx, source, dest, schema)
+}
+
+func loadTargetSchema(ctx context.Context, schema []string, target string, config pgconn.Config, options ...func(*pgx.ConnConfig)) ([]string, error) {
+	conn, err := ConnectShadowDatabase(ctx, 10*time.Second, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(context.Background())
+	sql := "CREATE DATABASE " + pgx.Identifier{config.Database}.Sanitize()
+	if _, err := conn.Exec(ctx, sql); err != nil {
+		return nil, errors.Errorf("failed to create target database: %w", err)
+	}
+	targetConn, err := utils.ConnectLocalPostgres(ctx, config, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer targetConn.Close(context.Background())
+	if err := apply.BatchExecDDL(ctx, targetConn, strings.NewReader(target)); err != nil {
+		return nil, err
+	}
+	if len(schema) > 0 {
+		return schema, nil
+	}
+	return LoadUserSchemas(ctx, targetConn)
+}
@@ -1,13 +1,16 @@
 package dump
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/go-errors/errors"
@@ -23,9 +26,44 @@ var (
 	dumpDataScript string
 	//go:embed templates/dump_role.sh
 	dumpRoleScript string
+	//go:embed templates/dump_custom.sh
+	dumpCustomScript string
+	//go:embed templates/dump_cluster.sh
+	dumpClusterScript string
 )
 
-func Run(ctx context.Context, path string, config pgconn.Config, schema, excludeTable []string, dataOnly, roleOnly, keepComments, useCopy, dryRun bool, fsys afero.Fs) error {
+// Appends extra flags to the pg_dump command.
+type DumpOption func(flags []string) []string
+
+// Waits for a serializable snapshot before dumping, so that concurrent changes on a live database
+// cannot produce an inconsistent dump.
+func WithConsistentSnapshot(flags []string) []string {
+	return append(flags, "--serializable-deferrable")
+}
+
+// Quotes every identifier, including those that are not keywords in the dumping server but may be
+// reserved in the restoring one. Plain sql dumps always quote identifiers so this only affects custom format.
+func WithQuoteAllIdentifiers(flags []string) []string {
+	return append(flags, "--quote-all-identifiers")
+}
+
+// Omits security labels, such as those set by the anon and pgsodium extensions, which usually
+// hold environment specific values like key ids.
+func WithNoSecurityLabels(flags []string) []string {
+	return append(flags, "--no-security-labels")
+}
+
+// Excludes the given schemas, in addition to internal schemas, from the dump.
+func WithExcludeSchemas(schema []string) DumpOption {
+	return func(flags []string) []string {
+		if len(schema) == 0 {
+			return flags
+		}
+		return append(flags, "--exclude-schema="+strings.Join(schema, "|"))
+	}
+}
+
+func Run(ctx context.Context, path string, config pgconn.Config, schema, excludeTable []string, dataOnly, roleOnly, keepComments, useCopy, dryRun bool, fsys afero.Fs, opts ...DumpOption) error {
 	// Initialize output stream
 	var outStream afero.File
 	if len(path) > 0 {
@@ -48,30 +86,103 @@ func Run(ctx context.Context, path string, config pgconn.Config, schema, exclude
 	}
 	if dataOnly {
 		fmt.Fprintf(os.Stderr, "Dumping data from %s database...\n", db)
-		return dumpData(ctx, config, schema, excludeTable, useCopy, dryRun, outStream)
+		return dumpData(ctx, config, schema, excludeTable, useCopy, dryRun, outStream, opts...)
 	} else if roleOnly {
 		fmt.Fprintf(os.Stderr, "Dumping roles from %s database...\n", db)
 		return dumpRole(ctx, config, keepComments, dryRun, outStream)
 	}
 	fmt.Fprintf(os.Stderr, "Dumping schemas from %s database...\n", db)
-	return DumpSchema(ctx, config, schema, keepComments, dryRun, outStream)
+	if dryRun || db == "local" {
+		return DumpSchema(ctx, config, schema, keepComments, dryRun, outStream, opts...)
+	}
+	// Buffered so that a retry after a dropped connection does not write a partial dump
+	var buf bytes.Buffer
+	if err := DumpSchemaWithRetry(ctx, config, schema, keepComments, &buf, opts...); err != nil {
+		return err
+	}
+	if _, err := buf.WriteTo(outStream); err != nil {
+		return errors.Errorf("failed to write dump: %w", err)
+	}
+	return nil
+}
+
+func DumpSchema(ctx context.Context, config pgconn.Config, schema []string, keepComments, dryRun bool, stdout io.Writer, opts ...DumpOption) error {
+	return dumpSchema(ctx, config, schema, utils.InternalSchemas, keepComments, dryRun, stdout, opts...)
+}
+
+// Dumps like DumpSchema but only excludes system schemas, so that schemas managed by platform,
+// ie. auth and storage, are included in full.
+func DumpAllSchemas(ctx context.Context, config pgconn.Config, schema []string, keepComments, dryRun bool, stdout io.Writer, opts ...DumpOption) error {
+	return dumpSchema(ctx, config, schema, utils.SystemSchemas, keepComments, dryRun, stdout, opts...)
 }
 
-func DumpSchema(ctx context.Context, config pgconn.Config, schema []string, keepComments, dryRun bool, stdout io.Writer) error {
+func dumpSchema(ctx context.Context, config pgconn.Config, schema, excluded []string, keepComments, dryRun bool, stdout io.Writer, opts ...DumpOption) error {
+	env := schemaEnv(schema, excluded, keepComments, opts...)
+	return dump(ctx, config, dumpSchemaScript, env, dryRun, stdout)
+}
+
+// A writer that can discard the output of a failed dump attempt, such as bytes.Buffer.
+type ResetWriter interface {
+	io.Writer
+	Reset()
+}
+
+// Dumps like DumpSchema but retries from scratch on transient network errors, for remote databases.
+// Output is streamed to stdout, which is reset before each retry because pg_dump is not resumable.
+func DumpSchemaWithRetry(ctx context.Context, config pgconn.Config, schema []string, keepComments bool, stdout ResetWriter, opts ...DumpOption) error {
+	env := dumpEnv(config, schemaEnv(schema, utils.InternalSchemas, keepComments, opts...))
+	run := func(stdout, stderr io.Writer) error {
+		return runDump(ctx, dumpSchemaScript, env, stdout, stderr)
+	}
+	policy := backoff.WithContext(backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxDumpRetries), ctx)
+	return dumpWithRetry(run, policy, stdout)
+}
+
+func schemaEnv(schema, excluded []string, keepComments bool, opts ...DumpOption) []string {
 	var env []string
+	var extraFlags []string
 	if len(schema) > 0 {
 		// Must append flag because empty string results in error
-		env = append(env, "EXTRA_FLAGS=--schema="+strings.Join(schema, "|"))
+		extraFlags = append(extraFlags, "--schema="+strings.Join(schema, "|"))
 	} else {
-		env = append(env, "EXCLUDED_SCHEMAS="+strings.Join(utils.InternalSchemas, "|"))
+		env = append(env, "EXCLUDED_SCHEMAS="+strings.Join(excluded, "|"))
+	}
+	for _, apply := range opts {
+		extraFlags = apply(extraFlags)
+	}
+	if len(extraFlags) > 0 {
+		env = append(env, "EXTRA_FLAGS="+strings.Join(extraFlags, " "))
 	}
 	if !keepComments {
 		env = append(env, "EXTRA_SED=/^--/d")
 	}
-	return dump(ctx, config, dumpSchemaScript, env, dryRun, stdout)
+	return env
+}
+
+// Dumps schemas in pg_dump custom format, which must be applied with pg_restore.
+func DumpSchemaCustom(ctx context.Context, config pgconn.Config, schema []string, stdout io.Writer, opts ...DumpOption) error {
+	var env []string
+	var extraFlags []string
+	if len(schema) > 0 {
+		extraFlags = append(extraFlags, "--schema="+strings.Join(schema, "|"))
+	} else {
+		env = append(env, "EXCLUDED_SCHEMAS="+strings.Join(utils.InternalSchemas, "|"))
+	}
+	for _, apply := range opts {
+		extraFlags = apply(extraFlags)
+	}
+	if len(extraFlags) > 0 {
+		env = append(env, "EXTRA_FLAGS="+strings.Join(extraFlags, " "))
+	}
+	return dump(ctx, config, dumpCustomScript, env, false, stdout)
+}
+
+// Dumps tablespaces and comments on roles which are not included in the schema dump.
+func DumpCluster(ctx context.Context, config pgconn.Config, stdout io.Writer) error {
+	return dump(ctx, config, dumpClusterScript, nil, false, stdout)
 }
 
-func dumpData(ctx context.Context, config pgconn.Config, schema, excludeTable []string, useCopy, dryRun bool, stdout io.Writer) error {
+func dumpData(ctx context.Context, config pgconn.Config, schema, excludeTable []string, useCopy, dryRun bool, stdout io.Writer, opts ...DumpOption) error {
 	// We want to dump user data in auth, storage, etc. for migrating to new project
 	excludedSchemas := []string{
 		"information_schema",
@@ -115,6 +226,9 @@ func dumpData(ctx context.Context, config pgconn.Config, schema, excludeTable []
 	for _, table := range excludeTable {
 		extraFlags = append(extraFlags, "--exclude-table "+table)
 	}
+	for _, apply := range opts {
+		extraFlags = apply(extraFlags)
+	}
 	if len(extraFlags) > 0 {
 		env = append(env, "EXTRA_FLAGS="+strings.Join(extraFlags, " "))
 	}
@@ -130,15 +244,7 @@ func dumpRole(ctx context.Context, config pgconn.Config, keepComments, dryRun bo
 }
 
 func dump(ctx context.Context, config pgconn.Config, script string, env []string, dryRun bool, stdout io.Writer) error {
-	allEnvs := append(env,
-		"PGHOST="+config.Host,
-		fmt.Sprintf("PGPORT=%d", config.Port),
-		"PGUSER="+config.User,
-		"PGPASSWORD="+config.Password,
-		"PGDATABASE="+config.Database,
-		"RESERVED_ROLES="+strings.Join(utils.ReservedRoles, "|"),
-		"ALLOWED_CONFIGS="+strings.Join(utils.AllowedConfigs, "|"),
-	)
+	allEnvs := dumpEnv(config, env)
 	if dryRun {
 		envMap := make(map[string]string, len(allEnvs))
 		for _, e := range allEnvs {
@@ -157,11 +263,27 @@ func dump(ctx context.Context, config pgconn.Config, script string, env []string
 		fmt.Println(expanded)
 		return nil
 	}
+	return runDump(ctx, script, allEnvs, stdout, os.Stderr)
+}
+
+func dumpEnv(config pgconn.Config, env []string) []string {
+	return append(env,
+		"PGHOST="+config.Host,
+		fmt.Sprintf("PGPORT=%d", config.Port),
+		"PGUSER="+config.User,
+		"PGPASSWORD="+config.Password,
+		"PGDATABASE="+config.Database,
+		"RESERVED_ROLES="+strings.Join(utils.ReservedRoles, "|"),
+		"ALLOWED_CONFIGS="+strings.Join(utils.AllowedConfigs, "|"),
+	)
+}
+
+func runDump(ctx context.Context, script string, env []string, stdout, stderr io.Writer) error {
 	return utils.DockerRunOnceWithConfig(
 		ctx,
 		container.Config{
 			Image: utils.Pg15Image,
-			Env:   allEnvs,
+			Env:   env,
 			Cmd:   []string{"bash", "-c", script, "--"},
 		},
 		container.HostConfig{
@@ -170,6 +292,48 @@ func dump(ctx context.Context, config pgconn.Config, script string, env []string
 		network.NetworkingConfig{},
 		"",
 		stdout,
-		os.Stderr,
+		stderr,
 	)
 }
+
+const maxDumpRetries = 3
+
+// Substrings of libpq errors that indicate the connection dropped mid dump.
+var transientErrors = []string{
+	"server closed the connection unexpectedly",
+	"connection reset by peer",
+	"connection timed out",
+	"could not receive data from server",
+	"could not send data to server",
+	"ssl syscall error",
+	"ssl connection has been closed unexpectedly",
+}
+
+func isTransientError(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	for _, pattern := range transientErrors {
+		if strings.Contains(stderr, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Since pg_dump is not resumable, each retry starts over after resetting the output.
+func dumpWithRetry(run func(stdout, stderr io.Writer) error, policy backoff.BackOff, stdout ResetWriter) error {
+	var errBuf bytes.Buffer
+	attempt := 0
+	return backoff.RetryNotify(func() error {
+		stdout.Reset()
+		errBuf.Reset()
+		err := run(stdout, io.MultiWriter(&errBuf, os.Stderr))
+		if err != nil && !isTransientError(errBuf.String()) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, policy, func(err error, d time.Duration) {
+		attempt++
+		fmt.Fprintln(utils.GetDebugLogger(), err)
+		fmt.Fprintf(os.Stderr, "Retrying dump after transient error (%d/%d)...\n", attempt, maxDumpRetries)
+	})
+}
@@ -1,10 +1,15 @@
 package dump
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"testing"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/jackc/pgconn"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
@@ -60,6 +65,21 @@ func TestPullCommand(t *testing.T) {
 		assert.Empty(t, apitest.ListUnmatchedRequests())
 	})
 
+	t.Run("dumps data with consistent snapshot", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, imageUrl, containerId)
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, containerId, "hello world"))
+		// Run test
+		err := Run(context.Background(), "data.sql", dbConfig, nil, nil, true, false, false, false, false, fsys, WithConsistentSnapshot)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
 	t.Run("throws error on missing docker", func(t *testing.T) {
 		// Setup in-memory fs
 		fsys := afero.NewMemMapFs()
@@ -91,3 +111,95 @@ func TestPullCommand(t *testing.T) {
 		assert.Empty(t, apitest.ListUnmatchedRequests())
 	})
 }
+
+func TestConsistentSnapshot(t *testing.T) {
+	flags := WithConsistentSnapshot([]string{"--schema=public"})
+	// Check output
+	assert.Equal(t, []string{"--schema=public", "--serializable-deferrable"}, flags)
+}
+
+func TestQuoteAllIdentifiers(t *testing.T) {
+	flags := WithQuoteAllIdentifiers([]string{"--schema=public"})
+	// Check output
+	assert.Equal(t, []string{"--schema=public", "--quote-all-identifiers"}, flags)
+}
+
+func TestNoSecurityLabels(t *testing.T) {
+	flags := WithNoSecurityLabels([]string{"--schema=public"})
+	// Check output
+	assert.Equal(t, []string{"--schema=public", "--no-security-labels"}, flags)
+}
+
+func TestExcludeSchemas(t *testing.T) {
+	t.Run("appends exclude pattern", func(t *testing.T) {
+		flags := WithExcludeSchemas([]string{"cron", "supabase_functions"})(nil)
+		// Check output
+		assert.Equal(t, []string{"--exclude-schema=cron|supabase_functions"}, flags)
+	})
+
+	t.Run("keeps flags when empty", func(t *testing.T) {
+		flags := WithExcludeSchemas(nil)([]string{"--schema=public"})
+		// Check output
+		assert.Equal(t, []string{"--schema=public"}, flags)
+	})
+}
+
+func TestDumpRetry(t *testing.T) {
+	policy := backoff.WithMaxRetries(&backoff.ZeroBackOff{}, maxDumpRetries)
+
+	t.Run("retries from scratch on transient error", func(t *testing.T) {
+		attempts := 0
+		run := func(stdout, stderr io.Writer) error {
+			attempts++
+			fmt.Fprint(stdout, "create table a;")
+			if attempts == 1 {
+				fmt.Fprintln(stderr, "pg_dump: error: server closed the connection unexpectedly")
+				return errors.New("error running container: exit 1")
+			}
+			fmt.Fprint(stdout, "create table b;")
+			return nil
+		}
+		var out bytes.Buffer
+		// Run test
+		err := dumpWithRetry(run, policy, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+		assert.Equal(t, "create table a;create table b;", out.String())
+	})
+
+	t.Run("throws error on permanent failure", func(t *testing.T) {
+		policy.Reset()
+		attempts := 0
+		run := func(stdout, stderr io.Writer) error {
+			attempts++
+			fmt.Fprintln(stderr, `pg_dump: error: permission denied for schema "private"`)
+			return errors.New("error running container: exit 1")
+		}
+		var out bytes.Buffer
+		// Run test
+		err := dumpWithRetry(run, policy, &out)
+		// Check error
+		assert.ErrorContains(t, err, "error running container: exit 1")
+		assert.Equal(t, 1, attempts)
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("throws error after max retries", func(t *testing.T) {
+		policy.Reset()
+		attempts := 0
+		run := func(stdout, stderr io.Writer) error {
+			attempts++
+			fmt.Fprint(stdout, "create table a;")
+			fmt.Fprintln(stderr, "pg_dump: error: could not receive data from server: Connection reset by peer")
+			return errors.New("error running container: exit 1")
+		}
+		var out bytes.Buffer
+		// Run test
+		err := dumpWithRetry(run, policy, &out)
+		// Check error
+		assert.ErrorContains(t, err, "error running container: exit 1")
+		assert.Equal(t, maxDumpRetries+1, attempts)
+		assert.Equal(t, "create table a;", out.String())
+	})
+}
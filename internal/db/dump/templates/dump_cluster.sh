@@ -0,0 +1,29 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+export PGHOST="$PGHOST"
+export PGPORT="$PGPORT"
+export PGUSER="$PGUSER"
+export PGPASSWORD="$PGPASSWORD"
+export PGDATABASE="$PGDATABASE"
+
+# Explanation of pg_dumpall flags:
+#
+#   --tablespaces-only   only include create tablespace statements
+#   --roles-only         only include role statements, filtered to comments below
+#
+# Explanation of grep and sed filters:
+#
+#   - keep tablespace definitions, owners, options, and comments
+#   - keep comments on roles, except reserved roles which are managed by platform
+pg_dumpall \
+    --tablespaces-only \
+    --quote-all-identifier \
+| { grep -E '^(CREATE|ALTER|COMMENT ON) TABLESPACE ' || true; }
+
+pg_dumpall \
+    --roles-only \
+    --quote-all-identifier \
+    --no-role-passwords \
+| { grep -E '^COMMENT ON ROLE ' || true; } \
+| sed -E "s/^COMMENT ON ROLE \"($RESERVED_ROLES)\"/-- &/"
@@ -0,0 +1,26 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+export PGHOST="$PGHOST"
+export PGPORT="$PGPORT"
+export PGUSER="$PGUSER"
+export PGPASSWORD="$PGPASSWORD"
+export PGDATABASE="$PGDATABASE"
+
+# Explanation of pg_dump flags:
+#
+#   --format=custom      write a compressed archive that can be restored by pg_restore
+#   --schema-only        omit data like migration history, pgsodium key, etc.
+#   --no-publications    omit creating publication "supabase_realtime"
+#   --no-subscriptions   omit logical replication subscriptions
+#   --exclude-schema     omit internal schemas as they are maintained by platform
+#
+# Unlike plain format, the archive is binary so entries that dump_schema.sh comments out
+# are instead filtered from the list by restore_custom.sh when the archive is restored.
+pg_dump \
+    --format=custom \
+    --schema-only \
+    --no-publications \
+    --no-subscriptions \
+    --exclude-schema "${EXCLUDED_SCHEMAS:-}" \
+    ${EXTRA_FLAGS:-}
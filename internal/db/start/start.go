@@ -22,6 +22,7 @@ import (
 	"github.com/supabase/cli/internal/migration/apply"
 	"github.com/supabase/cli/internal/status"
 	"github.com/supabase/cli/internal/utils"
+	"github.com/supabase/cli/internal/utils/pgxv5"
 )
 
 var (
@@ -53,6 +54,11 @@ func Run(ctx context.Context, fsys afero.Fs) error {
 }
 
 func NewContainerConfig() container.Config {
+	return NewContainerConfigFor(utils.Config.Db.Image, utils.Config.Db.MajorVersion)
+}
+
+// Builds the database container config for the given image instead of the configured one.
+func NewContainerConfigFor(image string, majorVersion uint) container.Config {
 	env := []string{
 		"POSTGRES_PASSWORD=" + utils.Config.Db.Password,
 		"POSTGRES_HOST=/var/run/postgresql",
@@ -73,7 +79,7 @@ func NewContainerConfig() container.Config {
 		env = append(env, "POSTGRES_INITDB_ARGS=--lc-collate=C.UTF-8")
 	}
 	config := container.Config{
-		Image: utils.Config.Db.Image,
+		Image: image,
 		Env:   env,
 		Healthcheck: &container.HealthConfig{
 			Test:     []string{"CMD", "pg_isready", "-U", "postgres", "-h", "127.0.0.1", "-p", "5432"},
@@ -88,7 +94,7 @@ EOF
 EOF
 `},
 	}
-	if utils.Config.Db.MajorVersion >= 14 {
+	if majorVersion >= 14 {
 		config.Cmd = []string{"postgres",
 			"-c", "config_file=/etc/postgresql/postgresql.conf",
 			// Ref: https://postgrespro.com/list/thread-id/2448092
@@ -169,6 +175,39 @@ func WaitForHealthyService(ctx context.Context, container string, timeout time.D
 	return RetryEverySecond(ctx, probe, timeout)
 }
 
+// Schemas created by SetupDatabase that migrations commonly depend on.
+var BaselineSchemas = []string{"auth", "extensions", "storage"}
+
+const SELECT_MISSING_SCHEMAS = "SELECT s FROM unnest($1::text[]) s WHERE s NOT IN (SELECT nspname FROM pg_namespace)"
+
+// Polls until all baseline schemas are visible to conn, so that migrations never race against setup.
+// The migration history schema is not checked because it is created by the first migrate up.
+func WaitForMigrationsReady(ctx context.Context, conn *pgx.Conn, timeout time.Duration) bool {
+	logger := utils.GetDebugLogger()
+	probe := func() bool {
+		if err := assertSchemasExist(ctx, conn, BaselineSchemas); err != nil {
+			fmt.Fprintln(logger, err)
+			return false
+		}
+		return true
+	}
+	return RetryEverySecond(ctx, probe, timeout)
+}
+
+func assertSchemasExist(ctx context.Context, conn *pgx.Conn, schemas []string) error {
+	rows, err := conn.Query(ctx, SELECT_MISSING_SCHEMAS, schemas)
+	if err != nil {
+		return errors.Errorf("failed to query schemas: %w", err)
+	}
+	missing, err := pgxv5.CollectStrings(rows)
+	if err != nil {
+		return err
+	} else if len(missing) > 0 {
+		return errors.Errorf("waiting for schemas: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
 func WithSyslogConfig(hostConfig container.HostConfig) container.HostConfig {
 	if utils.Config.Analytics.Enabled {
 		hostConfig.LogConfig.Type = "syslog"
@@ -190,9 +229,9 @@ func initCurrentBranch(fsys afero.Fs) error {
 	return utils.WriteFile(utils.CurrBranchPath, []byte("main"), fsys)
 }
 
-func initSchema(ctx context.Context, conn *pgx.Conn, host string, w io.Writer) error {
+func initSchema(ctx context.Context, conn *pgx.Conn, host string, majorVersion uint, w io.Writer) error {
 	fmt.Fprintln(w, "Setting up initial schema...")
-	if utils.Config.Db.MajorVersion <= 14 {
+	if majorVersion <= 14 {
 		return initSchema14(ctx, conn)
 	}
 	return initSchema15(ctx, host)
@@ -270,8 +309,45 @@ func setupDatabase(ctx context.Context, fsys afero.Fs, w io.Writer, options ...f
 }
 
 func SetupDatabase(ctx context.Context, conn *pgx.Conn, host string, w io.Writer, fsys afero.Fs) error {
-	if err := initSchema(ctx, conn, host, w); err != nil {
+	return setupSchema(ctx, conn, host, utils.Config.Db.MajorVersion, w, fsys)
+}
+
+func setupSchema(ctx context.Context, conn *pgx.Conn, host string, majorVersion uint, w io.Writer, fsys afero.Fs) error {
+	if err := initSchema(ctx, conn, host, majorVersion, w); err != nil {
 		return err
 	}
 	return push.CreateCustomRoles(ctx, conn, w, fsys)
 }
+
+// Sets up a shadow database with the search path configured to match production.
+func SetupShadowDatabase(ctx context.Context, conn *pgx.Conn, host string, w io.Writer, fsys afero.Fs) error {
+	return SetupShadowDatabaseFor(ctx, conn, host, utils.Config.Db.MajorVersion, w, fsys)
+}
+
+// Sets up a shadow database running the given major version instead of the configured one.
+func SetupShadowDatabaseFor(ctx context.Context, conn *pgx.Conn, host string, majorVersion uint, w io.Writer, fsys afero.Fs) error {
+	if err := setupSchema(ctx, conn, host, majorVersion, w, fsys); err != nil {
+		return err
+	}
+	return setSearchPath(ctx, conn, utils.Config.Db.Shadow.SearchPath)
+}
+
+// Applies the search path to the current session and to new sessions of the connected role.
+func setSearchPath(ctx context.Context, conn *pgx.Conn, schemas []string) error {
+	if len(schemas) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(schemas))
+	for i, name := range schemas {
+		quoted[i] = pgx.Identifier{name}.Sanitize()
+	}
+	path := strings.Join(quoted, ", ")
+	role := pgx.Identifier{conn.Config().User}.Sanitize()
+	batch := pgconn.Batch{}
+	batch.ExecParams("ALTER ROLE "+role+" SET search_path TO "+path, nil, nil, nil, nil)
+	batch.ExecParams("SET search_path TO "+path, nil, nil, nil, nil)
+	if _, err := conn.PgConn().ExecBatch(ctx, &batch).ReadAll(); err != nil {
+		return errors.Errorf("failed to set search path: %w", err)
+	}
+	return nil
+}
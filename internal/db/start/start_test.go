@@ -7,9 +7,11 @@ import (
 	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/volume"
+	"github.com/jackc/pgconn"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -324,3 +326,61 @@ func TestSetupDatabase(t *testing.T) {
 		assert.Empty(t, apitest.ListUnmatchedRequests())
 	})
 }
+
+func TestSetSearchPath(t *testing.T) {
+	t.Run("sets search path of role and session", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(`ALTER ROLE "postgres" SET search_path TO "$user", "public", "extensions"`).
+			Reply("ALTER ROLE").
+			Query(`SET search_path TO "$user", "public", "extensions"`).
+			Reply("SET")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = setSearchPath(ctx, mock, []string{"$user", "public", "extensions"})
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("skips unconfigured search path", func(t *testing.T) {
+		// Run test
+		err := setSearchPath(context.Background(), nil, nil)
+		// Check error
+		assert.NoError(t, err)
+	})
+}
+
+func TestWaitForMigrationsReady(t *testing.T) {
+	t.Run("returns when schemas exist", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(SELECT_MISSING_SCHEMAS, BaselineSchemas).
+			Reply("SELECT 0")
+		db, err := utils.ConnectLocalPostgres(context.Background(), pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer db.Close(context.Background())
+		// Run test
+		assert.True(t, WaitForMigrationsReady(context.Background(), db, time.Second))
+	})
+
+	t.Run("throws error on missing schema", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(SELECT_MISSING_SCHEMAS, BaselineSchemas).
+			Reply("SELECT 1", []interface{}{"storage"})
+		db, err := utils.ConnectLocalPostgres(context.Background(), pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer db.Close(context.Background())
+		// Run test
+		err = assertSchemasExist(context.Background(), db, BaselineSchemas)
+		// Check error
+		assert.ErrorContains(t, err, "waiting for schemas: storage")
+	})
+}
@@ -3,6 +3,7 @@ package typescript
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -18,11 +19,7 @@ import (
 
 func Run(ctx context.Context, projectId string, dbConfig pgconn.Config, schemas []string, postgrestV9Compat bool, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
 	originalURL := utils.ToPostgresURL(dbConfig)
-	// Add default schemas if --schema flag is not specified
-	if len(schemas) == 0 {
-		schemas = utils.RemoveDuplicates(append([]string{"public"}, utils.Config.Api.Schemas...))
-	}
-	included := strings.Join(schemas, ",")
+	included := getIncludedSchemas(schemas)
 
 	if projectId != "" {
 		resp, err := utils.GetSupabase().GetTypescriptTypesWithResponse(ctx, projectId, &api.GetTypescriptTypesParams{
@@ -69,12 +66,30 @@ func Run(ctx context.Context, projectId string, dbConfig pgconn.Config, schemas
 		escaped += "&sslmode=require"
 	}
 
+	return runPgMeta(ctx, escaped, networkID, included, postgrestV9Compat, os.Stdout)
+}
+
+// Generates types from a database reachable on the host network, such as the shadow database.
+func GenerateFromHost(ctx context.Context, dbConfig pgconn.Config, schemas []string, w io.Writer) error {
+	postgrestV9Compat := strings.Contains(utils.Config.Api.Image, "v9")
+	return runPgMeta(ctx, utils.ToPostgresURL(dbConfig), "host", getIncludedSchemas(schemas), postgrestV9Compat, w)
+}
+
+func getIncludedSchemas(schemas []string) string {
+	// Add default schemas if --schema flag is not specified
+	if len(schemas) == 0 {
+		schemas = utils.RemoveDuplicates(append([]string{"public"}, utils.Config.Api.Schemas...))
+	}
+	return strings.Join(schemas, ",")
+}
+
+func runPgMeta(ctx context.Context, dbUrl, networkID, included string, postgrestV9Compat bool, stdout io.Writer) error {
 	return utils.DockerRunOnceWithConfig(
 		ctx,
 		container.Config{
 			Image: utils.PgmetaImage,
 			Env: []string{
-				"PG_META_DB_URL=" + escaped,
+				"PG_META_DB_URL=" + dbUrl,
 				"PG_META_GENERATE_TYPES=typescript",
 				"PG_META_GENERATE_TYPES_INCLUDED_SCHEMAS=" + included,
 				fmt.Sprintf("PG_META_GENERATE_TYPES_DETECT_ONE_TO_ONE_RELATIONSHIPS=%v", !postgrestV9Compat),
@@ -86,7 +101,7 @@ func Run(ctx context.Context, projectId string, dbConfig pgconn.Config, schemas
 		},
 		network.NetworkingConfig{},
 		"",
-		os.Stdout,
+		stdout,
 		os.Stderr,
 	)
 }
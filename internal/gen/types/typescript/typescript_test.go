@@ -1,6 +1,7 @@
 package typescript
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"net/http"
@@ -166,3 +167,30 @@ func TestGenRemoteCommand(t *testing.T) {
 		assert.Empty(t, apitest.ListUnmatchedRequests())
 	})
 }
+
+func TestGenerateFromHost(t *testing.T) {
+	dbConfig := pgconn.Config{
+		Host:     "127.0.0.1",
+		Port:     54320,
+		User:     "postgres",
+		Password: "postgres",
+		Database: "postgres",
+	}
+
+	t.Run("writes types to output", func(t *testing.T) {
+		const containerId = "test-pgmeta"
+		imageUrl := utils.GetRegistryImageUrl(utils.PgmetaImage)
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, imageUrl, containerId)
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, containerId, "export type Json = string\n"))
+		// Run test
+		var out bytes.Buffer
+		err := GenerateFromHost(context.Background(), dbConfig, nil, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "export type Json = string\n", out.String())
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
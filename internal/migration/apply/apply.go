@@ -2,12 +2,19 @@ package apply
 
 import (
 	"context"
+	_ "embed"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
 	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/spf13/afero"
 	"github.com/supabase/cli/internal/migration/history"
@@ -40,27 +47,150 @@ func SeedDatabase(ctx context.Context, conn *pgx.Conn, fsys afero.Fs) error {
 }
 
 func MigrateUp(ctx context.Context, conn *pgx.Conn, pending []string, fsys afero.Fs) error {
+	return MigrateUpWithProfile(ctx, conn, pending, nil, false, fsys)
+}
+
+// Applies pending migrations, recording the wall-clock time of each file when profile is not nil.
+// With continueOnError, failing statements are logged and returned together after all files are applied.
+func MigrateUpWithProfile(ctx context.Context, conn *pgx.Conn, pending []string, profile *Profile, continueOnError bool, fsys afero.Fs) error {
 	if len(pending) > 0 {
 		if err := history.CreateMigrationTable(ctx, conn); err != nil {
 			return err
 		}
 	}
+	var failed []error
 	for _, filename := range pending {
-		if err := applyMigration(ctx, conn, filename, fsys); err != nil {
-			return err
+		start := time.Now()
+		if errs := applyMigration(ctx, conn, filename, profile, continueOnError, fsys); len(errs) > 0 {
+			if !continueOnError {
+				return errs[0]
+			}
+			failed = append(failed, errs...)
+		}
+		if profile != nil {
+			profile.Timings = append(profile.Timings, Timing{Name: filename, Duration: time.Since(start)})
 		}
 	}
+	if len(failed) > 0 {
+		return errors.Errorf("%d statements failed to apply:\n%w", len(failed), errors.Join(failed...))
+	}
 	return nil
 }
 
-func applyMigration(ctx context.Context, conn *pgx.Conn, filename string, fsys afero.Fs) error {
+type Timing struct {
+	Name     string
+	Duration time.Duration
+}
+
+type Profile struct {
+	Timings []Timing
+	// Number of statements applied across all migration files
+	Statements int
+}
+
+const maxProfileRows = 10
+
+// Prints the slowest migrations in descending order of duration.
+func (p *Profile) Print(w io.Writer) {
+	if len(p.Timings) == 0 {
+		return
+	}
+	sorted := make([]Timing, len(p.Timings))
+	copy(sorted, p.Timings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Duration > sorted[j].Duration
+	})
+	if len(sorted) > maxProfileRows {
+		sorted = sorted[:maxProfileRows]
+	}
+	fmt.Fprintln(w, "Slowest migrations:")
+	for _, t := range sorted {
+		fmt.Fprintf(w, "  %10s  %s\n", t.Duration.Round(time.Millisecond), t.Name)
+	}
+}
+
+func applyMigration(ctx context.Context, conn *pgx.Conn, filename string, profile *Profile, continueOnError bool, fsys afero.Fs) []error {
 	fmt.Fprintln(os.Stderr, "Applying migration "+utils.Bold(filename)+"...")
 	path := filepath.Join(utils.MigrationsDir, filename)
 	migration, err := repair.NewMigrationFromFile(path, fsys)
 	if err != nil {
-		return err
+		return []error{err}
 	}
-	return migration.ExecBatch(ctx, conn)
+	if profile != nil {
+		profile.Statements += len(migration.Lines)
+	}
+	// Squashed migrations in custom format must be restored before applying managed schema changes
+	dumpPath := repair.GetCustomDumpPath(migration.Version)
+	if exists, err := afero.Exists(fsys, dumpPath); err != nil {
+		return []error{errors.Errorf("failed to check custom dump: %w", err)}
+	} else if exists {
+		if err := RestoreDump(ctx, conn.Config().Config, dumpPath, fsys); err != nil {
+			return []error{err}
+		}
+	}
+	if !continueOnError {
+		if err := migration.ExecBatch(ctx, conn); err != nil {
+			return []error{err}
+		}
+		return nil
+	}
+	errs := migration.ExecEach(ctx, conn)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), filename+":", err)
+	}
+	return errs
+}
+
+//go:embed templates/restore_custom.sh
+var restoreCustomScript string
+
+// Restores a pg_dump custom format archive using pg_restore in a container of the configured
+// major version, streaming the archive over stdin so that it may be read from any filesystem.
+func RestoreDump(ctx context.Context, config pgconn.Config, path string, fsys afero.Fs) error {
+	fmt.Fprintln(os.Stderr, "Restoring custom dump "+utils.Bold(path)+"...")
+	f, err := fsys.Open(path)
+	if err != nil {
+		return errors.Errorf("failed to open custom dump: %w", err)
+	}
+	defer f.Close()
+	return utils.DockerRunOnceWithStdin(
+		ctx,
+		container.Config{
+			Image: utils.Config.Db.Image,
+			Env: []string{
+				"PGHOST=" + config.Host,
+				fmt.Sprintf("PGPORT=%d", config.Port),
+				"PGUSER=" + config.User,
+				"PGPASSWORD=" + config.Password,
+				"PGDATABASE=" + config.Database,
+				"EXCLUDED_ENTRIES=" + excludedEntries(utils.InternalSchemas),
+			},
+			Cmd: []string{"bash", "-c", restoreCustomScript, "--"},
+		},
+		container.HostConfig{
+			NetworkMode: container.NetworkMode("host"),
+		},
+		network.NetworkingConfig{},
+		"",
+		f,
+		os.Stdout,
+		os.Stderr,
+	)
+}
+
+// Matches pg_restore list entries that the plain schema dump comments out, because the role
+// restoring the archive cannot recreate them. Entries are formatted as
+//
+//	<id>; <catalog> <oid> <type> <schema> <name> <owner>
+func excludedEntries(schemas []string) string {
+	internal := strings.Join(schemas, "|")
+	return strings.Join([]string{
+		` EVENT TRIGGER `,
+		` DEFAULT ACL .* supabase_admin$`,
+		` ACL (- SCHEMA )?(` + internal + `) `,
+		` COMMENT - EXTENSION `,
+		` (POLICY|ROW SECURITY) cron `,
+	}, "|")
 }
 
 func BatchExecDDL(ctx context.Context, conn *pgx.Conn, sql io.Reader) error {
@@ -1,10 +1,16 @@
 package apply
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgerrcode"
@@ -12,9 +18,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/supabase/cli/internal/migration/history"
+	"github.com/supabase/cli/internal/testing/apitest"
 	"github.com/supabase/cli/internal/testing/fstest"
 	"github.com/supabase/cli/internal/testing/pgtest"
 	"github.com/supabase/cli/internal/utils"
+	"gopkg.in/h2non/gock.v1"
 )
 
 func TestMigrateDatabase(t *testing.T) {
@@ -43,6 +51,59 @@ func TestMigrateDatabase(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("restores custom dump with configured image", func(t *testing.T) {
+		image := utils.Config.Db.Image
+		utils.Config.Db.Image = utils.Pg14Image
+		defer func() { utils.Config.Db.Image = image }()
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_test.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema public"), 0644))
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "0.dump"), []byte("PGDMP"), 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg14Image), "test-restore")
+		gock.New(utils.Docker.DaemonHost()).
+			Delete("/v" + utils.Docker.ClientVersion() + "/containers/test-restore").
+			Reply(http.StatusOK)
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		pgtest.MockMigrationHistory(conn)
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = MigrateAndSeed(ctx, "", mock, fsys)
+		// Check error
+		assert.ErrorContains(t, err, "failed to attach docker container")
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("throws error on dump open failure", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := &fstest.OpenErrorFs{DenyPath: filepath.Join(utils.MigrationsDir, "0.dump")}
+		path := filepath.Join(utils.MigrationsDir, "0_test.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema public"), 0644))
+		require.NoError(t, afero.WriteFile(&fsys.MemMapFs, filepath.Join(utils.MigrationsDir, "0.dump"), []byte("PGDMP"), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		pgtest.MockMigrationHistory(conn)
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = MigrateAndSeed(ctx, "", mock, fsys)
+		// Check error
+		assert.ErrorIs(t, err, os.ErrPermission)
+	})
+
 	t.Run("ignores empty local directory", func(t *testing.T) {
 		assert.NoError(t, MigrateAndSeed(context.Background(), "", nil, afero.NewMemMapFs()))
 	})
@@ -57,6 +118,95 @@ func TestMigrateDatabase(t *testing.T) {
 	})
 }
 
+func TestContinueOnError(t *testing.T) {
+	t.Run("collects errors from all migrations", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		files := map[string]string{
+			"0_test.sql": "create schema public;\nselect fail",
+			"1_test.sql": "drop table missing",
+		}
+		for name, sql := range files {
+			path := filepath.Join(utils.MigrationsDir, name)
+			require.NoError(t, afero.WriteFile(fsys, path, []byte(sql), 0644))
+		}
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		pgtest.MockMigrationHistory(conn)
+		conn.Query("create schema public").
+			Reply("CREATE SCHEMA").
+			Query("select fail").
+			ReplyError(pgerrcode.UndefinedColumn, `column "fail" does not exist`).
+			Query(history.INSERT_MIGRATION_VERSION, "0", "test", []string{"create schema public", "select fail"}).
+			Reply("INSERT 0 1").
+			Query("drop table missing").
+			ReplyError(pgerrcode.UndefinedTable, `table "missing" does not exist`).
+			Query(history.INSERT_MIGRATION_VERSION, "1", "test", []string{"drop table missing"}).
+			Reply("INSERT 0 1")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = MigrateUpWithProfile(ctx, mock, []string{"0_test.sql", "1_test.sql"}, nil, true, fsys)
+		// Check error
+		assert.ErrorContains(t, err, "2 statements failed to apply:")
+		assert.ErrorContains(t, err, `ERROR: column "fail" does not exist (SQLSTATE 42703)`)
+		assert.ErrorContains(t, err, `ERROR: table "missing" does not exist (SQLSTATE 42P01)`)
+	})
+}
+
+func TestMigrateProfile(t *testing.T) {
+	t.Run("records time per migration", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_test.sql")
+		sql := "create schema public"
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(sql), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		pgtest.MockMigrationHistory(conn)
+		conn.Query(sql).
+			Reply("CREATE SCHEMA").
+			Query(history.INSERT_MIGRATION_VERSION, "0", "test", []string{sql}).
+			Reply("INSERT 0 1")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		var profile Profile
+		err = MigrateUpWithProfile(ctx, mock, []string{"0_test.sql"}, &profile, false, fsys)
+		// Check error
+		assert.NoError(t, err)
+		require.Len(t, profile.Timings, 1)
+		assert.Equal(t, "0_test.sql", profile.Timings[0].Name)
+	})
+
+	t.Run("prints slowest migrations first", func(t *testing.T) {
+		profile := Profile{}
+		for i := 0; i <= maxProfileRows; i++ {
+			profile.Timings = append(profile.Timings, Timing{
+				Name:     fmt.Sprintf("%d_test.sql", i),
+				Duration: time.Duration(i) * time.Second,
+			})
+		}
+		var out bytes.Buffer
+		// Run test
+		profile.Print(&out)
+		// Check output
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		assert.Len(t, lines, maxProfileRows+1)
+		assert.Equal(t, "Slowest migrations:", lines[0])
+		assert.Equal(t, "         10s  10_test.sql", lines[1])
+		assert.Equal(t, "          1s  1_test.sql", lines[maxProfileRows])
+	})
+}
+
 func TestSeedDatabase(t *testing.T) {
 	t.Run("seeds from file", func(t *testing.T) {
 		// Setup in-memory fs
@@ -133,3 +283,34 @@ func TestMigrateUp(t *testing.T) {
 		assert.ErrorIs(t, err, os.ErrNotExist)
 	})
 }
+
+func TestExcludedEntries(t *testing.T) {
+	pattern := regexp.MustCompile(excludedEntries(utils.InternalSchemas))
+
+	t.Run("filters entries restricted to superuser", func(t *testing.T) {
+		for _, entry := range []string{
+			"4012; 3466 17049 EVENT TRIGGER - issue_pg_cron_access supabase_admin",
+			"3921; 826 16520 DEFAULT ACL public DEFAULT PRIVILEGES FOR TABLES supabase_admin",
+			"3922; 0 0 ACL - SCHEMA extensions supabase_admin",
+			"3923; 0 0 ACL auth FUNCTION uid() supabase_auth_admin",
+			"3924; 0 0 COMMENT - EXTENSION pgcrypto ",
+			"3925; 3256 29000 POLICY cron job cron_job_policy supabase_admin",
+			"3926; 0 0 ROW SECURITY cron job supabase_admin",
+		} {
+			assert.Regexp(t, pattern, entry)
+		}
+	})
+
+	t.Run("keeps user entries", func(t *testing.T) {
+		for _, entry := range []string{
+			"215; 1259 16386 TABLE public todos postgres",
+			"3927; 826 16521 DEFAULT ACL public DEFAULT PRIVILEGES FOR TABLES postgres",
+			"3928; 0 0 ACL - SCHEMA public pg_database_owner",
+			"3929; 0 0 ACL public TABLE todos postgres",
+			"3930; 0 0 COMMENT public TABLE todos postgres",
+			"3931; 3256 29001 POLICY public todos todos_policy postgres",
+		} {
+			assert.NotRegexp(t, pattern, entry)
+		}
+	})
+}
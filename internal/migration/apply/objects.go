@@ -0,0 +1,74 @@
+package apply
+
+import (
+	"regexp"
+	"strings"
+)
+
+const identPattern = `(?:"(?:[^"]|"")+"|[A-Za-z_][\w$]*)`
+
+var (
+	// Indexes, triggers and policies are dumped with the table they are defined on
+	definedOnPattern = regexp.MustCompile(`(?is)^\s*(?:CREATE|ALTER)\s+(?:OR\s+REPLACE\s+)?(?:(?:UNIQUE|CONSTRAINT)\s+)*(?:INDEX|TRIGGER|POLICY)\b.*?\sON\s+(?:ONLY\s+)?(` + identPattern + `(?:\.` + identPattern + `)?)`)
+	commentsPattern  = regexp.MustCompile(`^(\s*--[^\n]*\n)+`)
+	// Captures the kind and name of the object created by a statement
+	definitionPattern = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:OR\s+REPLACE\s+)?(?:(?:TEMP|TEMPORARY|UNLOGGED|MATERIALIZED|FOREIGN|CONSTRAINT|UNIQUE)\s+)*(TABLE|VIEW|FUNCTION|PROCEDURE|SEQUENCE|TYPE|DOMAIN|INDEX|TRIGGER|POLICY)\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(` + identPattern + `(?:\.` + identPattern + `)?)`)
+)
+
+// Unquotes a possibly qualified identifier, folding unquoted parts to lower case like postgres.
+func NormalizeName(name string) string {
+	parts := splitName(name)
+	if len(parts) == 1 {
+		parts = append([]string{"public"}, parts...)
+	}
+	return strings.Join(parts, ".")
+}
+
+func splitName(name string) []string {
+	var parts []string
+	for len(name) > 0 {
+		var part string
+		if name[0] == '"' {
+			end := 1
+			for end < len(name) {
+				if name[end] == '"' {
+					if end+1 < len(name) && name[end+1] == '"' {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			part = strings.ReplaceAll(name[1:end], `""`, `"`)
+			name = name[min(end+1, len(name)):]
+		} else {
+			end := strings.IndexByte(name, '.')
+			if end < 0 {
+				end = len(name)
+			}
+			part = strings.ToLower(name[:end])
+			name = name[end:]
+		}
+		parts = append(parts, part)
+		name = strings.TrimPrefix(name, ".")
+	}
+	return parts
+}
+
+// Returns the lower case kind and normalised name of the object created by a statement. Triggers
+// and policies are named after the table they are defined on, ie. public.users.on_insert.
+func DefinedObject(sql string) (string, string, bool) {
+	sql = commentsPattern.ReplaceAllString(sql, "")
+	matches := definitionPattern.FindStringSubmatch(sql)
+	if len(matches) < 3 {
+		return "", "", false
+	}
+	kind := strings.ToLower(matches[1])
+	if kind == "trigger" || kind == "policy" {
+		if on := definedOnPattern.FindStringSubmatch(sql); len(on) > 1 {
+			return kind, NormalizeName(on[1]) + "." + strings.Join(splitName(matches[2]), "."), true
+		}
+	}
+	return kind, NormalizeName(matches[2]), true
+}
@@ -0,0 +1,36 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "public.users", NormalizeName("users"))
+	assert.Equal(t, `my"schema.Users`, NormalizeName(`"my""schema"."Users"`))
+}
+
+func TestDefinedObject(t *testing.T) {
+	t.Run("parses kind and name", func(t *testing.T) {
+		kind, name, ok := DefinedObject(`CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS "Idx" ON users (id)`)
+		// Check output
+		assert.True(t, ok)
+		assert.Equal(t, "index", kind)
+		assert.Equal(t, "public.Idx", name)
+	})
+
+	t.Run("qualifies trigger with table", func(t *testing.T) {
+		kind, name, ok := DefinedObject("create trigger on_insert after insert on auth.users execute function f()")
+		// Check output
+		assert.True(t, ok)
+		assert.Equal(t, "trigger", kind)
+		assert.Equal(t, "auth.users.on_insert", name)
+	})
+
+	t.Run("ignores other statements", func(t *testing.T) {
+		_, _, ok := DefinedObject("alter table users add column name text")
+		// Check output
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,34 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+export PGHOST="$PGHOST"
+export PGPORT="$PGPORT"
+export PGUSER="$PGUSER"
+export PGPASSWORD="$PGPASSWORD"
+export PGDATABASE="$PGDATABASE"
+
+# The archive is streamed over stdin but must be seekable to restore from a list
+cat > /tmp/migration.dump
+
+# Explanation of list substitutions:
+#
+#   - do not alter superuser role "supabase_admin"
+#   - do not include ACL changes on internal schemas
+#   - do not include RLS policies on cron extension schema
+#   - do not include event triggers
+#   - do not include comments on extensions
+pg_restore --list /tmp/migration.dump \
+| sed -E "/${EXCLUDED_ENTRIES:-^$}/s/^/;/" \
+> /tmp/migration.list
+
+# Explanation of pg_restore flags:
+#
+#   --exit-on-error       abort on the first failed statement
+#   --single-transaction  roll back everything restored so far on failure
+#   --use-list            restore only entries that are not commented out
+pg_restore \
+    --exit-on-error \
+    --single-transaction \
+    --use-list /tmp/migration.list \
+    --dbname "$PGDATABASE" \
+    /tmp/migration.dump
@@ -1,12 +1,16 @@
 package list
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"math"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/glamour"
@@ -151,10 +155,24 @@ func LoadLocalMigrations(fsys afero.Fs) ([]string, error) {
 }
 
 func LoadPartialMigrations(version string, fsys afero.Fs) ([]string, error) {
+	return loadMigrations(version, false, fsys)
+}
+
+// Loads migrations up to version in the order of the migrations manifest, for squash to apply and merge.
+// Other commands compare local migrations with remote history by position so they keep filename order.
+func LoadOrderedMigrations(version string, fsys afero.Fs) ([]string, error) {
+	return loadMigrations(version, true, fsys)
+}
+
+func loadMigrations(version string, ordered bool, fsys afero.Fs) ([]string, error) {
 	localMigrations, err := afero.ReadDir(fsys, utils.MigrationsDir)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return nil, errors.Errorf("failed to read directory: %w", err)
 	}
+	logger := utils.GetDebugLogger()
+	if len(version) > 0 {
+		fmt.Fprintln(logger, "Loading migrations up to version", version)
+	}
 	var names []string
 	for i, migration := range localMigrations {
 		filename := migration.Name()
@@ -162,19 +180,50 @@ func LoadPartialMigrations(version string, fsys afero.Fs) ([]string, error) {
 			fmt.Fprintln(os.Stderr, "Skipping migration "+utils.Bold(filename)+`... (replace "init" with a different file name to apply this migration)`)
 			continue
 		}
+		// Markdown files, such as the squash changelog, document migrations
+		if isSidecarFile(filename) || filename == filepath.Base(utils.MigrationAssertionsPath) || filepath.Ext(filename) == ".md" {
+			continue
+		}
 		matches := utils.MigrateFilePattern.FindStringSubmatch(filename)
 		if len(matches) == 0 {
 			fmt.Fprintln(os.Stderr, "Skipping migration "+utils.Bold(filename)+`... (file name must match pattern "<timestamp>_name.sql")`)
 			continue
 		}
 		names = append(names, filename)
+	}
+	if ordered {
+		if names, err = applyManifest(names, fsys); err != nil {
+			return nil, err
+		}
+	}
+	for i, filename := range names {
+		matches := utils.MigrateFilePattern.FindStringSubmatch(filename)
 		if matches[1] == version {
-			break
+			fmt.Fprintln(logger, "Including migration", filename, "as the target version")
+			return names[:i+1], nil
+		}
+		if len(version) > 0 {
+			// Files are sorted by name or manifest so every file before the target is included
+			fmt.Fprintf(logger, "Including migration %s because version %s is ordered before %s\n", filename, matches[1], version)
+		} else {
+			fmt.Fprintln(logger, "Including migration", filename, "because no target version is specified")
 		}
 	}
 	return names, nil
 }
 
+// Files written alongside squashed migrations, such as custom format dumps and checksums.
+func isSidecarFile(name string) bool {
+	if strings.HasSuffix(name, ".down.sql") {
+		return true
+	}
+	switch filepath.Ext(name) {
+	case ".dump", ".sha256", ".asc":
+		return true
+	}
+	return false
+}
+
 func shouldSkip(name string) bool {
 	// NOTE: To handle backward-compatibility. `<timestamp>_init.sql` as
 	// the first migration (prev versions of the CLI) is deprecated.
@@ -186,3 +235,19 @@ func shouldSkip(name string) bool {
 	}
 	return false
 }
+
+// Written as the first line of every squashed migration so that repeated runs only merge newer migrations.
+const BaselineMarker = "-- supabase: squashed baseline"
+
+// A baseline is named squashed_baseline or starts with the baseline marker comment.
+func IsBaseline(name string, fsys afero.Fs) (bool, error) {
+	if matches := utils.MigrateFilePattern.FindStringSubmatch(name); len(matches) > 2 && strings.HasSuffix(matches[2], "squashed_baseline") {
+		return true, nil
+	}
+	contents, err := afero.ReadFile(fsys, filepath.Join(utils.MigrationsDir, name))
+	if err != nil {
+		return false, errors.Errorf("failed to read migration file: %w", err)
+	}
+	line, _, _ := bufio.NewReader(bytes.NewReader(contents)).ReadLine()
+	return strings.TrimSpace(string(line)) == BaselineMarker, nil
+}
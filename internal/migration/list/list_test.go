@@ -140,6 +140,34 @@ func TestLocalMigrations(t *testing.T) {
 		assert.Empty(t, versions)
 	})
 
+	t.Run("ignores squash sidecar files", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"1_squash.sql", "1.dump", "1.sql.sha256", "1.sql.asc", "1.down.sql"} {
+			path := filepath.Join(utils.MigrationsDir, name)
+			require.NoError(t, afero.WriteFile(fsys, path, []byte{}, 0644))
+		}
+		// Run test
+		versions, err := LoadLocalVersions(fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1"}, versions)
+	})
+
+	t.Run("loads migrations up to version", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"1_a.sql", "2_b.sql", "3_c.sql"} {
+			path := filepath.Join(utils.MigrationsDir, name)
+			require.NoError(t, afero.WriteFile(fsys, path, []byte{}, 0644))
+		}
+		// Run test
+		names, err := LoadPartialMigrations("2", fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1_a.sql", "2_b.sql"}, names)
+	})
+
 	t.Run("throws error on open failure", func(t *testing.T) {
 		// Setup in-memory fs
 		fsys := &fstest.OpenErrorFs{DenyPath: utils.MigrationsDir}
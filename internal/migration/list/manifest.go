@@ -0,0 +1,80 @@
+package list
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	UnlistedError  = "error"
+	UnlistedAppend = "append"
+)
+
+var ErrUnlisted = errors.New("migration not found in manifest")
+
+// Overrides the filename order of local migrations, ie.
+//
+//	unlisted: append
+//	migrations:
+//	  - 20230102000000_second.sql
+//	  - 20230101000000_first.sql
+type manifest struct {
+	// Policy for local migrations missing from the manifest, defaults to error
+	Unlisted   string   `yaml:"unlisted"`
+	Migrations []string `yaml:"migrations"`
+}
+
+func loadManifest(fsys afero.Fs) (*manifest, error) {
+	contents, err := afero.ReadFile(fsys, utils.MigrationsManifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Errorf("failed to read migrations manifest: %w", err)
+	}
+	var m manifest
+	if err := yaml.Unmarshal(contents, &m); err != nil {
+		return nil, errors.Errorf("failed to parse migrations manifest: %w", err)
+	}
+	if len(m.Unlisted) == 0 {
+		m.Unlisted = UnlistedError
+	}
+	if allowed := []string{UnlistedError, UnlistedAppend}; !utils.SliceContains(allowed, m.Unlisted) {
+		return nil, errors.Errorf("Invalid unlisted policy in %s. Must be one of: %v", utils.MigrationsManifestPath, allowed)
+	}
+	return &m, nil
+}
+
+// Sorts local migrations in manifest order, ignoring entries removed from the migrations directory.
+func applyManifest(names []string, fsys afero.Fs) ([]string, error) {
+	m, err := loadManifest(fsys)
+	if err != nil || m == nil {
+		return names, err
+	}
+	exists := make(map[string]bool, len(names))
+	for _, name := range names {
+		exists[name] = true
+	}
+	result := make([]string, 0, len(names))
+	for _, name := range m.Migrations {
+		if exists[name] {
+			result = append(result, name)
+			delete(exists, name)
+		}
+	}
+	for _, name := range names {
+		if !exists[name] {
+			continue
+		}
+		if m.Unlisted == UnlistedError {
+			return nil, errors.Errorf("%w: %s", ErrUnlisted, name)
+		}
+		fmt.Fprintln(utils.GetDebugLogger(), "Appending migration", name, "missing from manifest")
+		result = append(result, name)
+	}
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package list
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestMigrationManifest(t *testing.T) {
+	setup := func(t *testing.T, manifest string) afero.Fs {
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"1_a.sql", "2_b.sql", "3_c.sql"} {
+			path := filepath.Join(utils.MigrationsDir, name)
+			require.NoError(t, afero.WriteFile(fsys, path, []byte{}, 0644))
+		}
+		require.NoError(t, afero.WriteFile(fsys, utils.MigrationsManifestPath, []byte(manifest), 0644))
+		return fsys
+	}
+
+	t.Run("loads migrations in manifest order", func(t *testing.T) {
+		fsys := setup(t, "migrations:\n  - 3_c.sql\n  - 0_removed.sql\n  - 1_a.sql\n  - 2_b.sql\n")
+		// Run test
+		names, err := LoadOrderedMigrations("1", fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"3_c.sql", "1_a.sql"}, names)
+	})
+
+	t.Run("ignores manifest outside squash", func(t *testing.T) {
+		fsys := setup(t, "migrations:\n  - 3_c.sql\n  - 1_a.sql\n  - 2_b.sql\n")
+		// Run test
+		names, err := LoadLocalMigrations(fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1_a.sql", "2_b.sql", "3_c.sql"}, names)
+	})
+
+	t.Run("appends unlisted migrations", func(t *testing.T) {
+		fsys := setup(t, "unlisted: append\nmigrations:\n  - 2_b.sql\n")
+		// Run test
+		names, err := LoadOrderedMigrations("", fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2_b.sql", "1_a.sql", "3_c.sql"}, names)
+	})
+
+	t.Run("throws error on unlisted migration", func(t *testing.T) {
+		fsys := setup(t, "migrations:\n  - 2_b.sql\n  - 1_a.sql\n")
+		// Run test
+		_, err := LoadOrderedMigrations("", fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrUnlisted)
+		assert.ErrorContains(t, err, "3_c.sql")
+	})
+
+	t.Run("throws error on invalid policy", func(t *testing.T) {
+		fsys := setup(t, "unlisted: ignore\n")
+		// Run test
+		_, err := LoadOrderedMigrations("", fsys)
+		// Check error
+		assert.ErrorContains(t, err, "Invalid unlisted policy")
+	})
+}
@@ -0,0 +1,62 @@
+package prune
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func Run(ctx context.Context, config pgconn.Config, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	local, err := list.LoadLocalVersions(fsys)
+	if err != nil {
+		return err
+	}
+	conn, err := utils.ConnectByConfig(ctx, config, options...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	remote, err := list.LoadRemoteMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	orphans := findOrphans(remote, local)
+	if len(orphans) == 0 {
+		fmt.Fprintln(os.Stderr, "Remote migration history matches local migration files.")
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, "Found remote migration versions without a local migration file:")
+	for _, v := range orphans {
+		fmt.Fprintln(os.Stderr, "  "+utils.Bold(v))
+	}
+	if shouldDelete, err := utils.PromptDestructive("Delete these versions from the migration history table?", os.Stdin); err != nil {
+		return err
+	} else if !shouldDelete {
+		return errors.New(context.Canceled)
+	}
+	return repair.UpdateMigrationTable(ctx, conn, orphans, repair.Reverted, false, fsys)
+}
+
+// Returns remote versions that are neither a local migration nor the squashed baseline,
+// which is itself kept as a local migration file.
+func findOrphans(remote, local []string) []string {
+	exists := make(map[string]bool, len(local))
+	for _, v := range local {
+		exists[v] = true
+	}
+	var result []string
+	for _, v := range remote {
+		if !exists[v] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
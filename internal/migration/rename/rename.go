@@ -0,0 +1,69 @@
+package rename
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/history"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var (
+	ErrInvalidName = errors.New("invalid migration name")
+	namePattern    = regexp.MustCompile(`^[\w-]+$`)
+)
+
+func Run(ctx context.Context, version, name string, config pgconn.Config, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	if !namePattern.MatchString(name) {
+		return errors.Errorf("%w: %s", ErrInvalidName, name)
+	}
+	path, err := repair.GetMigrationFile(version, fsys)
+	if err != nil {
+		return err
+	}
+	renamed := filepath.Join(filepath.Dir(path), fmt.Sprintf("%s_%s.sql", version, name))
+	if renamed == path {
+		fmt.Fprintln(os.Stderr, "Migration is already named", utils.Bold(renamed))
+		return nil
+	}
+	conn, err := utils.ConnectByConfig(ctx, config, options...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	remote, err := list.LoadRemoteMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	// Remote history is updated first so that a failure leaves both sides unchanged
+	if isApplied(version, remote) {
+		if _, err := conn.Exec(ctx, history.UPDATE_MIGRATION_NAME, name, version); err != nil {
+			return errors.Errorf("failed to update migration history: %w", err)
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "Migration", utils.Bold(version), "is not applied remotely, renaming local file only.")
+	}
+	if err := fsys.Rename(path, renamed); err != nil {
+		return errors.Errorf("failed to rename migration: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Renamed migration", utils.Bold(path), "to", utils.Bold(renamed))
+	return nil
+}
+
+func isApplied(version string, remote []string) bool {
+	for _, v := range remote {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package rename
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/migration/history"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var dbConfig = pgconn.Config{
+	Host:     "db.supabase.com",
+	Port:     5432,
+	User:     "admin",
+	Password: "password",
+	Database: "postgres",
+}
+
+func TestRenameCommand(t *testing.T) {
+	path := filepath.Join(utils.MigrationsDir, "1_init.sql")
+	renamed := filepath.Join(utils.MigrationsDir, "1_create_users.sql")
+
+	t.Run("renames local file and remote history", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema a;"), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(list.LIST_MIGRATION_VERSION).
+			Reply("SELECT 2", []interface{}{"0"}, []interface{}{"1"}).
+			Query(history.UPDATE_MIGRATION_NAME, "create_users", "1").
+			Reply("UPDATE 1")
+		// Run test
+		err := Run(context.Background(), "1", "create_users", dbConfig, fsys, conn.Intercept)
+		// Check error
+		assert.NoError(t, err)
+		exists, err := afero.Exists(fsys, renamed)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("renames local only migration", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema a;"), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(list.LIST_MIGRATION_VERSION).
+			Reply("SELECT 1", []interface{}{"0"})
+		// Run test
+		err := Run(context.Background(), "1", "create_users", dbConfig, fsys, conn.Intercept)
+		// Check error
+		assert.NoError(t, err)
+		exists, err := afero.Exists(fsys, renamed)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("keeps local file on update failure", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema a;"), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(list.LIST_MIGRATION_VERSION).
+			Reply("SELECT 1", []interface{}{"1"}).
+			Query(history.UPDATE_MIGRATION_NAME, "create_users", "1").
+			ReplyError(pgerrcode.InsufficientPrivilege, "permission denied for table schema_migrations")
+		// Run test
+		err := Run(context.Background(), "1", "create_users", dbConfig, fsys, conn.Intercept)
+		// Check error
+		assert.ErrorContains(t, err, "permission denied for table schema_migrations")
+		exists, err := afero.Exists(fsys, path)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("throws error on invalid name", func(t *testing.T) {
+		// Run test
+		err := Run(context.Background(), "1", "../users", dbConfig, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, ErrInvalidName)
+	})
+
+	t.Run("throws error on missing version", func(t *testing.T) {
+		// Run test
+		err := Run(context.Background(), "1", "create_users", dbConfig, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,222 @@
+package reorder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var ErrRemoteVersion = errors.New("cannot renumber migration already applied on remote")
+
+type rename struct {
+	From string
+	To   string
+}
+
+func Run(ctx context.Context, config pgconn.Config, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	repo, err := git.PlainOpenWithOptions(".", &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return errors.Errorf("failed to open git repository: %w", err)
+	}
+	committed, err := LoadCommitOrder(repo, utils.MigrationsDir)
+	if err != nil {
+		return err
+	}
+	local, err := list.LoadLocalMigrations(fsys)
+	if err != nil {
+		return err
+	}
+	renames := planRenames(mergeOrder(committed, local))
+	if len(renames) == 0 {
+		fmt.Fprintln(os.Stderr, "Local migrations are already in order.")
+		return nil
+	}
+	conn, err := utils.ConnectByConfig(ctx, config, options...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	remote, err := list.LoadRemoteMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if err := assertNotApplied(renames, remote); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Found migrations out of order with git history:")
+	for _, r := range renames {
+		fmt.Fprintf(os.Stderr, "  %s => %s\n", utils.Bold(r.From), utils.Bold(r.To))
+	}
+	if !utils.PromptYesNo("Renumber these migration files?", true, os.Stdin) {
+		return errors.New(context.Canceled)
+	}
+	return renameMigrations(renames, fsys)
+}
+
+// Loads the names of migration files in the order they were first committed to git.
+func LoadCommitOrder(repo *git.Repository, dir string) ([]string, error) {
+	prefix, err := GetRepoPath(repo, dir)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.Log(&git.LogOptions{Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, errors.Errorf("failed to read git log: %w", err)
+	}
+	var commits []*object.Commit
+	if err := iter.ForEach(func(c *object.Commit) error {
+		commits = append(commits, c)
+		return nil
+	}); err != nil {
+		return nil, errors.Errorf("failed to iterate commits: %w", err)
+	}
+	seen := map[string]bool{}
+	var order []string
+	// Log iterates from the latest commit so we walk backwards
+	for i := len(commits) - 1; i >= 0; i-- {
+		tree, err := commits[i].Tree()
+		if err != nil {
+			return nil, errors.Errorf("failed to load commit tree: %w", err)
+		}
+		sub, err := tree.Tree(prefix)
+		if errors.Is(err, object.ErrDirectoryNotFound) {
+			continue
+		} else if err != nil {
+			return nil, errors.Errorf("failed to load migrations tree: %w", err)
+		}
+		var added []string
+		for _, entry := range sub.Entries {
+			if entry.Mode.IsFile() && !seen[entry.Name] {
+				seen[entry.Name] = true
+				added = append(added, entry.Name)
+			}
+		}
+		// Files added in the same commit are ordered by name
+		sort.Strings(added)
+		order = append(order, added...)
+	}
+	return order, nil
+}
+
+// Resolves dir to a slash separated path relative to the git worktree root.
+func GetRepoPath(repo *git.Repository, dir string) (string, error) {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", errors.Errorf("failed to load git worktree: %w", err)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", errors.Errorf("failed to resolve migrations dir: %w", err)
+	}
+	rel, err := filepath.Rel(wt.Filesystem.Root(), abs)
+	if err != nil {
+		return "", errors.Errorf("failed to resolve migrations dir: %w", err)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Keeps committed files that still exist locally, followed by uncommitted files in name order.
+func mergeOrder(committed, local []string) []string {
+	exists := make(map[string]bool, len(local))
+	for _, name := range local {
+		exists[name] = true
+	}
+	var result []string
+	for _, name := range committed {
+		if exists[name] {
+			result = append(result, name)
+			delete(exists, name)
+		}
+	}
+	for _, name := range local {
+		if exists[name] {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+func planRenames(order []string) []rename {
+	var result []rename
+	var latest string
+	for _, name := range order {
+		matches := utils.MigrateFilePattern.FindStringSubmatch(name)
+		if len(matches) < 3 {
+			continue
+		}
+		version := matches[1]
+		if len(latest) > 0 && compareVersion(version, latest) <= 0 {
+			version = nextVersion(latest)
+			result = append(result, rename{
+				From: name,
+				To:   fmt.Sprintf("%s_%s.sql", version, matches[2]),
+			})
+		}
+		latest = version
+	}
+	return result
+}
+
+func compareVersion(a, b string) int {
+	x, errX := strconv.ParseUint(a, 10, 64)
+	y, errY := strconv.ParseUint(b, 10, 64)
+	if errX != nil || errY != nil || x == y {
+		return 0
+	}
+	if x < y {
+		return -1
+	}
+	return 1
+}
+
+const layoutVersion = "20060102150405"
+
+func nextVersion(version string) string {
+	if timestamp, err := time.Parse(layoutVersion, version); err == nil {
+		return timestamp.Add(time.Second).Format(layoutVersion)
+	}
+	// Fallback for versions that are not timestamps
+	value, _ := strconv.ParseUint(version, 10, 64)
+	return strconv.FormatUint(value+1, 10)
+}
+
+func assertNotApplied(renames []rename, remote []string) error {
+	applied := make(map[string]bool, len(remote))
+	for _, v := range remote {
+		applied[v] = true
+	}
+	for _, r := range renames {
+		version := utils.MigrateFilePattern.FindStringSubmatch(r.From)[1]
+		if applied[version] {
+			return errors.Errorf("%w: %s", ErrRemoteVersion, r.From)
+		}
+	}
+	return nil
+}
+
+func renameMigrations(renames []rename, fsys afero.Fs) error {
+	// Rename from the end so that no file is overwritten by a renumbered version
+	for i := len(renames) - 1; i >= 0; i-- {
+		r := renames[i]
+		src := filepath.Join(utils.MigrationsDir, r.From)
+		dst := filepath.Join(utils.MigrationsDir, r.To)
+		if err := fsys.Rename(src, dst); err != nil {
+			return errors.Errorf("failed to rename migration: %w", err)
+		}
+		fmt.Fprintln(os.Stderr, "Renamed migration", utils.Bold(r.From), "to", utils.Bold(r.To))
+	}
+	return nil
+}
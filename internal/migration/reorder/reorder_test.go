@@ -0,0 +1,140 @@
+package reorder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestCommitOrder(t *testing.T) {
+	t.Run("loads files in commit order", func(t *testing.T) {
+		// Setup git repo
+		root := t.TempDir()
+		repo, err := git.PlainInit(root, false)
+		require.NoError(t, err)
+		wt, err := repo.Worktree()
+		require.NoError(t, err)
+		dir := filepath.Join(root, utils.MigrationsDir)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		now := time.Now()
+		for i, name := range []string{"2_second.sql", "1_first.sql"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte{}, 0644))
+			_, err = wt.Add(filepath.ToSlash(filepath.Join(utils.MigrationsDir, name)))
+			require.NoError(t, err)
+			_, err = wt.Commit("add "+name, &git.CommitOptions{Author: &object.Signature{
+				Name: "test",
+				When: now.Add(time.Duration(i) * time.Minute),
+			}})
+			require.NoError(t, err)
+		}
+		// Run test
+		order, err := LoadCommitOrder(repo, dir)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2_second.sql", "1_first.sql"}, order)
+	})
+
+	t.Run("ignores missing migrations dir", func(t *testing.T) {
+		// Setup git repo
+		root := t.TempDir()
+		repo, err := git.PlainInit(root, false)
+		require.NoError(t, err)
+		wt, err := repo.Worktree()
+		require.NoError(t, err)
+		_, err = wt.Commit("empty", &git.CommitOptions{
+			AllowEmptyCommits: true,
+			Author:            &object.Signature{Name: "test", When: time.Now()},
+		})
+		require.NoError(t, err)
+		// Run test
+		order, err := LoadCommitOrder(repo, filepath.Join(root, utils.MigrationsDir))
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, order)
+	})
+}
+
+func TestPlanRenames(t *testing.T) {
+	t.Run("renumbers out of order files", func(t *testing.T) {
+		order := mergeOrder(
+			[]string{"20230102000000_b.sql", "20230101000000_a.sql", "20230103000000_c.sql"},
+			[]string{"20230101000000_a.sql", "20230102000000_b.sql", "20230103000000_c.sql", "20230104000000_d.sql"},
+		)
+		// Run test
+		renames := planRenames(order)
+		// Check output
+		assert.Equal(t, []rename{{
+			From: "20230101000000_a.sql",
+			To:   "20230102000001_a.sql",
+		}}, renames)
+	})
+
+	t.Run("cascades renumbered versions", func(t *testing.T) {
+		renames := planRenames([]string{"2_b.sql", "1_a.sql", "3_c.sql"})
+		// Check output
+		assert.Equal(t, []rename{
+			{From: "1_a.sql", To: "3_a.sql"},
+			{From: "3_c.sql", To: "4_c.sql"},
+		}, renames)
+	})
+
+	t.Run("skips ordered files", func(t *testing.T) {
+		renames := planRenames([]string{"1_a.sql", "2_b.sql"})
+		// Check output
+		assert.Empty(t, renames)
+	})
+}
+
+func TestRenameMigrations(t *testing.T) {
+	t.Run("renames cascading files without overwrite", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"1_a.sql", "2_b.sql", "3_c.sql"} {
+			path := filepath.Join(utils.MigrationsDir, name)
+			require.NoError(t, afero.WriteFile(fsys, path, []byte(name), 0644))
+		}
+		renames := planRenames([]string{"2_b.sql", "1_a.sql", "3_c.sql"})
+		// Run test
+		err := renameMigrations(renames, fsys)
+		// Check error
+		assert.NoError(t, err)
+		local, err := list.LoadLocalMigrations(fsys)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2_b.sql", "3_a.sql", "4_c.sql"}, local)
+		contents, err := afero.ReadFile(fsys, filepath.Join(utils.MigrationsDir, "4_c.sql"))
+		assert.NoError(t, err)
+		assert.Equal(t, "3_c.sql", string(contents))
+	})
+
+	t.Run("throws error on missing file", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		err := renameMigrations([]rename{{From: "1_a.sql", To: "2_a.sql"}}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestAssertNotApplied(t *testing.T) {
+	t.Run("throws error on remote version", func(t *testing.T) {
+		err := assertNotApplied([]rename{{From: "1_a.sql", To: "3_a.sql"}}, []string{"0", "1"})
+		// Check error
+		assert.ErrorIs(t, err, ErrRemoteVersion)
+	})
+
+	t.Run("allows local only version", func(t *testing.T) {
+		err := assertNotApplied([]rename{{From: "1_a.sql", To: "3_a.sql"}}, []string{"0"})
+		// Check error
+		assert.NoError(t, err)
+	})
+}
@@ -36,7 +36,9 @@ func Run(ctx context.Context, config pgconn.Config, version []string, status str
 	repairAll := len(version) == 0
 	if repairAll {
 		msg := "Do you want to repair the entire migration history table to match local migration files?"
-		if shouldRepair := utils.PromptYesNo(msg, false, os.Stdin); !shouldRepair {
+		if shouldRepair, err := utils.PromptDestructive(msg, os.Stdin); err != nil {
+			return err
+		} else if !shouldRepair {
 			utils.CmdSuggestion = ""
 			return errors.New(context.Canceled)
 		}
@@ -102,6 +104,26 @@ func GetMigrationFile(version string, fsys afero.Fs) (string, error) {
 	return matches[0], nil
 }
 
+// Returns the path to the down migration generated for a squashed migration.
+func GetDownPath(version string) string {
+	return filepath.Join(utils.MigrationsDir, version+".down.sql")
+}
+
+// Returns the path to the pg_dump custom format archive restored before the migration file.
+func GetCustomDumpPath(version string) string {
+	return filepath.Join(utils.MigrationsDir, version+".dump")
+}
+
+// Returns the path to the sha256sum of a squashed migration.
+func GetChecksumPath(version string) string {
+	return filepath.Join(utils.MigrationsDir, version+".sql.sha256")
+}
+
+// Returns the path to the detached gpg signature of a squashed migration.
+func GetSignaturePath(version string) string {
+	return filepath.Join(utils.MigrationsDir, version+".sql.asc")
+}
+
 type MigrationFile struct {
 	Lines   []string
 	Version string
@@ -176,6 +198,27 @@ func (m *MigrationFile) ExecBatch(ctx context.Context, conn *pgx.Conn) error {
 	return nil
 }
 
+// Executes each statement separately so that failures are collected instead of aborting the migration.
+func (m *MigrationFile) ExecEach(ctx context.Context, conn *pgx.Conn) []error {
+	var errs []error
+	for i, line := range m.Lines {
+		if _, err := conn.PgConn().ExecParams(ctx, line, nil, nil, nil, nil).Close(); err != nil {
+			errs = append(errs, errors.Errorf("%w\nAt statement %d: %s", err, i, line))
+		}
+	}
+	// Record the version even on failure so that replaying the remaining migrations is consistent
+	if len(m.Version) > 0 {
+		batch := &pgconn.Batch{}
+		if err := m.insertVersionSQL(conn, batch); err != nil {
+			return append(errs, err)
+		}
+		if _, err := conn.PgConn().ExecBatch(ctx, batch).ReadAll(); err != nil {
+			errs = append(errs, errors.Errorf("failed to update migration history: %w", err))
+		}
+	}
+	return errs
+}
+
 func (m *MigrationFile) insertVersionSQL(conn *pgx.Conn, batch *pgconn.Batch) error {
 	value := pgtype.TextArray{}
 	if err := value.Set(m.Lines); err != nil {
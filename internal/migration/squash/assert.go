@@ -0,0 +1,52 @@
+package squash
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/utils"
+	"github.com/supabase/cli/internal/utils/parser"
+)
+
+var (
+	ErrAssertion = errors.New("schema assertion failed")
+
+	doBlockPattern = regexp.MustCompile(`(?i)^DO\b`)
+)
+
+// Runs the optional schema contract in assertions.sql against the migrated shadow database.
+// DO blocks must raise an exception on failure while other queries must return true.
+func runAssertions(ctx context.Context, conn *pgx.Conn, fsys afero.Fs) error {
+	f, err := fsys.Open(utils.MigrationAssertionsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return errors.Errorf("failed to open assertions: %w", err)
+	}
+	defer f.Close()
+	stats, err := parser.SplitAndTrim(f)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Running schema assertions "+utils.Bold(utils.MigrationAssertionsPath)+"...")
+	for i, sql := range stats {
+		if doBlockPattern.MatchString(sql) {
+			if _, err := conn.Exec(ctx, sql); err != nil {
+				return errors.Errorf("%w: %v\nAt statement %d: %s", ErrAssertion, err, i, sql)
+			}
+			continue
+		}
+		var ok bool
+		if err := conn.QueryRow(ctx, sql).Scan(&ok); err != nil {
+			return errors.Errorf("failed to run assertion: %w\nAt statement %d: %s", err, i, sql)
+		} else if !ok {
+			return errors.Errorf("%w\nAt statement %d: %s", ErrAssertion, i, sql)
+		}
+	}
+	return nil
+}
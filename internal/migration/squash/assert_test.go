@@ -0,0 +1,66 @@
+package squash
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestRunAssertions(t *testing.T) {
+	assertion := "DO $$ BEGIN IF NOT EXISTS (SELECT FROM pg_tables WHERE tablename = 'todos') THEN RAISE EXCEPTION 'missing todos'; END IF; END $$"
+
+	t.Run("skips missing assertions", func(t *testing.T) {
+		// Run test
+		err := runAssertions(context.Background(), nil, afero.NewMemMapFs())
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("runs do block assertions", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, utils.MigrationAssertionsPath, []byte(assertion+";"), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(assertion).
+			Reply("DO")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = runAssertions(ctx, mock, fsys)
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on raised exception", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, utils.MigrationAssertionsPath, []byte(assertion+";"), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(assertion).
+			ReplyError(pgerrcode.RaiseException, "missing todos")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = runAssertions(ctx, mock, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrAssertion)
+		assert.ErrorContains(t, err, "missing todos")
+	})
+}
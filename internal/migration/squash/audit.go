@@ -0,0 +1,164 @@
+package squash
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/utils"
+)
+
+const (
+	auditCreate    = "create"
+	auditOverwrite = "overwrite"
+	auditDelete    = "delete"
+	auditRename    = "rename"
+
+	auditBackupDir = "squash_backups"
+)
+
+type auditEntry struct {
+	RunId     string     `json:"run_id,omitempty"`
+	Operation string     `json:"operation"`
+	Path      string     `json:"path"`
+	Source    string     `json:"source,omitempty"`
+	Bytes     int64      `json:"bytes"`
+	Timestamp time.Time  `json:"timestamp"`
+	Backup    string     `json:"backup,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Restored  *time.Time `json:"restored,omitempty"`
+}
+
+// Groups the entries of a single squash so that restore only undoes the latest run.
+var auditRunId string
+
+func startAuditRun() {
+	var id [4]byte
+	_, _ = rand.Read(id[:])
+	auditRunId = time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(id[:])
+}
+
+// Appends an entry to the audit log at db.squash.audit_path for every file squash
+// creates, overwrites or deletes. Each entry is flushed before the next operation
+// so that a squash failing midway still leaves a record of what it changed.
+// Original contents are backed up next to the log so the squash can be restored.
+type auditLog struct {
+	path string
+	fsys afero.Fs
+}
+
+func newAuditLog(fsys afero.Fs) *auditLog {
+	path := utils.Config.Db.Squash.AuditPath
+	if len(path) == 0 {
+		return nil
+	}
+	return &auditLog{path: path, fsys: fsys}
+}
+
+// Writes contents to path, recording whether an existing file was overwritten.
+func (a *auditLog) writeFile(path string, contents []byte, fsys afero.Fs) error {
+	backup, err := a.backup(path, fsys)
+	if err != nil {
+		return err
+	}
+	op := auditCreate
+	if exists, _ := afero.Exists(fsys, path); exists {
+		op = auditOverwrite
+	}
+	err = utils.WriteFile(path, contents, fsys)
+	a.record(auditEntry{Operation: op, Path: path, Bytes: int64(len(contents)), Backup: backup}, err)
+	return err
+}
+
+// Removes path, recording the size of the deleted file.
+func (a *auditLog) remove(path string, fsys afero.Fs) error {
+	backup, err := a.backup(path, fsys)
+	if err != nil {
+		return err
+	}
+	var size int64
+	if info, err := fsys.Stat(path); err == nil {
+		size = info.Size()
+	}
+	err = fsys.Remove(path)
+	// Files that never existed were not deleted so they are left out of the log
+	if !errors.Is(err, os.ErrNotExist) {
+		a.record(auditEntry{Operation: auditDelete, Path: path, Bytes: size, Backup: backup}, err)
+	}
+	return err
+}
+
+// Renames source to path, recording the original name so that the rename can be undone.
+func (a *auditLog) rename(source, path string, fsys afero.Fs) error {
+	backup, err := a.backup(path, fsys)
+	if err != nil {
+		return err
+	}
+	var size int64
+	if info, err := fsys.Stat(source); err == nil {
+		size = info.Size()
+	}
+	err = fsys.Rename(source, path)
+	a.record(auditEntry{Operation: auditRename, Path: path, Source: source, Bytes: size, Backup: backup}, err)
+	return err
+}
+
+// Copies the current contents of path to a backup named by their hash, returning
+// an empty path when the file does not exist.
+func (a *auditLog) backup(path string, fsys afero.Fs) (string, error) {
+	if a == nil {
+		return "", nil
+	}
+	contents, err := afero.ReadFile(fsys, path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Errorf("failed to read file for backup: %w", err)
+	}
+	hash := sha256.Sum256(contents)
+	backup := filepath.Join(filepath.Dir(a.path), auditBackupDir, hex.EncodeToString(hash[:]))
+	if err := utils.WriteFile(backup, contents, a.fsys); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+func (a *auditLog) record(entry auditEntry, opErr error) {
+	if a == nil {
+		return
+	}
+	entry.RunId = auditRunId
+	entry.Timestamp = time.Now().UTC()
+	if opErr != nil {
+		entry.Error = opErr.Error()
+	}
+	if err := a.append(entry); err != nil {
+		fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "failed to write audit log:", err)
+	}
+}
+
+func (a *auditLog) append(entry auditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Errorf("failed to encode audit entry: %w", err)
+	}
+	if err := utils.MkdirIfNotExistFS(a.fsys, filepath.Dir(a.path)); err != nil {
+		return err
+	}
+	f, err := a.fsys.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return errors.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
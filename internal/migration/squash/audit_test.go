@@ -0,0 +1,132 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func readAudit(t *testing.T, path string, fsys afero.Fs) []auditEntry {
+	contents, err := afero.ReadFile(fsys, path)
+	require.NoError(t, err)
+	var entries []auditEntry
+	for _, line := range bytes.Split(bytes.TrimSpace(contents), []byte("\n")) {
+		var entry auditEntry
+		require.NoError(t, json.Unmarshal(line, &entry))
+		assert.False(t, entry.Timestamp.IsZero())
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAuditLog(t *testing.T) {
+	auditPath := filepath.Join(utils.SupabaseDirPath, "squash_audit.jsonl")
+	utils.Config.Db.Squash.AuditPath = auditPath
+	defer func() { utils.Config.Db.Squash.AuditPath = "" }()
+
+	t.Run("records squash file mutations", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema a;"), 0644))
+		path = filepath.Join(utils.MigrationsDir, "1_target.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema b;"), 0644))
+		// Run test
+		_, err := squashToVersion(context.Background(), "1", RunParams{Textual: true}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		entries := readAudit(t, auditPath, fsys)
+		require.Len(t, entries, 2)
+		assert.Equal(t, auditOverwrite, entries[0].Operation)
+		assert.Equal(t, path, entries[0].Path)
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(contents)), entries[0].Bytes)
+		assert.Equal(t, auditEntry{
+			RunId:     entries[1].RunId,
+			Operation: auditDelete,
+			Path:      filepath.Join(utils.MigrationsDir, "0_init.sql"),
+			Bytes:     16,
+			Timestamp: entries[1].Timestamp,
+			Backup:    filepath.Join(utils.SupabaseDirPath, auditBackupDir, "1adc2b78505275f1f7b59039527a674c0c48e2ed02450f081f7a15cb9b5536d0"),
+		}, entries[1])
+	})
+
+	t.Run("records renames, checksums and changelog", func(t *testing.T) {
+		changelog := filepath.Join(utils.SupabaseDirPath, "CHANGELOG.md")
+		utils.Config.Db.Squash.ChangelogPath = changelog
+		defer func() { utils.Config.Db.Squash.ChangelogPath = "" }()
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "0_init.sql"), []byte("create schema a;"), 0644))
+		path := filepath.Join(utils.MigrationsDir, "1_target.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema b;"), 0644))
+		require.NoError(t, afero.WriteFile(fsys, changelog, []byte("# Changelog\n"), 0644))
+		// Run test
+		_, err := squashToVersion(context.Background(), "1", RunParams{Textual: true, Rename: "baseline", Checksum: true}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		entries := readAudit(t, auditPath, fsys)
+		require.Len(t, entries, 5)
+		renamed := filepath.Join(utils.MigrationsDir, "1_baseline.sql")
+		assert.Equal(t, auditRename, entries[1].Operation)
+		assert.Equal(t, path, entries[1].Source)
+		assert.Equal(t, renamed, entries[1].Path)
+		assert.Equal(t, auditCreate, entries[2].Operation)
+		assert.Equal(t, repair.GetChecksumPath("1"), entries[2].Path)
+		assert.Equal(t, auditOverwrite, entries[3].Operation)
+		assert.Equal(t, changelog, entries[3].Path)
+		assert.NotEmpty(t, entries[3].Backup)
+		assert.Equal(t, auditDelete, entries[4].Operation)
+	})
+
+	t.Run("records failed operations", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		// Run test
+		err := newAuditLog(fsys).writeFile(path, []byte("create schema a;"), afero.NewReadOnlyFs(fsys))
+		// Check error
+		assert.ErrorIs(t, err, os.ErrPermission)
+		entries := readAudit(t, auditPath, fsys)
+		require.Len(t, entries, 1)
+		assert.Equal(t, auditCreate, entries[0].Operation)
+		assert.NotEmpty(t, entries[0].Error)
+	})
+
+	t.Run("skips missing files", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		err := newAuditLog(fsys).remove(filepath.Join(utils.MigrationsDir, "0_init.sql"), fsys)
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		exists, err := afero.Exists(fsys, auditPath)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("ignores unset audit path", func(t *testing.T) {
+		utils.Config.Db.Squash.AuditPath = ""
+		defer func() { utils.Config.Db.Squash.AuditPath = auditPath }()
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		// Run test
+		err := newAuditLog(fsys).writeFile(path, []byte("create schema a;"), fsys)
+		// Check error
+		assert.NoError(t, err)
+		exists, err := afero.Exists(fsys, auditPath)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
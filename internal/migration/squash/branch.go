@@ -0,0 +1,120 @@
+package squash
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/go-errors/errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/history"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/migration/reorder"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var ErrInterleaved = errors.New("branch migration is older than a base migration")
+
+// Loads local migrations up to version that are not committed to the base git branch.
+func loadBranchMigrations(version, base string, fsys afero.Fs) ([]string, error) {
+	migrations, err := list.LoadOrderedMigrations(version, fsys)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := git.PlainOpenWithOptions(".", &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, errors.Errorf("failed to open git repository: %w", err)
+	}
+	committed, err := listBaseMigrations(repo, base, utils.MigrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	return filterBranchMigrations(migrations, committed)
+}
+
+func listBaseMigrations(repo *git.Repository, base, dir string) (map[string]bool, error) {
+	prefix, err := reorder.GetRepoPath(repo, dir)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(base))
+	if err != nil {
+		return nil, errors.Errorf("failed to resolve base branch %s: %w", base, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, errors.Errorf("failed to load base commit: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, errors.Errorf("failed to load commit tree: %w", err)
+	}
+	committed := map[string]bool{}
+	sub, err := tree.Tree(prefix)
+	if errors.Is(err, object.ErrDirectoryNotFound) {
+		return committed, nil
+	} else if err != nil {
+		return nil, errors.Errorf("failed to load migrations tree: %w", err)
+	}
+	for _, entry := range sub.Entries {
+		if entry.Mode.IsFile() {
+			committed[entry.Name] = true
+		}
+	}
+	return committed, nil
+}
+
+// Branch migrations must come after all base migrations, otherwise merging them would reorder the history.
+func filterBranchMigrations(migrations []string, committed map[string]bool) ([]string, error) {
+	var result []string
+	for _, name := range migrations {
+		if !committed[name] {
+			result = append(result, name)
+		} else if len(result) > 0 {
+			return nil, errors.Errorf("%w: %s", ErrInterleaved, result[len(result)-1])
+		}
+	}
+	return result, nil
+}
+
+// Replaces the history rows of migrations merged from a window with the squashed one, keeping earlier rows.
+func baselineWindow(ctx context.Context, config pgconn.Config, window []string, role string, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	var versions []string
+	for _, name := range window {
+		versions = append(versions, utils.MigrateFilePattern.FindStringSubmatch(name)[1])
+	}
+	version := versions[len(versions)-1]
+	fmt.Fprintln(os.Stderr, "Replacing squashed migration history with", version)
+	if !utils.IsLocalDatabase(config) {
+		options = withRemoteOptions(options)
+	}
+	conn, err := utils.ConnectByConfig(ctx, config, options...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if err := setRole(ctx, conn, role); err != nil {
+		return err
+	}
+	if err := history.CreateMigrationTable(ctx, conn); err != nil {
+		return err
+	}
+	m, err := repair.NewMigrationFromVersion(version, fsys)
+	if err != nil {
+		return err
+	}
+	batch := pgx.Batch{}
+	batch.Queue(history.DELETE_MIGRATION_VERSION, versions)
+	batch.Queue(history.INSERT_MIGRATION_VERSION, m.Version, m.Name, m.Lines)
+	setup, err := queueRelease(&batch, m.Version, fsys)
+	if err != nil {
+		return err
+	}
+	return replaceHistory(ctx, conn, setup, &batch)
+}
@@ -0,0 +1,104 @@
+package squash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestBaseMigrations(t *testing.T) {
+	t.Run("lists migrations on base branch", func(t *testing.T) {
+		// Setup git repo
+		root := t.TempDir()
+		repo, err := git.PlainInit(root, false)
+		require.NoError(t, err)
+		wt, err := repo.Worktree()
+		require.NoError(t, err)
+		dir := filepath.Join(root, utils.MigrationsDir)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		var hashes []plumbing.Hash
+		for _, name := range []string{"1_base.sql", "2_branch.sql"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte{}, 0644))
+			_, err = wt.Add(filepath.ToSlash(filepath.Join(utils.MigrationsDir, name)))
+			require.NoError(t, err)
+			hash, err := wt.Commit("add "+name, &git.CommitOptions{Author: &object.Signature{
+				Name: "test",
+				When: time.Now(),
+			}})
+			require.NoError(t, err)
+			hashes = append(hashes, hash)
+		}
+		ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName("main"), hashes[0])
+		require.NoError(t, repo.Storer.SetReference(ref))
+		// Run test
+		committed, err := listBaseMigrations(repo, "main", dir)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]bool{"1_base.sql": true}, committed)
+	})
+
+	t.Run("throws error on missing branch", func(t *testing.T) {
+		// Setup git repo
+		root := t.TempDir()
+		repo, err := git.PlainInit(root, false)
+		require.NoError(t, err)
+		// Run test
+		_, err = listBaseMigrations(repo, "main", filepath.Join(root, utils.MigrationsDir))
+		// Check error
+		assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+	})
+}
+
+func TestFilterBranch(t *testing.T) {
+	committed := map[string]bool{"0_init.sql": true, "1_base.sql": true}
+
+	t.Run("keeps migrations added on branch", func(t *testing.T) {
+		// Run test
+		branch, err := filterBranchMigrations([]string{"0_init.sql", "1_base.sql", "2_a.sql", "3_b.sql"}, committed)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2_a.sql", "3_b.sql"}, branch)
+	})
+
+	t.Run("throws error on interleaved migrations", func(t *testing.T) {
+		// Run test
+		_, err := filterBranchMigrations([]string{"0_init.sql", "0_a.sql", "1_base.sql"}, committed)
+		// Check error
+		assert.ErrorIs(t, err, ErrInterleaved)
+	})
+}
+
+func TestBaselineWindow(t *testing.T) {
+	t.Run("replaces merged versions only", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "3_b.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema b"), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		pgtest.MockMigrationHistory(conn)
+		conn.Query("begin").Reply("BEGIN")
+		conn.Query("DELETE FROM supabase_migrations.schema_migrations WHERE version = ANY( '{2,3}' );INSERT INTO supabase_migrations.schema_migrations(version, name, statements) VALUES( '3' ,  'b' ,  '{create schema b}' )").
+			Reply("INSERT 0 1").
+			Query("commit").Reply("COMMIT")
+		// Run test
+		err := baselineWindow(context.Background(), dbConfig, []string{"2_a.sql", "3_b.sql"}, "", fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
+			cc.PreferSimpleProtocol = true
+		})
+		// Check error
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,104 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/db/dump"
+	"github.com/supabase/cli/internal/migration/apply"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var (
+	ErrCacheManaged = errors.New("cache cannot be used with managed schema diff")
+	ErrCacheSince   = errors.New("cache cannot be used with since filter")
+
+	cacheDir = filepath.Join(utils.TempDir, "squash-cache")
+)
+
+// Hashes the shadow database config, role, custom roles, and the names and contents of
+// migrations so that changing any of them produces a different cache key.
+func prefixKey(migrations []string, role string, fsys afero.Fs) (string, error) {
+	hash := sha256.New()
+	db := utils.Config.Db
+	fmt.Fprintf(hash, "%s\x00%d\x00%s\x00%s\x00", db.Image, db.MajorVersion, db.Name, role)
+	fmt.Fprintf(hash, "%s\x00%s\x00%s\x00%s\x00", db.Shadow.Encoding, db.Shadow.LcCollate, db.Shadow.LcCtype, strings.Join(db.Shadow.SearchPath, ","))
+	roles, err := afero.ReadFile(fsys, utils.CustomRolesPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", errors.Errorf("failed to read custom roles: %w", err)
+	}
+	fmt.Fprintf(hash, "%d\x00", len(roles))
+	hash.Write(roles)
+	for _, name := range migrations {
+		contents, err := afero.ReadFile(fsys, filepath.Join(utils.MigrationsDir, name))
+		if err != nil {
+			return "", errors.Errorf("failed to read migration file: %w", err)
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", name, len(contents))
+		hash.Write(contents)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func getCachePath(key string) string {
+	return filepath.Join(cacheDir, key+".dump")
+}
+
+// Restores all but the last migration from a cached dump when their hash matches,
+// otherwise applies them and caches the resulting schema before applying the last.
+func migrateWithCache(ctx context.Context, conn *pgx.Conn, config pgconn.Config, migrations []string, role string, profile *apply.Profile, continueOnError bool, fsys afero.Fs) error {
+	prefix, last := migrations[:len(migrations)-1], migrations[len(migrations)-1:]
+	// Cached dumps are schema only, so rows inserted by earlier migrations would be lost
+	if data, err := collectData(prefix, fsys); err != nil {
+		return err
+	} else if len(data) > 0 {
+		fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "skipped squash cache because earlier migrations insert data.")
+		return apply.MigrateUpWithProfile(ctx, conn, migrations, profile, continueOnError, fsys)
+	}
+	key, err := prefixKey(prefix, role, fsys)
+	if err != nil {
+		return err
+	}
+	path := getCachePath(key)
+	if exists, err := afero.Exists(fsys, path); err != nil {
+		return errors.Errorf("failed to check squash cache: %w", err)
+	} else if exists {
+		fmt.Fprintln(os.Stderr, "Restoring", len(prefix), "migrations from squash cache...")
+		if err := apply.RestoreDump(ctx, config, path, fsys); err != nil {
+			return err
+		}
+	} else {
+		if err := apply.MigrateUpWithProfile(ctx, conn, prefix, profile, continueOnError, fsys); err != nil {
+			return err
+		}
+		writeCache(ctx, config, path, fsys)
+	}
+	return apply.MigrateUpWithProfile(ctx, conn, last, profile, continueOnError, fsys)
+}
+
+// Caching is best effort so failures only print a warning. Cache files are shared across runs,
+// so they are written outside the audit log to keep restore from removing them.
+func writeCache(ctx context.Context, config pgconn.Config, path string, fsys afero.Fs) {
+	var out bytes.Buffer
+	if err := dump.DumpSchemaCustom(ctx, config, nil, &out); err != nil {
+		fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "skipped writing squash cache:", err)
+		return
+	}
+	if err := utils.WriteFile(path, out.Bytes(), fsys); err != nil {
+		fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "skipped writing squash cache:", err)
+		// Partial dumps must not be restored by the next squash
+		if err := fsys.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package squash
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/migration/history"
+	"github.com/supabase/cli/internal/testing/apitest"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestPrefixKey(t *testing.T) {
+	t.Run("changes on earlier file edit", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema a;"), 0644))
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "1_b.sql"), []byte("create schema b;"), 0644))
+		// Run test
+		key, err := prefixKey([]string{"0_init.sql", "1_b.sql"}, "", fsys)
+		require.NoError(t, err)
+		same, err := prefixKey([]string{"0_init.sql", "1_b.sql"}, "", fsys)
+		require.NoError(t, err)
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema c;"), 0644))
+		edited, err := prefixKey([]string{"0_init.sql", "1_b.sql"}, "", fsys)
+		require.NoError(t, err)
+		// Check output
+		assert.Equal(t, key, same)
+		assert.NotEqual(t, key, edited)
+	})
+
+	t.Run("changes on config, role or custom roles", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "0_init.sql"), []byte("create schema a;"), 0644))
+		key, err := prefixKey([]string{"0_init.sql"}, "", fsys)
+		require.NoError(t, err)
+		// Run test
+		role, err := prefixKey([]string{"0_init.sql"}, "app_owner", fsys)
+		require.NoError(t, err)
+		original := utils.Config.Db.Shadow.SearchPath
+		utils.Config.Db.Shadow.SearchPath = []string{"app", "public"}
+		searchPath, err := prefixKey([]string{"0_init.sql"}, "", fsys)
+		utils.Config.Db.Shadow.SearchPath = original
+		require.NoError(t, err)
+		shadow := utils.Config.Db.Shadow
+		utils.Config.Db.Shadow.LcCollate = "en_US.UTF-8"
+		locale, err := prefixKey([]string{"0_init.sql"}, "", fsys)
+		utils.Config.Db.Shadow = shadow
+		require.NoError(t, err)
+		name := utils.Config.Db.Name
+		utils.Config.Db.Name = "app"
+		database, err := prefixKey([]string{"0_init.sql"}, "", fsys)
+		utils.Config.Db.Name = name
+		require.NoError(t, err)
+		require.NoError(t, afero.WriteFile(fsys, utils.CustomRolesPath, []byte("create role app_owner;"), 0644))
+		roles, err := prefixKey([]string{"0_init.sql"}, "", fsys)
+		require.NoError(t, err)
+		// Check output
+		assert.NotEqual(t, key, role)
+		assert.NotEqual(t, key, searchPath)
+		assert.NotEqual(t, key, locale)
+		assert.NotEqual(t, key, database)
+		assert.NotEqual(t, key, roles)
+	})
+
+	t.Run("throws error on missing file", func(t *testing.T) {
+		// Run test
+		_, err := prefixKey([]string{"0_init.sql"}, "", afero.NewMemMapFs())
+		// Check error
+		assert.ErrorContains(t, err, "failed to read migration file")
+	})
+}
+
+func TestMigrateWithCache(t *testing.T) {
+	prefix := "create schema a"
+	last := "create schema b"
+
+	setup := func(t *testing.T) afero.Fs {
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "0_init.sql"), []byte(prefix), 0644))
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "1_last.sql"), []byte(last), 0644))
+		return fsys
+	}
+
+	t.Run("restores earlier migrations from cache", func(t *testing.T) {
+		fsys := setup(t)
+		key, err := prefixKey([]string{"0_init.sql"}, "", fsys)
+		require.NoError(t, err)
+		require.NoError(t, afero.WriteFile(fsys, getCachePath(key), []byte("PGDMP"), 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Config.Db.Image), "test-restore")
+		gock.New(utils.Docker.DaemonHost()).
+			Delete("/v" + utils.Docker.ClientVersion() + "/containers/test-restore").
+			Reply(http.StatusOK)
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = migrateWithCache(ctx, mock, dbConfig, []string{"0_init.sql", "1_last.sql"}, "", nil, false, fsys)
+		// Check error
+		assert.ErrorContains(t, err, "failed to attach docker container")
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("skips cache when earlier migrations insert data", func(t *testing.T) {
+		fsys := afero.NewMemMapFs()
+		data := "insert into a.t values (1)"
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "0_init.sql"), []byte(data), 0644))
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "1_last.sql"), []byte(last), 0644))
+		key, err := prefixKey([]string{"0_init.sql"}, "", fsys)
+		require.NoError(t, err)
+		require.NoError(t, afero.WriteFile(fsys, getCachePath(key), []byte("PGDMP"), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		pgtest.MockMigrationHistory(conn)
+		conn.Query(data).
+			Reply("INSERT 0 1").
+			Query(history.INSERT_MIGRATION_VERSION, "0", "init", []string{data}).
+			Reply("INSERT 0 1").
+			Query(last).
+			Reply("CREATE SCHEMA").
+			Query(history.INSERT_MIGRATION_VERSION, "1", "last", []string{last}).
+			Reply("INSERT 0 1")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = migrateWithCache(ctx, mock, dbConfig, []string{"0_init.sql", "1_last.sql"}, "", nil, false, fsys)
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("applies all migrations on cache miss", func(t *testing.T) {
+		fsys := setup(t)
+		// Setup mock docker without a dump container
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		pgtest.MockMigrationHistory(conn)
+		conn.Query(prefix).
+			Reply("CREATE SCHEMA").
+			Query(history.INSERT_MIGRATION_VERSION, "0", "init", []string{prefix}).
+			Reply("INSERT 0 1")
+		pgtest.MockMigrationHistory(conn)
+		conn.Query(last).
+			Reply("CREATE SCHEMA").
+			Query(history.INSERT_MIGRATION_VERSION, "1", "last", []string{last}).
+			Reply("INSERT 0 1")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = migrateWithCache(ctx, mock, dbConfig, []string{"0_init.sql", "1_last.sql"}, "", nil, false, fsys)
+		// Check error
+		assert.NoError(t, err)
+		// Failed dumps are not cached
+		key, err := prefixKey([]string{"0_init.sql"}, "", fsys)
+		require.NoError(t, err)
+		exists, err := afero.Exists(fsys, getCachePath(key))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestWriteCache(t *testing.T) {
+	t.Run("writes cache outside audit log", func(t *testing.T) {
+		utils.Config.Db.Squash.AuditPath = filepath.Join(utils.TempDir, "squash-audit.log")
+		defer func() { utils.Config.Db.Squash.AuditPath = "" }()
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := getCachePath("test")
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-db")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-db", "PGDMP"))
+		// Run test
+		writeCache(context.Background(), dbConfig, path, fsys)
+		// Check output
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		assert.Equal(t, "PGDMP", string(contents))
+		exists, err := afero.Exists(fsys, utils.Config.Db.Squash.AuditPath)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
@@ -0,0 +1,36 @@
+package squash
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/utils"
+)
+
+// Appends an entry listing the merged migrations to the changelog, so their
+// versions and names remain readable after the files are removed.
+func writeChangelog(path string, merged []string, target string, now time.Time, fsys afero.Fs) error {
+	var entry bytes.Buffer
+	fmt.Fprintf(&entry, "## %s\n\nSquashed into `%s`:\n\n", now.UTC().Format(time.RFC3339), filepath.Base(target))
+	for _, name := range merged {
+		if matches := utils.MigrateFilePattern.FindStringSubmatch(name); len(matches) > 2 {
+			fmt.Fprintf(&entry, "- %s %s\n", matches[1], matches[2])
+		}
+	}
+	entry.WriteByte('\n')
+	// Rewritten instead of appended so that the audit log backs up the previous changelog
+	existing, err := afero.ReadFile(fsys, path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Errorf("failed to read changelog: %w", err)
+	}
+	if err := newAuditLog(fsys).writeFile(path, append(existing, entry.Bytes()...), fsys); err != nil {
+		return errors.Errorf("failed to write changelog: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Appended squash entry to", utils.Bold(path))
+	return nil
+}
@@ -0,0 +1,41 @@
+package squash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestWriteChangelog(t *testing.T) {
+	path := filepath.Join(utils.MigrationsDir, "SQUASH_LOG.md")
+	target := filepath.Join(utils.MigrationsDir, "20240103000000_target.sql")
+	now := time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	t.Run("appends entry per squash", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		assert.NoError(t, writeChangelog(path, []string{"20240101000000_init.sql"}, target, now, fsys))
+		err := writeChangelog(path, []string{"20240102000000_add_todos.sql"}, target, now.Add(time.Hour), fsys)
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		assert.Equal(t, "## 2024-01-04T00:00:00Z\n\nSquashed into `20240103000000_target.sql`:\n\n- 20240101000000 init\n\n"+
+			"## 2024-01-04T01:00:00Z\n\nSquashed into `20240103000000_target.sql`:\n\n- 20240102000000 add_todos\n\n", string(contents))
+	})
+
+	t.Run("throws error on permission denied", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewReadOnlyFs(afero.NewMemMapFs())
+		// Run test
+		err := writeChangelog(path, []string{"20240101000000_init.sql"}, target, now, fsys)
+		// Check error
+		assert.ErrorIs(t, err, os.ErrPermission)
+	})
+}
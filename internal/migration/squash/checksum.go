@@ -0,0 +1,75 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+// Writes the SHA-256 digest of the squashed migration to <version>.sql.sha256 in sha256sum format.
+func writeChecksum(path string, fsys afero.Fs) error {
+	contents, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return errors.Errorf("failed to read migration file: %w", err)
+	}
+	digest := sha256.Sum256(contents)
+	line := fmt.Sprintf("%s  %s\n", hex.EncodeToString(digest[:]), filepath.Base(path))
+	checksumPath := repair.GetChecksumPath(getVersion(path))
+	if err := newAuditLog(fsys).writeFile(checksumPath, []byte(line), fsys); err != nil {
+		return errors.Errorf("failed to write checksum file: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Wrote checksum to", utils.Bold(checksumPath))
+	return nil
+}
+
+// Sidecar files are named by version so that they still match after the migration is renamed.
+func getVersion(path string) string {
+	name := filepath.Base(path)
+	if matches := utils.MigrateFilePattern.FindStringSubmatch(name); len(matches) > 1 {
+		return matches[1]
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// Signs the squashed migration with gpg, printing a warning instead of failing on error.
+func signMigration(ctx context.Context, path, key string, fsys afero.Fs) {
+	if err := gpgSign(ctx, path, key, fsys); err != nil {
+		fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "skipped signing migration:", err)
+	}
+}
+
+func gpgSign(ctx context.Context, path, key string, fsys afero.Fs) error {
+	gpg, err := exec.LookPath("gpg")
+	if err != nil {
+		return errors.Errorf("failed to find gpg: %w", err)
+	}
+	contents, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return errors.Errorf("failed to read migration file: %w", err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, gpg, "--batch", "--yes", "--armor", "--local-user", key, "--detach-sign")
+	cmd.Stdin = bytes.NewReader(contents)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Errorf("failed to sign with key %s: %w\n%s", key, err, stderr.String())
+	}
+	signaturePath := repair.GetSignaturePath(getVersion(path))
+	if err := newAuditLog(fsys).writeFile(signaturePath, stdout.Bytes(), fsys); err != nil {
+		return errors.Errorf("failed to write signature file: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Wrote signature to", utils.Bold(signaturePath))
+	return nil
+}
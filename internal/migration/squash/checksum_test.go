@@ -0,0 +1,89 @@
+package squash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestWriteChecksum(t *testing.T) {
+	t.Run("writes sha256sum of migration", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("hello"), 0644))
+		// Run test
+		err := writeChecksum(path, fsys)
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, filepath.Join(utils.MigrationsDir, "0.sql.sha256"))
+		assert.NoError(t, err)
+		assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824  0_init.sql\n", string(contents))
+	})
+
+	t.Run("throws error on missing file", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		err := writeChecksum(filepath.Join(utils.MigrationsDir, "0_init.sql"), fsys)
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("throws error on permission denied", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("hello"), 0644))
+		// Run test
+		err := writeChecksum(path, afero.NewReadOnlyFs(fsys))
+		// Check error
+		assert.ErrorIs(t, err, os.ErrPermission)
+	})
+
+	t.Run("removes sidecars of merged migrations", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema a;"), 0644))
+		require.NoError(t, afero.WriteFile(fsys, repair.GetChecksumPath("0"), []byte("hash  0_init.sql\n"), 0644))
+		require.NoError(t, afero.WriteFile(fsys, repair.GetSignaturePath("0"), []byte("signature"), 0644))
+		path = filepath.Join(utils.MigrationsDir, "1_target.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create table a.t();"), 0644))
+		// Run test
+		_, err := squashToVersion(context.Background(), "1", RunParams{Textual: true, Checksum: true}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		for _, sidecar := range []string{repair.GetChecksumPath("0"), repair.GetSignaturePath("0")} {
+			exists, err := afero.Exists(fsys, sidecar)
+			assert.NoError(t, err)
+			assert.False(t, exists)
+		}
+		exists, err := afero.Exists(fsys, repair.GetChecksumPath("1"))
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+}
+
+func TestSignMigration(t *testing.T) {
+	t.Run("skips signing on missing gpg", func(t *testing.T) {
+		t.Setenv("PATH", "")
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("hello"), 0644))
+		// Run test
+		signMigration(context.Background(), path, "test@example.com", fsys)
+		// Check output
+		exists, err := afero.Exists(fsys, repair.GetSignaturePath("0"))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
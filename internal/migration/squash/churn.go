@@ -0,0 +1,63 @@
+package squash
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/apply"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+type redefinition struct {
+	Object string
+	Files  []string
+}
+
+// Finds objects created more than once across migrations, such as functions that are
+// replaced by later files, ordered by the number of definitions.
+func findRedefinitions(migrations []string, fsys afero.Fs) ([]redefinition, error) {
+	files := map[string][]string{}
+	var order []string
+	for _, name := range migrations {
+		migration, err := repair.NewMigrationFromFile(filepath.Join(utils.MigrationsDir, name), fsys)
+		if err != nil {
+			return nil, err
+		}
+		for _, sql := range migration.Lines {
+			kind, object, ok := apply.DefinedObject(sql)
+			if !ok {
+				continue
+			}
+			key := kind + " " + object
+			if _, ok := files[key]; !ok {
+				order = append(order, key)
+			}
+			files[key] = append(files[key], name)
+		}
+	}
+	var result []redefinition
+	for _, key := range order {
+		if len(files[key]) > 1 {
+			result = append(result, redefinition{Object: key, Files: files[key]})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return len(result[i].Files) > len(result[j].Files)
+	})
+	return result, nil
+}
+
+func printRedefinitions(redefined []redefinition, w io.Writer) {
+	if len(redefined) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Objects redefined across merged migrations:")
+	for _, r := range redefined {
+		fmt.Fprintf(w, "  %dx %s (%s)\n", len(r.Files), r.Object, strings.Join(r.Files, ", "))
+	}
+}
@@ -0,0 +1,46 @@
+package squash
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestFindRedefinitions(t *testing.T) {
+	t.Run("counts objects created more than once", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		files := map[string]string{
+			"0_init.sql":  "create table users (id int);\ncreate function uid() returns int as $$ select 1 $$ language sql;",
+			"1_fix.sql":   `create or replace function public.uid() returns int as $$ select 2 $$ language sql;`,
+			"2_again.sql": "CREATE OR REPLACE FUNCTION \"public\".\"uid\"() RETURNS int AS $$ select 3 $$ LANGUAGE sql;\ncreate trigger t after insert on users execute function uid();",
+		}
+		for name, sql := range files {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte(sql), 0644))
+		}
+		// Run test
+		redefined, err := findRedefinitions([]string{"0_init.sql", "1_fix.sql", "2_again.sql"}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []redefinition{{
+			Object: "function public.uid",
+			Files:  []string{"0_init.sql", "1_fix.sql", "2_again.sql"},
+		}}, redefined)
+		var out bytes.Buffer
+		printRedefinitions(redefined, &out)
+		assert.Equal(t, "Objects redefined across merged migrations:\n  3x function public.uid (0_init.sql, 1_fix.sql, 2_again.sql)\n", out.String())
+	})
+
+	t.Run("throws error on missing file", func(t *testing.T) {
+		// Run test
+		_, err := findRedefinitions([]string{"0_init.sql"}, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
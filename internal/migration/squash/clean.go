@@ -0,0 +1,53 @@
+package squash
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/supabase/cli/internal/migration/reorder"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var ErrUncommitted = errors.New("uncommitted changes in migrations directory")
+
+// Refuses to squash over migration edits that cannot be restored from git. The check is
+// skipped when the project is not inside a git repository.
+func assertCleanMigrations() error {
+	repo, err := git.PlainOpenWithOptions(".", &git.PlainOpenOptions{DetectDotGit: true})
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return nil
+	} else if err != nil {
+		return errors.Errorf("failed to open git repository: %w", err)
+	}
+	return checkCleanMigrations(repo, utils.MigrationsDir)
+}
+
+func checkCleanMigrations(repo *git.Repository, dir string) error {
+	prefix, err := reorder.GetRepoPath(repo, dir)
+	if err != nil {
+		return err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return errors.Errorf("failed to load git worktree: %w", err)
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return errors.Errorf("failed to load git status: %w", err)
+	}
+	var changed []string
+	for path, s := range status {
+		if strings.HasPrefix(path, prefix+"/") && (s.Worktree != git.Unmodified || s.Staging != git.Unmodified) {
+			changed = append(changed, path)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	sort.Strings(changed)
+	utils.CmdSuggestion = fmt.Sprintf("Commit your changes first, or use %s to squash anyway.", utils.Aqua("--allow-dirty"))
+	return errors.Errorf("%w:\n  %s", ErrUncommitted, strings.Join(changed, "\n  "))
+}
@@ -0,0 +1,53 @@
+package squash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestCleanMigrations(t *testing.T) {
+	setup := func(t *testing.T) (*git.Repository, string) {
+		root := t.TempDir()
+		repo, err := git.PlainInit(root, false)
+		require.NoError(t, err)
+		dir := filepath.Join(root, utils.MigrationsDir)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "0_init.sql"), []byte("create schema a"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "README.md"), []byte("readme"), 0644))
+		wt, err := repo.Worktree()
+		require.NoError(t, err)
+		require.NoError(t, wt.AddGlob("."))
+		_, err = wt.Commit("init", &git.CommitOptions{Author: &object.Signature{Name: "test", When: time.Now()}})
+		require.NoError(t, err)
+		return repo, root
+	}
+
+	t.Run("ignores changes outside migrations", func(t *testing.T) {
+		repo, root := setup(t)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "README.md"), []byte("edited"), 0644))
+		// Run test
+		err := checkCleanMigrations(repo, filepath.Join(root, utils.MigrationsDir))
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on modified and untracked migrations", func(t *testing.T) {
+		repo, root := setup(t)
+		dir := filepath.Join(root, utils.MigrationsDir)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "0_init.sql"), []byte("create schema b"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "1_new.sql"), []byte{}, 0644))
+		// Run test
+		err := checkCleanMigrations(repo, dir)
+		// Check error
+		assert.ErrorIs(t, err, ErrUncommitted)
+		assert.ErrorContains(t, err, "supabase/migrations/0_init.sql\n  supabase/migrations/1_new.sql")
+	})
+}
@@ -0,0 +1,97 @@
+package squash
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/utils"
+	"github.com/supabase/cli/internal/utils/parser"
+)
+
+// Idempotent statements that are safe to drop when repeated verbatim.
+var dedupAllowlist = regexp.MustCompile(`(?i)^CREATE (SCHEMA|EXTENSION) IF NOT EXISTS ("[^"]+"|[^\s;]+)(.*)$`)
+
+var (
+	// Changes to a schema or extension after which a repeated create is no longer a no-op.
+	dedupBarrier = regexp.MustCompile(`(?is)^(DROP|ALTER) (SCHEMA|EXTENSION) (IF EXISTS )?(.+)$`)
+	identList    = regexp.MustCompile(`"[^"]+"|[^\s,;]+`)
+	schemaClause = regexp.MustCompile(`(?i)\bSCHEMA ("[^"]+"|[^\s;]+)`)
+)
+
+// Concatenates migration files in order into the last file without applying them to a shadow database.
+func concatMigrations(migrations []string, params RunParams, fsys afero.Fs) error {
+	var stats []string
+	for _, name := range migrations {
+		path := filepath.Join(utils.MigrationsDir, name)
+		contents, err := afero.ReadFile(fsys, path)
+		if err != nil {
+			return errors.Errorf("failed to read migration file: %w", err)
+		}
+		// Split without trimming to preserve comments and formatting
+		lines, err := parser.Split(bytes.NewReader(contents))
+		if err != nil {
+			return err
+		}
+		if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+			lines[n-1] += "\n"
+		}
+		stats = append(stats, lines...)
+	}
+	path := filepath.Join(utils.MigrationsDir, migrations[len(migrations)-1])
+	if params.Stdout {
+		path = stdoutPath
+	}
+	contents, err := postProcess([]byte(strings.Join(dedupStatements(stats), "")), newPostProcessors(params))
+	if err != nil {
+		return err
+	}
+	return writeSquashed(path, contents, params, fsys)
+}
+
+// Removes exact duplicates of allowlisted statements, keeping the first occurrence. A duplicate
+// is kept if the schema or extension it creates was dropped or altered since the first occurrence.
+func dedupStatements(stats []string) []string {
+	// Maps each seen statement to the identifiers of objects it depends on
+	seen := map[string][]string{}
+	result := make([]string, 0, len(stats))
+	for _, sql := range stats {
+		key := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(sql), ";"))
+		if matches := dedupAllowlist.FindStringSubmatch(key); len(matches) > 0 {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			objects := []string{normalizeIdent(matches[2])}
+			if schema := schemaClause.FindStringSubmatch(matches[3]); len(schema) > 1 {
+				objects = append(objects, normalizeIdent(schema[1]))
+			}
+			seen[key] = objects
+		} else if matches := dedupBarrier.FindStringSubmatch(key); len(matches) > 0 {
+			changed := map[string]bool{}
+			for _, ident := range identList.FindAllString(matches[4], -1) {
+				changed[normalizeIdent(ident)] = true
+			}
+			for k, objects := range seen {
+				for _, name := range objects {
+					if changed[name] {
+						delete(seen, k)
+						break
+					}
+				}
+			}
+		}
+		result = append(result, sql)
+	}
+	return result
+}
+
+// Unquoted identifiers are case insensitive in postgres.
+func normalizeIdent(ident string) string {
+	if unquoted := strings.Trim(ident, `"`); len(unquoted) < len(ident) {
+		return unquoted
+	}
+	return strings.ToLower(ident)
+}
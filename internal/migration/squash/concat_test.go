@@ -0,0 +1,105 @@
+package squash
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestConcatMigrations(t *testing.T) {
+	t.Run("concatenates and dedups idempotent statements", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		files := map[string]string{
+			"0_init.sql":   "create schema if not exists private;\ncreate extension if not exists pgcrypto;\ncreate table private.a();",
+			"1_target.sql": "create schema if not exists private;\ninsert into private.a default values;\ninsert into private.a default values;\n",
+		}
+		for name, sql := range files {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte(sql), 0644))
+		}
+		// Run test
+		err := concatMigrations([]string{"0_init.sql", "1_target.sql"}, RunParams{}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, filepath.Join(utils.MigrationsDir, "1_target.sql"))
+		assert.NoError(t, err)
+		assert.Equal(t, list.BaselineMarker+`
+create schema if not exists private;
+create extension if not exists pgcrypto;
+create table private.a();
+
+insert into private.a default values;
+insert into private.a default values;
+`, string(contents))
+	})
+
+	t.Run("writes to stdout without modifying files", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "1_target.sql")
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "0_init.sql"), []byte("create table a();\n"), 0644))
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create table b();\n"), 0644))
+		// Setup stdout pipe
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		stdout := os.Stdout
+		os.Stdout = w
+		defer func() { os.Stdout = stdout }()
+		// Run test
+		err = concatMigrations([]string{"0_init.sql", "1_target.sql"}, RunParams{Stdout: true}, fsys)
+		require.NoError(t, w.Close())
+		// Check error
+		assert.NoError(t, err)
+		output, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "create table a();\ncreate table b();\n", string(output))
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		assert.Equal(t, "create table b();\n", string(contents))
+	})
+
+	t.Run("throws error on missing file", func(t *testing.T) {
+		// Run test
+		err := concatMigrations([]string{"0_init.sql"}, RunParams{}, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestDedupStatements(t *testing.T) {
+	t.Run("keeps non idempotent duplicates", func(t *testing.T) {
+		stats := []string{
+			"create schema private;",
+			"\ncreate schema private;",
+			"\nCREATE EXTENSION IF NOT EXISTS pg_trgm;",
+			"\n\nCREATE EXTENSION IF NOT EXISTS pg_trgm;",
+		}
+		// Run test
+		result := dedupStatements(stats)
+		// Check output
+		assert.Equal(t, stats[:3], result)
+	})
+	t.Run("keeps duplicates after intervening ddl", func(t *testing.T) {
+		stats := []string{
+			"CREATE SCHEMA IF NOT EXISTS private;",
+			"\nCREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA extensions;",
+			"\nDROP SCHEMA IF EXISTS Private CASCADE;",
+			"\nCREATE SCHEMA IF NOT EXISTS private;",
+			"\nCREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA extensions;",
+			"\nDROP SCHEMA \"extensions\" CASCADE;",
+			"\nCREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA extensions;",
+			"\nCREATE SCHEMA IF NOT EXISTS private;",
+		}
+		// Run test
+		result := dedupStatements(stats)
+		// Check output
+		assert.Equal(t, []string{stats[0], stats[1], stats[2], stats[3], stats[5], stats[6]}, result)
+	})
+}
@@ -0,0 +1,109 @@
+package squash
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+const (
+	// Appends data statements to the squashed migration after the dumped schema
+	DataSection = "section"
+	// Prepends data statements to seed.sql so they run before existing seed data
+	DataSeed = "seed"
+)
+
+const dataComment = "\n-- DATA\n\n"
+
+var (
+	dmlPattern     = regexp.MustCompile(`(?i)^(INSERT|UPDATE|DELETE|MERGE)\s`)
+	commentPattern = regexp.MustCompile(`^(\s*--[^\n]*\n)+`)
+	// Copying from a query is always an export, so only table copies can load data
+	copyFromPattern = regexp.MustCompile(`(?is)^COPY\s+[^(].*\sFROM\s`)
+)
+
+// Collects data modifying statements from migration files in order, which a schema only dump drops.
+func collectData(migrations []string, fsys afero.Fs) ([]string, error) {
+	var result []string
+	for _, name := range migrations {
+		path := filepath.Join(utils.MigrationsDir, name)
+		m, err := repair.NewMigrationFromFile(path, fsys)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range m.Lines {
+			if isDataStatement(line) {
+				result = append(result, line)
+			}
+		}
+	}
+	return result, nil
+}
+
+func isDataStatement(sql string) bool {
+	body := strings.TrimSpace(commentPattern.ReplaceAllString(sql, ""))
+	return dmlPattern.MatchString(body) || copyFromPattern.MatchString(body)
+}
+
+func formatData(stats []string) []byte {
+	var buf bytes.Buffer
+	for _, line := range stats {
+		buf.WriteString(line)
+		buf.WriteString(";\n\n")
+	}
+	return buf.Bytes()
+}
+
+const (
+	seedDataStart = "-- Data from squashed migrations\n\n"
+	seedDataEnd   = "-- End of data from squashed migrations\n"
+)
+
+// Prepends data statements to seed.sql so that db reset restores the same rows after squashing.
+// Statements already moved by an earlier squash are kept once, so repeated runs do not duplicate rows.
+func writeSeedData(stats []string, fsys afero.Fs) error {
+	existing, err := afero.ReadFile(fsys, utils.SeedDataPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Errorf("failed to read seed file: %w", err)
+	}
+	var moved []byte
+	if start := bytes.Index(existing, []byte(seedDataStart)); start >= 0 {
+		if end := bytes.Index(existing[start:], []byte(seedDataEnd)); end >= 0 {
+			end += start
+			moved = existing[start+len(seedDataStart) : end]
+			// Drops the blank line separating the block from existing seed data
+			rest := bytes.TrimPrefix(existing[end+len(seedDataEnd):], []byte("\n"))
+			existing = append(existing[:start:start], rest...)
+		}
+	}
+	var added int
+	for _, line := range stats {
+		data := formatData([]string{line})
+		if !bytes.Contains(moved, data) {
+			moved = append(moved, data...)
+			added++
+		}
+	}
+	if added == 0 {
+		fmt.Fprintln(os.Stderr, "Data statements are already in", utils.Bold(utils.SeedDataPath))
+		return nil
+	}
+	contents := append([]byte(seedDataStart), moved...)
+	contents = append(contents, seedDataEnd...)
+	if len(existing) > 0 {
+		contents = append(contents, '\n')
+	}
+	if err := newAuditLog(fsys).writeFile(utils.SeedDataPath, append(contents, existing...), fsys); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Moved %d data statements to %s\n", added, utils.Bold(utils.SeedDataPath))
+	return nil
+}
@@ -0,0 +1,124 @@
+package squash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestCollectData(t *testing.T) {
+	t.Run("collects data statements in order", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		first := filepath.Join(utils.MigrationsDir, "0_schema.sql")
+		require.NoError(t, afero.WriteFile(fsys, first, []byte(`create table t (id int);
+-- default rows
+insert into t values (1);`), 0644))
+		second := filepath.Join(utils.MigrationsDir, "1_data.sql")
+		require.NoError(t, afero.WriteFile(fsys, second, []byte(`alter table t add column name text;
+update t set name = 'a';
+delete from t where id > 1;`), 0644))
+		// Run test
+		data, err := collectData([]string{"0_schema.sql", "1_data.sql"}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"-- default rows\ninsert into t values (1)",
+			"update t set name = 'a'",
+			"delete from t where id > 1",
+		}, data)
+	})
+
+	t.Run("throws error on missing file", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		_, err := collectData([]string{"0_schema.sql"}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestIsDataStatement(t *testing.T) {
+	assert.True(t, isDataStatement("INSERT INTO t VALUES (1)"))
+	assert.True(t, isDataStatement("-- comment\nmerge into t using s on true when matched then delete"))
+	assert.False(t, isDataStatement("CREATE TRIGGER t AFTER INSERT ON t EXECUTE FUNCTION f()"))
+	assert.False(t, isDataStatement("inserted"))
+	assert.True(t, isDataStatement("COPY public.t (id) FROM '/tmp/t.csv' WITH (FORMAT csv)"))
+	assert.False(t, isDataStatement("copy (select * from t) to stdout"))
+	assert.False(t, isDataStatement("copy t to '/tmp/t.csv'"))
+}
+
+func TestWriteSeedData(t *testing.T) {
+	t.Run("prepends to existing seed", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, utils.SeedDataPath, []byte("insert into t values (2);\n"), 0644))
+		// Run test
+		err := writeSeedData([]string{"insert into t values (1)"}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, utils.SeedDataPath)
+		assert.NoError(t, err)
+		assert.Equal(t, `-- Data from squashed migrations
+
+insert into t values (1);
+
+-- End of data from squashed migrations
+
+insert into t values (2);
+`, string(contents))
+	})
+
+	t.Run("skips data moved by earlier squash", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, utils.SeedDataPath, []byte("insert into t values (2);\n"), 0644))
+		require.NoError(t, writeSeedData([]string{"insert into t values (1)"}, fsys))
+		// Run test
+		err := writeSeedData([]string{"insert into t values (1)", "copy t from '/tmp/t.csv'"}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, utils.SeedDataPath)
+		assert.NoError(t, err)
+		assert.Equal(t, `-- Data from squashed migrations
+
+insert into t values (1);
+
+copy t from '/tmp/t.csv';
+
+-- End of data from squashed migrations
+
+insert into t values (2);
+`, string(contents))
+	})
+
+	t.Run("keeps seed unchanged on repeated run", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, writeSeedData([]string{"insert into t values (1)"}, fsys))
+		expected, err := afero.ReadFile(fsys, utils.SeedDataPath)
+		require.NoError(t, err)
+		// Run test
+		err = writeSeedData([]string{"insert into t values (1)"}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, utils.SeedDataPath)
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), string(contents))
+	})
+
+	t.Run("throws error on permission denied", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewReadOnlyFs(afero.NewMemMapFs())
+		// Run test
+		err := writeSeedData([]string{"insert into t values (1)"}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, os.ErrPermission)
+	})
+}
@@ -0,0 +1,41 @@
+package squash
+
+import (
+	"io"
+	"regexp"
+
+	"github.com/go-errors/errors"
+)
+
+// Equivalent default expressions that pg_dump renders differently across Postgres versions,
+// mapped to the form written by recent versions. The trailing group rejects calls with a
+// precision, such as CURRENT_TIMESTAMP(3), which are not equivalent.
+var defaultRewrites = []struct {
+	pattern   *regexp.Regexp
+	canonical string
+}{{
+	pattern:   regexp.MustCompile(`(?im)(\bDEFAULT\s+)(?:CURRENT_TIMESTAMP|"?now"?\(\)|"?transaction_timestamp"?\(\)|\('now'::"?text"?\)::"?timestamp with time zone"?)([^(\w]|$)`),
+	canonical: `${1}"now"()${2}`,
+}, {
+	pattern:   regexp.MustCompile(`(?im)(\bDEFAULT\s+)(?:CURRENT_DATE|\('now'::"?text"?\)::"?date"?)([^(\w]|$)`),
+	canonical: `${1}CURRENT_DATE${2}`,
+}, {
+	pattern:   regexp.MustCompile(`(?im)(\bDEFAULT\s+)(?:'\{\}'|ARRAY\[\])(::"?\w+"?\[\])`),
+	canonical: `${1}'{}'${2}`,
+}}
+
+// Canonicalizes equivalent column defaults so that squashed files do not churn when
+// developers run slightly different shadow database versions.
+func normalizeDefaults(in io.Reader, out io.Writer) error {
+	contents, err := io.ReadAll(in)
+	if err != nil {
+		return errors.Errorf("failed to read squashed migration: %w", err)
+	}
+	for _, r := range defaultRewrites {
+		contents = r.pattern.ReplaceAll(contents, []byte(r.canonical))
+	}
+	if _, err := out.Write(contents); err != nil {
+		return errors.Errorf("failed to write normalized migration: %w", err)
+	}
+	return nil
+}
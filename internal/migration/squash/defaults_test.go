@@ -0,0 +1,68 @@
+package squash
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeDefaults(t *testing.T) {
+	t.Run("rewrites equivalent defaults", func(t *testing.T) {
+		sql := `CREATE TABLE "public"."todos" (
+    "a" timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    "b" timestamp with time zone DEFAULT now() NOT NULL,
+    "c" timestamp with time zone DEFAULT ('now'::"text")::timestamp with time zone,
+    "d" "date" DEFAULT ('now'::"text")::"date",
+    "e" "text"[] DEFAULT ARRAY[]::"text"[]
+);
+
+ALTER TABLE ONLY "public"."todos" ALTER COLUMN "f" SET DEFAULT transaction_timestamp();
+`
+		var out bytes.Buffer
+		// Run test
+		err := normalizeDefaults(strings.NewReader(sql), &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, `CREATE TABLE "public"."todos" (
+    "a" timestamp with time zone DEFAULT "now"(),
+    "b" timestamp with time zone DEFAULT "now"() NOT NULL,
+    "c" timestamp with time zone DEFAULT "now"(),
+    "d" "date" DEFAULT CURRENT_DATE,
+    "e" "text"[] DEFAULT '{}'::"text"[]
+);
+
+ALTER TABLE ONLY "public"."todos" ALTER COLUMN "f" SET DEFAULT "now"();
+`, out.String())
+	})
+
+	t.Run("keeps defaults that are not equivalent", func(t *testing.T) {
+		sql := `CREATE TABLE "public"."todos" (
+    "a" timestamp(3) with time zone DEFAULT CURRENT_TIMESTAMP(3),
+    "b" timestamp with time zone DEFAULT "public"."now_utc"(),
+    "c" "text" DEFAULT 'now'::"text",
+    "d" "date" DEFAULT CURRENT_DATE
+);
+SELECT CURRENT_TIMESTAMP;
+`
+		var out bytes.Buffer
+		// Run test
+		err := normalizeDefaults(strings.NewReader(sql), &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, sql, out.String())
+	})
+
+	t.Run("runs only when enabled", func(t *testing.T) {
+		sql := `CREATE TABLE "t" ("a" "date" DEFAULT CURRENT_TIMESTAMP);`
+		// Run test
+		kept, err := postProcess([]byte(sql), newPostProcessors(RunParams{}))
+		assert.NoError(t, err)
+		normalized, err := postProcess([]byte(sql), newPostProcessors(RunParams{NormalizeDefaults: true}))
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, sql, string(kept))
+		assert.Equal(t, `CREATE TABLE "t" ("a" "date" DEFAULT "now"());`, string(normalized))
+	})
+}
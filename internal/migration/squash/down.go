@@ -0,0 +1,197 @@
+package squash
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+	"github.com/supabase/cli/internal/utils/parser"
+)
+
+const (
+	identPattern = `(?:"(?:[^"]|"")+"|[\w$]+)`
+	qnamePattern = identPattern + `(?:\.` + identPattern + `)?`
+)
+
+type inverseRule struct {
+	pattern *regexp.Regexp
+	inverse func(matches []string) string
+}
+
+func newInverseRule(pattern string, inverse func(matches []string) string) inverseRule {
+	pattern = strings.NewReplacer("IDENT", "("+identPattern+")", "QNAME", "("+qnamePattern+")").Replace(pattern)
+	return inverseRule{pattern: regexp.MustCompile(`(?is)^` + pattern), inverse: inverse}
+}
+
+func dropStatement(kind string) func([]string) string {
+	return func(m []string) string {
+		return fmt.Sprintf("DROP %s IF EXISTS %s;", kind, m[1])
+	}
+}
+
+var (
+	inverseRules = []inverseRule{
+		newInverseRule(`CREATE\s+SCHEMA\s+(?:IF\s+NOT\s+EXISTS\s+)?IDENT`, dropStatement("SCHEMA")),
+		newInverseRule(`CREATE\s+EXTENSION\s+(?:IF\s+NOT\s+EXISTS\s+)?IDENT`, dropStatement("EXTENSION")),
+		newInverseRule(`CREATE\s+(?:(?:GLOBAL|LOCAL)\s+)?(?:(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?QNAME`, dropStatement("TABLE")),
+		newInverseRule(`CREATE\s+(?:OR\s+REPLACE\s+)?(?:(?:TEMP|TEMPORARY|RECURSIVE)\s+)?VIEW\s+QNAME`, dropStatement("VIEW")),
+		newInverseRule(`CREATE\s+MATERIALIZED\s+VIEW\s+(?:IF\s+NOT\s+EXISTS\s+)?QNAME`, dropStatement("MATERIALIZED VIEW")),
+		newInverseRule(`CREATE\s+SEQUENCE\s+(?:IF\s+NOT\s+EXISTS\s+)?QNAME`, dropStatement("SEQUENCE")),
+		newInverseRule(`CREATE\s+TYPE\s+QNAME`, dropStatement("TYPE")),
+		newInverseRule(`CREATE\s+DOMAIN\s+QNAME`, dropStatement("DOMAIN")),
+		newInverseRule(`CREATE\s+PUBLICATION\s+IDENT`, dropStatement("PUBLICATION")),
+		newInverseRule(`CREATE\s+SERVER\s+(?:IF\s+NOT\s+EXISTS\s+)?IDENT`, dropStatement("SERVER")),
+		newInverseRule(`CREATE\s+(?:OR\s+REPLACE\s+)?(FUNCTION|PROCEDURE)\s+QNAME\s*(\(.*)`, func(m []string) string {
+			args, ok := identityArguments(m[3])
+			if !ok {
+				return ""
+			}
+			return fmt.Sprintf("DROP %s IF EXISTS %s%s;", strings.ToUpper(m[1]), m[2], args)
+		}),
+		newInverseRule(`CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?IDENT\s+ON\s+(?:ONLY\s+)?(?:IDENT\.)?`, func(m []string) string {
+			// Indexes are created in the schema of their table
+			if len(m[2]) > 0 {
+				return fmt.Sprintf("DROP INDEX IF EXISTS %s.%s;", m[2], m[1])
+			}
+			return fmt.Sprintf("DROP INDEX IF EXISTS %s;", m[1])
+		}),
+		newInverseRule(`CREATE\s+(?:OR\s+REPLACE\s+)?(?:CONSTRAINT\s+)?TRIGGER\s+IDENT\s+.*?\bON\s+QNAME`, func(m []string) string {
+			return fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s;", m[1], m[2])
+		}),
+		newInverseRule(`CREATE\s+POLICY\s+IDENT\s+ON\s+QNAME`, func(m []string) string {
+			return fmt.Sprintf("DROP POLICY IF EXISTS %s ON %s;", m[1], m[2])
+		}),
+		newInverseRule(`CREATE\s+USER\s+MAPPING\s+(?:IF\s+NOT\s+EXISTS\s+)?FOR\s+IDENT\s+SERVER\s+IDENT`, func(m []string) string {
+			return fmt.Sprintf("DROP USER MAPPING IF EXISTS FOR %s SERVER %s;", m[1], m[2])
+		}),
+		newInverseRule(`ALTER\s+TABLE\s+(?:ONLY\s+)?QNAME\s+ADD\s+CONSTRAINT\s+IDENT`, func(m []string) string {
+			return fmt.Sprintf("ALTER TABLE IF EXISTS %s DROP CONSTRAINT IF EXISTS %s;", m[1], m[2])
+		}),
+	}
+	// Statements altering objects that are dropped anyway by the inverse of their create statement
+	droppedWithObject = regexp.MustCompile(`(?is)^(?:GRANT|REVOKE|COMMENT\s+ON|SET|RESET|SELECT\s+pg_catalog\.set_config)\b|` +
+		`^ALTER\s+(?:\w+\s+){1,2}.+?\s+OWNER\s+TO\s|` +
+		`^ALTER\s+TABLE\s+(?:ONLY\s+)?\S+\s+(?:(?:ENABLE|FORCE)\s+ROW\s+LEVEL\s+SECURITY|ALTER\s+COLUMN\b|ATTACH\s+PARTITION\b|CLUSTER\s+ON\b|REPLICA\s+IDENTITY\b)|` +
+		`^ALTER\s+SEQUENCE\s+\S+\s+OWNED\s+BY\b|` +
+		`^ALTER\s+PUBLICATION\s+\S+\s+ADD\s+TABLE\b`)
+	leadingComments = regexp.MustCompile(`^(?:\s*--[^\n]*\n)*\s*`)
+	defaultClause   = regexp.MustCompile(`(?is)\s+(?:DEFAULT\s|=).*$`)
+)
+
+// Extracts the argument types of a function signature, without defaults, for its drop statement.
+func identityArguments(sig string) (string, bool) {
+	depth := 0
+	var args []string
+	var quote rune
+	start := 1
+	for i, c := range sig {
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+		case '(', '[':
+			depth++
+		case ']':
+			depth--
+		case ')':
+			depth--
+			if depth == 0 {
+				args = append(args, sig[start:i])
+				for j, arg := range args {
+					args[j] = strings.TrimSpace(defaultClause.ReplaceAllString(arg, ""))
+				}
+				if len(args) == 1 && len(args[0]) == 0 {
+					return "()", true
+				}
+				return "(" + strings.Join(args, ", ") + ")", true
+			}
+		case ',':
+			if depth == 1 {
+				args = append(args, sig[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return "", false
+}
+
+// Computes the inverse of a single statement, returning ok as false when there is none.
+func invertStatement(sql string) (string, bool) {
+	sql = leadingComments.ReplaceAllString(sql, "")
+	if len(sql) == 0 || droppedWithObject.MatchString(sql) {
+		return "", true
+	}
+	for _, r := range inverseRules {
+		if matches := r.pattern.FindStringSubmatch(sql); len(matches) > 0 {
+			if inverse := r.inverse(matches); len(inverse) > 0 {
+				return inverse, true
+			}
+			break
+		}
+	}
+	return "", false
+}
+
+// Builds a best effort down migration dropping the objects created by the squashed migration.
+// Statements are inverted in reverse order since pg_dump writes them in dependency order.
+func generateDown(up io.Reader, source string, warn io.Writer) (string, error) {
+	stats, err := parser.Split(up)
+	if err != nil {
+		return "", err
+	}
+	var inverse []string
+	line := 1
+	for _, sql := range stats {
+		start := line + strings.Count(sql, "\n") - strings.Count(strings.TrimLeft(sql, " \t\r\n"), "\n")
+		line += strings.Count(sql, "\n")
+		trimmed := strings.TrimSpace(sql)
+		drop, ok := invertStatement(trimmed)
+		if !ok {
+			summary, _, _ := strings.Cut(leadingComments.ReplaceAllString(trimmed, ""), "\n")
+			fmt.Fprintf(warn, "%s no clean inverse for statement on line %d: %s\n", utils.Yellow("WARNING:"), start, summary)
+			continue
+		}
+		if len(drop) > 0 {
+			inverse = append(inverse, drop)
+		}
+	}
+	var down bytes.Buffer
+	fmt.Fprintf(&down, "-- Best effort inverse of %s generated by supabase migration squash --with-down.\n", source)
+	for i := len(inverse) - 1; i >= 0; i-- {
+		fmt.Fprintf(&down, "\n%s\n", inverse[i])
+	}
+	return down.String(), nil
+}
+
+// Writes the down migration of the squashed migration at path to its companion .down.sql file.
+func writeDown(path string, fsys afero.Fs) error {
+	up, err := fsys.Open(path)
+	if err != nil {
+		return errors.Errorf("failed to open squashed migration: %w", err)
+	}
+	defer up.Close()
+	contents, err := generateDown(up, filepath.Base(path), os.Stderr)
+	if err != nil {
+		return err
+	}
+	version := utils.MigrateFilePattern.FindStringSubmatch(filepath.Base(path))[1]
+	downPath := repair.GetDownPath(version)
+	if err := newAuditLog(fsys).writeFile(downPath, []byte(contents), fsys); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Wrote down migration to", utils.Bold(downPath))
+	return nil
+}
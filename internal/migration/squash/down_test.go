@@ -0,0 +1,132 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+const upSchema = `-- supabase: squashed baseline
+SET statement_timeout = 0;
+
+CREATE SCHEMA IF NOT EXISTS "private";
+
+CREATE EXTENSION IF NOT EXISTS "pg_trgm" WITH SCHEMA "extensions";
+
+CREATE TYPE "public"."status" AS ENUM (
+    'todo',
+    'done'
+);
+
+CREATE OR REPLACE FUNCTION "public"."search"("query" "text", "max" integer DEFAULT 10) RETURNS SETOF "text"
+    LANGUAGE "plpgsql"
+    AS $$
+begin
+  return query select 'x';
+end;
+$$;
+
+ALTER FUNCTION "public"."search"("query" "text", "max" integer) OWNER TO "postgres";
+
+CREATE TABLE IF NOT EXISTS "public"."todos" (
+    "id" bigint NOT NULL,
+    "status" "public"."status" DEFAULT 'todo'
+);
+
+ALTER TABLE "public"."todos" OWNER TO "postgres";
+
+ALTER TABLE ONLY "public"."todos"
+    ADD CONSTRAINT "todos_pkey" PRIMARY KEY ("id");
+
+CREATE INDEX "todos_status_idx" ON "public"."todos" USING "btree" ("status");
+
+CREATE OR REPLACE TRIGGER "on_todo" AFTER INSERT OR UPDATE ON "public"."todos" FOR EACH ROW EXECUTE FUNCTION "public"."search"();
+
+CREATE POLICY "read" ON "public"."todos" FOR SELECT USING (true);
+
+ALTER TABLE "public"."todos" ENABLE ROW LEVEL SECURITY;
+
+GRANT ALL ON TABLE "public"."todos" TO "anon";
+
+INSERT INTO "public"."todos" VALUES (1, 'todo');
+`
+
+func TestGenerateDown(t *testing.T) {
+	t.Run("drops objects in reverse order", func(t *testing.T) {
+		var warn bytes.Buffer
+		// Run test
+		down, err := generateDown(strings.NewReader(upSchema), "1_baseline.sql", &warn)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, `-- Best effort inverse of 1_baseline.sql generated by supabase migration squash --with-down.
+
+DROP POLICY IF EXISTS "read" ON "public"."todos";
+
+DROP TRIGGER IF EXISTS "on_todo" ON "public"."todos";
+
+DROP INDEX IF EXISTS "public"."todos_status_idx";
+
+ALTER TABLE IF EXISTS "public"."todos" DROP CONSTRAINT IF EXISTS "todos_pkey";
+
+DROP TABLE IF EXISTS "public"."todos";
+
+DROP FUNCTION IF EXISTS "public"."search"("query" "text", "max" integer);
+
+DROP TYPE IF EXISTS "public"."status";
+
+DROP EXTENSION IF EXISTS "pg_trgm";
+
+DROP SCHEMA IF EXISTS "private";
+`, down)
+		assert.Equal(t, utils.Yellow("WARNING:")+` no clean inverse for statement on line 43: INSERT INTO "public"."todos" VALUES (1, 'todo');`+"\n", warn.String())
+	})
+
+	t.Run("parses function arguments", func(t *testing.T) {
+		args, ok := identityArguments(`() RETURNS void`)
+		assert.True(t, ok)
+		assert.Equal(t, "()", args)
+		args, ok = identityArguments(`("a" numeric(10, 2) = 0, "b" "text"[] DEFAULT ARRAY['x', 'y']) RETURNS void`)
+		assert.True(t, ok)
+		assert.Equal(t, `("a" numeric(10, 2), "b" "text"[])`, args)
+		_, ok = identityArguments(`("a" integer`)
+		assert.False(t, ok)
+	})
+}
+
+func TestSquashWithDown(t *testing.T) {
+	t.Run("writes down migration next to squashed file", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema a;"), 0644))
+		require.NoError(t, afero.WriteFile(fsys, repair.GetDownPath("0"), []byte("drop schema a;"), 0644))
+		path = filepath.Join(utils.MigrationsDir, "1_target.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create table a.t();"), 0644))
+		// Run test
+		_, err := squashToVersion(context.Background(), "1", RunParams{Textual: true, WithDown: true}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		down, err := afero.ReadFile(fsys, repair.GetDownPath("1"))
+		assert.NoError(t, err)
+		assert.Equal(t, "-- Best effort inverse of 1_target.sql generated by supabase migration squash --with-down.\n\n"+
+			"DROP TABLE IF EXISTS a.t;\n\nDROP SCHEMA IF EXISTS a;\n", string(down))
+		exists, err := afero.Exists(fsys, repair.GetDownPath("0"))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("throws error on custom format", func(t *testing.T) {
+		// Run test
+		_, err := RunWithResult(context.Background(), "", dbConfig, RunParams{WithDown: true, Format: FormatCustom}, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, ErrDownCustom)
+	})
+}
@@ -0,0 +1,78 @@
+package squash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/go-units"
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+type squashEstimate struct {
+	Merged  []string
+	Size    int64
+	Version string
+	Remote  bool
+}
+
+// Estimates the impact of squashing from local files only, without starting Docker or connecting to the database.
+func RunEstimate(version string, config pgconn.Config, fsys afero.Fs) error {
+	version, err := resolveVersion(version, os.Stdin)
+	if err != nil {
+		return err
+	}
+	if err := assertVersion(version, fsys); err != nil {
+		return err
+	}
+	if err := utils.LoadConfigFS(fsys); err != nil {
+		return err
+	}
+	migrations, _, err := loadSquashWindow(version, RunParams{}, fsys)
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		return errors.New(ErrMissingVersion)
+	}
+	est, err := estimateSquash(migrations, fsys)
+	if err != nil {
+		return err
+	}
+	est.Remote = !utils.IsLocalDatabase(config)
+	fmt.Printf("Files to merge: %d\n", len(est.Merged))
+	fmt.Printf("Combined size: %s\n", units.BytesSize(float64(est.Size)))
+	fmt.Printf("Baseline version: %s\n", est.Version)
+	if est.Remote {
+		fmt.Println("Remote baseline: required")
+	} else {
+		fmt.Println("Remote baseline: not required for local database")
+	}
+	return nil
+}
+
+// The squashed file replaces every migration in the window, so all of them count towards its size.
+func estimateSquash(migrations []string, fsys afero.Fs) (squashEstimate, error) {
+	target := migrations[len(migrations)-1]
+	est := squashEstimate{
+		Merged:  migrations[:len(migrations)-1],
+		Version: utils.MigrateFilePattern.FindStringSubmatch(target)[1],
+	}
+	for _, name := range migrations {
+		fi, err := fsys.Stat(filepath.Join(utils.MigrationsDir, name))
+		if err != nil {
+			return est, errors.Errorf("failed to stat migration file: %w", err)
+		}
+		est.Size += fi.Size()
+		// Custom format dumps are merged along with their migration
+		version := utils.MigrateFilePattern.FindStringSubmatch(name)[1]
+		if fi, err := fsys.Stat(repair.GetCustomDumpPath(version)); err == nil {
+			est.Size += fi.Size()
+		}
+	}
+	return est, nil
+}
@@ -0,0 +1,71 @@
+package squash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestEstimateSquash(t *testing.T) {
+	t.Run("sums size of squashed window", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		files := map[string]string{
+			"0_init.sql":   "create schema a;",
+			"1_target.sql": "create schema b;",
+		}
+		for name, sql := range files {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte(sql), 0644))
+		}
+		require.NoError(t, afero.WriteFile(fsys, repair.GetCustomDumpPath("0"), []byte("PGDMP"), 0644))
+		// Run test
+		est, err := estimateSquash([]string{"0_init.sql", "1_target.sql"}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, squashEstimate{
+			Merged:  []string{"0_init.sql"},
+			Size:    37,
+			Version: "1",
+		}, est)
+	})
+
+	t.Run("throws error on missing file", func(t *testing.T) {
+		// Run test
+		_, err := estimateSquash([]string{"0_init.sql"}, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestRunEstimate(t *testing.T) {
+	t.Run("estimates without docker", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		for _, name := range []string{"0_init.sql", "1_target.sql", "2_after.sql"} {
+			path := filepath.Join(utils.MigrationsDir, name)
+			require.NoError(t, afero.WriteFile(fsys, path, []byte{}, 0644))
+		}
+		// Run test
+		err := RunEstimate("1", pgconn.Config{Host: "db.supabase.co", Port: 5432}, fsys)
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on missing version", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		// Run test
+		err := RunEstimate("", pgconn.Config{}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrMissingVersion)
+	})
+}
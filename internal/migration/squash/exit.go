@@ -0,0 +1,53 @@
+package squash
+
+import (
+	"io/fs"
+
+	"github.com/go-errors/errors"
+)
+
+// Exit codes of squash failures, so that scripts can branch on the reason. Any other
+// failure, such as an invalid flag, exits with 1.
+const (
+	ExitDockerUnavailable = 3
+	ExitShadowTimeout     = 4
+	ExitMigrationFailed   = 5
+	ExitBaselineFailed    = 6
+	ExitFileFailed        = 7
+)
+
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Keeps an existing code so that failures are classified where they first occurred.
+func withExitCode(err error, code int) error {
+	var exitErr *ExitError
+	if err == nil || errors.As(err, &exitErr) {
+		return err
+	}
+	return &ExitError{Code: code, Err: err}
+}
+
+// Failures reading or writing migration files are not wrapped at every call site, so
+// they are recognised by the path error returned from the filesystem.
+func withFileExitCode(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return withExitCode(err, ExitFileFailed)
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+package squash
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/db/diff"
+	"github.com/supabase/cli/internal/db/start"
+	"github.com/supabase/cli/internal/migration/history"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/testing/apitest"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestExitCode(t *testing.T) {
+	utils.Config.Db.Name = "postgres"
+
+	t.Run("keeps first exit code", func(t *testing.T) {
+		err := withExitCode(withExitCode(errors.New("failed"), ExitMigrationFailed), ExitBaselineFailed)
+		// Check error
+		var exitErr *ExitError
+		assert.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, ExitMigrationFailed, exitErr.ExitCode())
+		assert.EqualError(t, err, "failed")
+	})
+
+	t.Run("ignores nil error", func(t *testing.T) {
+		assert.NoError(t, withExitCode(nil, ExitFileFailed))
+		assert.NoError(t, withFileExitCode(nil))
+	})
+
+	t.Run("classifies path errors", func(t *testing.T) {
+		_, err := afero.ReadFile(afero.NewMemMapFs(), "missing.sql")
+		err = withFileExitCode(err)
+		// Check error
+		var exitErr *ExitError
+		assert.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, ExitFileFailed, exitErr.ExitCode())
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("exits on missing docker", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		for _, name := range []string{"0_init.sql", "1_target.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte{}, 0644))
+		}
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Head("/_ping").
+			ReplyError(errors.New("network error"))
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/_ping").
+			ReplyError(errors.New("network error"))
+		// Run test
+		err := Run(context.Background(), "", dbConfig, RunParams{}, fsys)
+		// Check error
+		var exitErr *ExitError
+		assert.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, ExitDockerUnavailable, exitErr.ExitCode())
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("exits on unhealthy shadow", func(t *testing.T) {
+		start.HealthTimeout = time.Millisecond
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Config.Db.Image), "test-shadow-db")
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db/json").
+			Reply(http.StatusServiceUnavailable)
+		gock.New(utils.Docker.DaemonHost()).
+			Delete("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db").
+			Reply(http.StatusOK)
+		// Run test
+		err := squashMigrations(context.Background(), nil, RunParams{}, nil, fsys)
+		// Check error
+		var exitErr *ExitError
+		assert.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, ExitShadowTimeout, exitErr.ExitCode())
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("exits on migration failure", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		sql := "create schema test"
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(sql), 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Config.Db.Image), "test-shadow-db")
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db/json").
+			Reply(http.StatusOK).
+			JSON(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+				State: &types.ContainerState{
+					Running: true,
+					Health:  &types.Health{Status: "healthy"},
+				},
+			}})
+		gock.New(utils.Docker.DaemonHost()).
+			Delete("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db").
+			Reply(http.StatusOK)
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.RealtimeImage), "test-realtime")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-realtime", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.StorageImage), "test-storage")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-storage", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.GotrueImage), "test-auth")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-auth", ""))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(start.SELECT_MISSING_SCHEMAS, start.BaselineSchemas).
+			Reply("SELECT 0")
+		conn.Query(diff.SELECT_DATABASE_NAME, "postgres").
+			Reply("SELECT 1", []interface{}{"postgres"})
+		pgtest.MockMigrationHistory(conn)
+		conn.Query(sql).
+			ReplyError(pgerrcode.DuplicateSchema, `schema "test" already exists`).
+			Query(history.INSERT_MIGRATION_VERSION, "0", "init", []string{sql})
+		// Run test
+		err := squashMigrations(context.Background(), []string{filepath.Base(path)}, RunParams{NoManagedDiff: true}, nil, fsys, conn.Intercept)
+		// Check error
+		var exitErr *ExitError
+		assert.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, ExitMigrationFailed, exitErr.ExitCode())
+		assert.ErrorContains(t, err, `schema "test" already exists`)
+	})
+
+	t.Run("exits on baseline failure", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema test"), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(list.LIST_MIGRATION_VERSION).
+			ReplyError(pgerrcode.InsufficientPrivilege, "permission denied for relation schema_migrations")
+		// Run test
+		err := Run(context.Background(), "0", dbConfig, RunParams{Push: true}, fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
+			cc.PreferSimpleProtocol = true
+		})
+		// Check error
+		var exitErr *ExitError
+		assert.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, ExitBaselineFailed, exitErr.ExitCode())
+	})
+}
@@ -0,0 +1,124 @@
+package squash
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/utils"
+)
+
+const SELECT_AVAILABLE_EXTENSIONS = "SELECT name FROM pg_available_extensions WHERE name = ANY($1)"
+
+var (
+	ErrMissingExtensions = errors.New("extensions are not available in the shadow database")
+
+	updateExtensionPattern = regexp.MustCompile(`(?i)ALTER\s+EXTENSION\s+(?:IF\s+EXISTS\s+)?("[^"]+"|[\w-]+)\s+UPDATE\s+TO\s+('[^']+'|"[^"]+"|[\w.-]+)`)
+	createExtensionPattern = regexp.MustCompile(`(?i)CREATE\s+EXTENSION\s+(?:IF\s+NOT\s+EXISTS\s+)?("[^"]+"|[\w-]+)`)
+)
+
+type extensionUpgrade struct {
+	Name    string
+	Version string
+}
+
+// Finds the final version of each extension upgraded by ALTER EXTENSION ... UPDATE TO.
+func findExtensionUpgrades(migrations []string, fsys afero.Fs) ([]extensionUpgrade, error) {
+	latest := map[string]int{}
+	var result []extensionUpgrade
+	for _, name := range migrations {
+		path := filepath.Join(utils.MigrationsDir, name)
+		contents, err := afero.ReadFile(fsys, path)
+		if err != nil {
+			return nil, errors.Errorf("failed to read migration file: %w", err)
+		}
+		for _, matches := range updateExtensionPattern.FindAllStringSubmatch(string(contents), -1) {
+			upgrade := extensionUpgrade{
+				Name:    strings.Trim(matches[1], `"`),
+				Version: strings.Trim(matches[2], `'"`),
+			}
+			if i, ok := latest[upgrade.Name]; ok {
+				result[i] = upgrade
+				continue
+			}
+			latest[upgrade.Name] = len(result)
+			result = append(result, upgrade)
+		}
+	}
+	return result, nil
+}
+
+// Warns that the squashed schema only reflects the final version of upgraded extensions.
+func warnExtensionUpgrades(migrations []string, fsys afero.Fs) error {
+	upgrades, err := findExtensionUpgrades(migrations, fsys)
+	if err != nil {
+		return err
+	}
+	for _, u := range upgrades {
+		fmt.Fprintf(os.Stderr, "%s squash collapses the upgrade path of extension %s to version %s. Databases on an older version will not be upgraded.\n", utils.Yellow("WARNING:"), utils.Bold(u.Name), u.Version)
+	}
+	return nil
+}
+
+// Finds the names of extensions created by migrations, in the order they first appear.
+func findCreatedExtensions(migrations []string, fsys afero.Fs) ([]string, error) {
+	seen := map[string]bool{}
+	var result []string
+	for _, name := range migrations {
+		path := filepath.Join(utils.MigrationsDir, name)
+		contents, err := afero.ReadFile(fsys, path)
+		if err != nil {
+			return nil, errors.Errorf("failed to read migration file: %w", err)
+		}
+		for _, matches := range createExtensionPattern.FindAllStringSubmatch(string(contents), -1) {
+			ext := strings.Trim(matches[1], `"`)
+			if !seen[ext] {
+				seen[ext] = true
+				result = append(result, ext)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Fails before applying any migration when the shadow image does not ship an extension
+// they create, instead of aborting midway through the squash.
+func assertExtensionsAvailable(ctx context.Context, conn *pgx.Conn, migrations []string, fsys afero.Fs) error {
+	names, err := findCreatedExtensions(migrations, fsys)
+	if err != nil || len(names) == 0 {
+		return err
+	}
+	rows, err := conn.Query(ctx, SELECT_AVAILABLE_EXTENSIONS, names)
+	if err != nil {
+		return errors.Errorf("failed to list available extensions: %w", err)
+	}
+	defer rows.Close()
+	available := map[string]bool{}
+	for rows.Next() {
+		var ext string
+		if err := rows.Scan(&ext); err != nil {
+			return errors.Errorf("failed to scan available extension: %w", err)
+		}
+		available[ext] = true
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Errorf("failed to list available extensions: %w", err)
+	}
+	var missing []string
+	for _, ext := range names {
+		if !available[ext] {
+			missing = append(missing, ext)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	utils.CmdSuggestion = fmt.Sprintf("Set %s in %s to a Postgres version that bundles these extensions, or remove them from your migrations.", utils.Aqua("db.major_version"), utils.Bold(utils.ConfigPath))
+	return errors.Errorf("%w: %s", ErrMissingExtensions, strings.Join(missing, ", "))
+}
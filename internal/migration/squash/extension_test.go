@@ -0,0 +1,136 @@
+package squash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestFindExtensionUpgrades(t *testing.T) {
+	t.Run("finds final version of upgraded extensions", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		files := map[string]string{
+			"0_init.sql":    "create extension pg_graphql with version '1.0';\nALTER EXTENSION pg_graphql UPDATE TO '1.1';",
+			"1_upgrade.sql": "alter extension \"pg_graphql\" update to '1.2';\nalter extension pgsodium update to \"3.1.0\";",
+			"2_schema.sql":  "create table test();",
+		}
+		for name, sql := range files {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte(sql), 0644))
+		}
+		// Run test
+		upgrades, err := findExtensionUpgrades([]string{"0_init.sql", "1_upgrade.sql", "2_schema.sql"}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []extensionUpgrade{
+			{Name: "pg_graphql", Version: "1.2"},
+			{Name: "pgsodium", Version: "3.1.0"},
+		}, upgrades)
+	})
+
+	t.Run("ignores migrations without upgrades", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create extension pgcrypto;"), 0644))
+		// Run test
+		upgrades, err := findExtensionUpgrades([]string{"0_init.sql"}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, upgrades)
+	})
+
+	t.Run("throws error on missing file", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		_, err := findExtensionUpgrades([]string{"0_init.sql"}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestAssertExtensionsAvailable(t *testing.T) {
+	migrations := map[string]string{
+		"0_init.sql":   "create extension if not exists \"uuid-ossp\";\nCREATE EXTENSION postgis WITH SCHEMA extensions;",
+		"1_search.sql": "create extension pg_trgm;\ncreate extension postgis;",
+	}
+
+	t.Run("finds created extensions in order", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for name, sql := range migrations {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte(sql), 0644))
+		}
+		// Run test
+		names, err := findCreatedExtensions([]string{"0_init.sql", "1_search.sql"}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"uuid-ossp", "postgis", "pg_trgm"}, names)
+	})
+
+	t.Run("passes when extensions are available", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for name, sql := range migrations {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte(sql), 0644))
+		}
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(SELECT_AVAILABLE_EXTENSIONS, []string{"uuid-ossp", "postgis", "pg_trgm"}).
+			Reply("SELECT 3", []interface{}{"pg_trgm"}, []interface{}{"postgis"}, []interface{}{"uuid-ossp"})
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = assertExtensionsAvailable(ctx, mock, []string{"0_init.sql", "1_search.sql"}, fsys)
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("lists missing extensions", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for name, sql := range migrations {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte(sql), 0644))
+		}
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(SELECT_AVAILABLE_EXTENSIONS, []string{"uuid-ossp", "postgis", "pg_trgm"}).
+			Reply("SELECT 1", []interface{}{"uuid-ossp"})
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = assertExtensionsAvailable(ctx, mock, []string{"0_init.sql", "1_search.sql"}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrMissingExtensions)
+		assert.ErrorContains(t, err, "postgis, pg_trgm")
+		assert.Contains(t, utils.CmdSuggestion, "db.major_version")
+	})
+
+	t.Run("skips query without extensions", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create table test();"), 0644))
+		// Run test
+		err := assertExtensionsAvailable(context.Background(), nil, []string{filepath.Base(path)}, fsys)
+		// Check error
+		assert.NoError(t, err)
+	})
+}
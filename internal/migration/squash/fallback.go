@@ -0,0 +1,35 @@
+package squash
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/supabase/cli/internal/utils"
+)
+
+// Marks a migration that was concatenated because Docker was unavailable.
+const fallbackComment = "-- supabase: textual fallback, not normalized by postgres"
+
+// Options that only a shadow database can produce are never silently dropped by the fallback.
+func canFallback(params RunParams) bool {
+	return params.Format != FormatCustom && !params.Full && !params.FromEmpty && !params.AssertObjects &&
+		!params.Cache && len(params.Since) == 0 && len(params.VerifyVersions) == 0 && !params.Verify &&
+		len(params.GenTypes) == 0 && !params.CheckTypes && len(params.Owner) == 0 && len(params.ExcludeSchemas) == 0 &&
+		!params.Metadata && !params.NoSecurityLabels && !params.Pretty && !params.Validate
+}
+
+// Switches to concatenating migration files when Docker cannot be started, either because
+// --textual-fallback is set or the user confirms it.
+func fallbackToTextual(params *RunParams, dockerErr error) bool {
+	if !canFallback(*params) {
+		return false
+	}
+	if !params.TextualFallback && !utils.PromptYesNo("Docker is unavailable. Concatenate migration files without a shadow database instead?", false, os.Stdin) {
+		return false
+	}
+	fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), dockerErr)
+	fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "falling back to textual concatenation. The squashed schema is not round-tripped through Postgres, so it keeps every intermediate statement and may need manual cleanup.")
+	params.Textual = true
+	params.unnormalized = true
+	return true
+}
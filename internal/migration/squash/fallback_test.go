@@ -0,0 +1,90 @@
+package squash
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/testing/apitest"
+	"github.com/supabase/cli/internal/utils"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func mockMissingDocker(t *testing.T) {
+	require.NoError(t, apitest.MockDocker(utils.Docker))
+	gock.New(utils.Docker.DaemonHost()).
+		Head("/_ping").
+		ReplyError(errors.New("network error"))
+	gock.New(utils.Docker.DaemonHost()).
+		Get("/_ping").
+		ReplyError(errors.New("network error"))
+}
+
+func TestTextualFallback(t *testing.T) {
+	t.Run("concatenates migrations without docker", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema a;\n"), 0644))
+		path = filepath.Join(utils.MigrationsDir, "1_target.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema b;\n"), 0644))
+		require.NoError(t, utils.LoadConfigFS(fsys))
+		local := pgconn.Config{Host: utils.Config.Hostname, Port: uint16(utils.Config.Db.Port)}
+		// Setup mock docker
+		mockMissingDocker(t)
+		defer gock.OffAll()
+		// Run test
+		err := Run(context.Background(), "", local, RunParams{TextualFallback: true}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		assert.Equal(t, list.BaselineMarker+"\n"+fallbackComment+"\ncreate schema a;\ncreate schema b;\n", string(contents))
+	})
+
+	t.Run("throws error on options requiring shadow", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		for _, name := range []string{"0_init.sql", "1_target.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte{}, 0644))
+		}
+		// Setup mock docker
+		mockMissingDocker(t)
+		defer gock.OffAll()
+		// Run test
+		err := Run(context.Background(), "", dbConfig, RunParams{TextualFallback: true, Full: true}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrDockerRequired)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
+
+func TestCanFallback(t *testing.T) {
+	t.Run("allows plain textual squash", func(t *testing.T) {
+		assert.True(t, canFallback(RunParams{TextualFallback: true}))
+	})
+
+	t.Run("refuses options applied to the dumped schema", func(t *testing.T) {
+		for _, params := range []RunParams{
+			{GenTypes: "types.ts"},
+			{CheckTypes: true},
+			{Owner: "postgres"},
+			{ExcludeSchemas: []string{"cron"}},
+			{Metadata: true},
+			{NoSecurityLabels: true},
+			{Pretty: true},
+			{Validate: true},
+		} {
+			assert.False(t, canFallback(params), "%+v", params)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/supabase/cli/internal/utils"
+)
+
+// Extensions providing the wrappers of foreign servers, ie. postgres_fdw.
+const LIST_FDW_EXTENSIONS = `
+SELECT DISTINCT e.extname, n.nspname
+FROM pg_foreign_server s
+JOIN pg_depend d ON d.classid = 'pg_foreign_data_wrapper'::regclass AND d.objid = s.srvfdw AND d.deptype = 'e'
+JOIN pg_extension e ON e.oid = d.refobjid
+JOIN pg_namespace n ON n.oid = e.extnamespace
+ORDER BY e.extname
+`
+
+var (
+	userMappingPattern = regexp.MustCompile(`(?i)CREATE USER MAPPING FOR [^;]+? SERVER [^;]+? OPTIONS \((?s:.*?)\n\);`)
+	optionValuePattern = regexp.MustCompile(`'(?:[^']|'')*'`)
+)
+
+// Foreign servers and user mappings are dumped with the schema, but not the wrapper extensions
+// when they are installed in an internal schema, so servers would fail to create without them.
+func dumpForeignWrappers(ctx context.Context, conn *pgx.Conn, w io.Writer) error {
+	rows, err := conn.Query(ctx, LIST_FDW_EXTENSIONS)
+	if err != nil {
+		return errors.Errorf("failed to list foreign data wrappers: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, schema string
+		if err := rows.Scan(&name, &schema); err != nil {
+			return errors.Errorf("failed to scan foreign data wrapper: %w", err)
+		}
+		if matchesAny(schema, utils.InternalSchemas) {
+			fmt.Fprintf(w, "CREATE EXTENSION IF NOT EXISTS %s WITH SCHEMA %s;\n\n", pgx.Identifier{name}.Sanitize(), pgx.Identifier{schema}.Sanitize())
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Errorf("failed to list foreign data wrappers: %w", err)
+	}
+	return nil
+}
+
+type fdwCredentialProcessor struct {
+	warn io.Writer
+}
+
+// Replaces option values of user mappings, such as user and password, with a placeholder.
+func (p fdwCredentialProcessor) Process(in io.Reader, out io.Writer) error {
+	contents, err := io.ReadAll(in)
+	if err != nil {
+		return errors.Errorf("failed to read squashed migration: %w", err)
+	}
+	for _, loc := range userMappingPattern.FindAllIndex(contents, -1) {
+		line := bytes.Count(contents[:loc[0]], []byte("\n")) + 1
+		fmt.Fprintf(p.warn, "%s redacted user mapping credentials on line %d\n", utils.Yellow("WARNING:"), line)
+	}
+	contents = userMappingPattern.ReplaceAllFunc(contents, func(stat []byte) []byte {
+		return optionValuePattern.ReplaceAllLiteral(stat, []byte("'"+maskPlaceholder+"'"))
+	})
+	if _, err := out.Write(contents); err != nil {
+		return errors.Errorf("failed to write redacted migration: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/db/diff"
+	"github.com/supabase/cli/internal/db/start"
+	"github.com/supabase/cli/internal/migration/history"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/testing/apitest"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const foreignSchema = `CREATE SERVER "remote" FOREIGN DATA WRAPPER "postgres_fdw" OPTIONS (
+    dbname 'postgres',
+    host 'db.example.com'
+);
+
+ALTER SERVER "remote" OWNER TO "postgres";
+
+CREATE USER MAPPING FOR "postgres" SERVER "remote" OPTIONS (
+    password 'it''s secret',
+    "user" 'admin'
+);
+
+`
+
+const redactedSchema = `CREATE SERVER "remote" FOREIGN DATA WRAPPER "postgres_fdw" OPTIONS (
+    dbname 'postgres',
+    host 'db.example.com'
+);
+
+ALTER SERVER "remote" OWNER TO "postgres";
+
+CREATE USER MAPPING FOR "postgres" SERVER "remote" OPTIONS (
+    password 'REDACTED',
+    "user" 'REDACTED'
+);
+
+`
+
+func TestFdwCredentials(t *testing.T) {
+	t.Run("redacts user mapping options", func(t *testing.T) {
+		var out, warn bytes.Buffer
+		// Run test
+		err := fdwCredentialProcessor{warn: &warn}.Process(strings.NewReader(foreignSchema), &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, redactedSchema, out.String())
+		assert.Contains(t, warn.String(), "redacted user mapping credentials on line 8")
+	})
+
+	t.Run("keeps mappings without options", func(t *testing.T) {
+		sql := `CREATE USER MAPPING FOR "postgres" SERVER "local";
+
+CREATE SERVER "remote" FOREIGN DATA WRAPPER "postgres_fdw" OPTIONS (
+    host 'db.example.com'
+);
+`
+		var out bytes.Buffer
+		// Run test
+		err := fdwCredentialProcessor{warn: io.Discard}.Process(strings.NewReader(sql), &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, sql, out.String())
+	})
+
+	t.Run("keeps credentials when requested", func(t *testing.T) {
+		// Run test
+		processed, err := postProcess([]byte(foreignSchema), newPostProcessors(RunParams{FdwCredentials: true}))
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, foreignSchema, string(processed))
+	})
+}
+
+func TestSquashForeignServer(t *testing.T) {
+	utils.Config.Db.Name = "postgres"
+
+	t.Run("keeps foreign server with redacted credentials", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		sql := "create server remote foreign data wrapper postgres_fdw"
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(sql), 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Config.Db.Image), "test-shadow-db")
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db/json").
+			Reply(http.StatusOK).
+			JSON(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+				State: &types.ContainerState{
+					Running: true,
+					Health:  &types.Health{Status: "healthy"},
+				},
+			}})
+		gock.New(utils.Docker.DaemonHost()).
+			Delete("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db").
+			Reply(http.StatusOK)
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.RealtimeImage), "test-realtime")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-realtime", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.StorageImage), "test-storage")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-storage", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.GotrueImage), "test-auth")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-auth", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-db")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-db", foreignSchema))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(start.SELECT_MISSING_SCHEMAS, start.BaselineSchemas).
+			Reply("SELECT 0")
+		conn.Query(diff.SELECT_DATABASE_NAME, "postgres").
+			Reply("SELECT 1", []interface{}{"postgres"})
+		pgtest.MockMigrationHistory(conn)
+		conn.Query(sql).
+			Reply("CREATE SERVER").
+			Query(history.INSERT_MIGRATION_VERSION, "0", "init", []string{sql}).
+			Reply("INSERT 0 1")
+		conn.Query(LIST_FDW_EXTENSIONS).
+			Reply("SELECT 1", []interface{}{"postgres_fdw", "extensions"})
+		// Run test
+		err := squashMigrations(context.Background(), []string{filepath.Base(path)}, RunParams{NoManagedDiff: true}, nil, fsys, conn.Intercept)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		assert.Equal(t, list.BaselineMarker+"\n"+
+			`CREATE EXTENSION IF NOT EXISTS "postgres_fdw" WITH SCHEMA "extensions";`+"\n\n"+
+			redactedSchema, string(contents))
+	})
+}
@@ -0,0 +1,60 @@
+package squash
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	createSchemaPattern = regexp.MustCompile(`(?i)^\s*CREATE SCHEMA (?:IF NOT EXISTS )?("[^"]+")`)
+	referencesPattern   = regexp.MustCompile(`(?i)\bREFERENCES ("[^"]+"\."[^"]+")`)
+)
+
+// Defers statements until the schema and table of their object, and any tables
+// referenced by foreign keys, are created. Passes repeat until stable because a deferred table
+// may itself be referenced by an earlier statement.
+func orderReferences(stats []string) []string {
+	for range stats {
+		result, moved := deferReferences(stats)
+		if !moved {
+			break
+		}
+		stats = result
+	}
+	return stats
+}
+
+func deferReferences(stats []string) ([]string, bool) {
+	created := map[string]int{}
+	for i, sql := range stats {
+		if matches := createSchemaPattern.FindStringSubmatch(sql); len(matches) > 1 {
+			created[matches[1]] = i
+		} else if matches := createTablePattern.FindStringSubmatch(sql); len(matches) > 1 {
+			created[matches[1]] = i
+		}
+	}
+	deferred := map[int][]string{}
+	result := make([]string, 0, len(stats))
+	for i, sql := range stats {
+		var deps []string
+		if name := qualifiedNamePattern.FindString(sql); len(name) > 0 {
+			deps = append(deps, name[:strings.Index(name, `".`)+1], name)
+		}
+		for _, matches := range referencesPattern.FindAllStringSubmatch(sql, -1) {
+			deps = append(deps, matches[1])
+		}
+		last := i
+		for _, name := range deps {
+			if j, ok := created[name]; ok && j > last {
+				last = j
+			}
+		}
+		if last > i {
+			deferred[last] = append(deferred[last], sql)
+			continue
+		}
+		result = append(result, sql)
+		result = append(result, deferred[i]...)
+	}
+	return result, len(deferred) > 0
+}
@@ -0,0 +1,73 @@
+package squash
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferenceOrder(t *testing.T) {
+	t.Run("moves table after referenced schema", func(t *testing.T) {
+		sql := `CREATE SCHEMA IF NOT EXISTS "a";
+
+CREATE TABLE IF NOT EXISTS "a"."orders" (
+    "user_id" bigint REFERENCES "b"."users"("id")
+);
+
+ALTER TABLE "a"."orders" OWNER TO "postgres";
+
+CREATE SCHEMA IF NOT EXISTS "b";
+
+CREATE TABLE IF NOT EXISTS "b"."users" (
+    "id" bigint PRIMARY KEY
+);
+
+RESET ALL;
+`
+		var out bytes.Buffer
+		// Run test
+		err := writeOrderedSchema(strings.NewReader(sql), &out, RunParams{})
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, `CREATE SCHEMA IF NOT EXISTS "a";
+
+CREATE SCHEMA IF NOT EXISTS "b";
+
+CREATE TABLE IF NOT EXISTS "b"."users" (
+    "id" bigint PRIMARY KEY
+);
+
+CREATE TABLE IF NOT EXISTS "a"."orders" (
+    "user_id" bigint REFERENCES "b"."users"("id")
+);
+
+ALTER TABLE "a"."orders" OWNER TO "postgres";
+
+RESET ALL;
+`, out.String())
+	})
+
+	t.Run("keeps dependency order of dump", func(t *testing.T) {
+		sql := `CREATE SCHEMA IF NOT EXISTS "a";
+
+CREATE SCHEMA IF NOT EXISTS "b";
+
+CREATE TABLE IF NOT EXISTS "a"."orders" (
+    "user_id" bigint
+);
+
+CREATE TABLE IF NOT EXISTS "b"."users" (
+    "id" bigint
+);
+
+ALTER TABLE ONLY "a"."orders"
+    ADD CONSTRAINT "orders_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "b"."users"("id");
+`
+		// Run test
+		stats := orderReferences(strings.SplitAfter(sql, ";\n"))
+		// Check output
+		assert.Equal(t, sql, strings.Join(stats, ""))
+	})
+}
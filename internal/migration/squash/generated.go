@@ -0,0 +1,60 @@
+package squash
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	createFunctionPattern  = regexp.MustCompile(`(?i)^\s*CREATE (?:OR REPLACE )?FUNCTION ("[^"]+"\."[^"]+")\(`)
+	generatedColumnPattern = regexp.MustCompile(`(?is)GENERATED ALWAYS AS \((.*?)\) STORED`)
+	functionCallPattern    = regexp.MustCompile(`("[^"]+"\."[^"]+")\(`)
+)
+
+// Moves functions referenced by generated columns before the first table that
+// uses them. Function bodies are not validated by pg_dump output so creating
+// them earlier is safe, while moving tables would break their dependents.
+func orderGeneratedColumns(stats []string) []string {
+	functions := map[string]int{}
+	for i, sql := range stats {
+		if matches := createFunctionPattern.FindStringSubmatch(sql); len(matches) > 1 {
+			functions[matches[1]] = i
+		}
+	}
+	hoisted := map[int][]string{}
+	moved := map[int]bool{}
+	for i, sql := range stats {
+		if !createTablePattern.MatchString(sql) {
+			continue
+		}
+		for _, expr := range generatedColumnPattern.FindAllStringSubmatch(sql, -1) {
+			for _, call := range functionCallPattern.FindAllStringSubmatch(expr[1], -1) {
+				if j, ok := functions[call[1]]; ok && j > i && !moved[j] {
+					hoisted[i] = append(hoisted[i], stats[j])
+					moved[j] = true
+				}
+			}
+		}
+	}
+	if len(moved) == 0 {
+		return stats
+	}
+	result := make([]string, 0, len(stats))
+	for i, sql := range stats {
+		if moved[i] {
+			continue
+		}
+		if fns := hoisted[i]; len(fns) > 0 {
+			// Keep the leading whitespace of the table in front of the hoisted functions
+			lead, body := splitLeadingSpace(sql)
+			for _, fn := range fns {
+				result = append(result, lead+strings.TrimLeftFunc(fn, unicode.IsSpace))
+				lead = "\n\n"
+			}
+			sql = lead + body
+		}
+		result = append(result, sql)
+	}
+	return result
+}
@@ -0,0 +1,55 @@
+package squash
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratedColumnOrder(t *testing.T) {
+	t.Run("preserves column referencing another column", func(t *testing.T) {
+		sql := `CREATE TABLE IF NOT EXISTS "public"."people" (
+    "height_cm" numeric,
+    "height_in" numeric GENERATED ALWAYS AS (("height_cm" / 2.54)) STORED
+);
+
+ALTER TABLE "public"."people" OWNER TO "postgres";
+`
+		var out bytes.Buffer
+		// Run test
+		err := writeOrderedSchema(strings.NewReader(sql), &out, RunParams{})
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, sql, out.String())
+	})
+
+	t.Run("moves function before generated column", func(t *testing.T) {
+		sql := `CREATE TABLE IF NOT EXISTS "public"."people" (
+    "first_name" "text",
+    "last_name" "text",
+    "full_name" "text" GENERATED ALWAYS AS ("public"."full_name"("first_name", "last_name")) STORED
+);
+
+CREATE OR REPLACE FUNCTION "public"."full_name"("first" "text", "last" "text") RETURNS "text"
+    LANGUAGE "sql" IMMUTABLE
+    AS $$ select first || ' ' || last $$;
+`
+		var out bytes.Buffer
+		// Run test
+		err := writeOrderedSchema(strings.NewReader(sql), &out, RunParams{})
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, `CREATE OR REPLACE FUNCTION "public"."full_name"("first" "text", "last" "text") RETURNS "text"
+    LANGUAGE "sql" IMMUTABLE
+    AS $$ select first || ' ' || last $$;
+
+CREATE TABLE IF NOT EXISTS "public"."people" (
+    "first_name" "text",
+    "last_name" "text",
+    "full_name" "text" GENERATED ALWAYS AS ("public"."full_name"("first_name", "last_name")) STORED
+);
+`, out.String())
+	})
+}
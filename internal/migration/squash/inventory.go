@@ -0,0 +1,135 @@
+package squash
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/db/diff"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var ErrMissingObjects = errors.New("squashed migration is missing objects")
+
+const LIST_SCHEMA_OBJECTS = `
+SELECT n.nspname, c.relname, CASE c.relkind
+  WHEN 'v' THEN 'view' WHEN 'm' THEN 'materialized view' WHEN 'S' THEN 'sequence'
+  WHEN 'f' THEN 'foreign table' WHEN 'i' THEN 'index' WHEN 'I' THEN 'index' ELSE 'table' END
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p', 'v', 'm', 'S', 'f', 'i', 'I')
+UNION ALL
+SELECT n.nspname, p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')', 'function'
+FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+UNION ALL
+SELECT n.nspname, t.typname, 'type'
+FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace
+WHERE t.typtype IN ('e', 'd') OR (t.typtype = 'c' AND (SELECT relkind FROM pg_class WHERE oid = t.typrelid) = 'c')
+UNION ALL
+SELECT n.nspname, c.relname || '.' || t.tgname, 'trigger'
+FROM pg_trigger t JOIN pg_class c ON c.oid = t.tgrelid JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE NOT t.tgisinternal
+UNION ALL
+SELECT n.nspname, c.relname || '.' || p.polname, 'policy'
+FROM pg_policy p JOIN pg_class c ON c.oid = p.polrelid JOIN pg_namespace n ON n.oid = c.relnamespace
+`
+
+// Lists schema objects outside of internal and excluded schemas as "kind schema.name".
+func listObjects(ctx context.Context, conn *pgx.Conn, exclude []string) ([]string, error) {
+	rows, err := conn.Query(ctx, LIST_SCHEMA_OBJECTS)
+	if err != nil {
+		return nil, errors.Errorf("failed to list objects: %w", err)
+	}
+	defer rows.Close()
+	excluded := append(append([]string{}, utils.InternalSchemas...), exclude...)
+	var result []string
+	for rows.Next() {
+		var schema, name, kind string
+		if err := rows.Scan(&schema, &name, &kind); err != nil {
+			return nil, errors.Errorf("failed to scan object: %w", err)
+		}
+		if !matchesAny(schema, excluded) {
+			result = append(result, fmt.Sprintf("%s %s.%s", kind, schema, name))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Errorf("failed to list objects: %w", err)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Schema patterns use the same wildcards as pg_dump.
+func matchesAny(schema string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, schema); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Applies the squashed migration to a fresh shadow database and checks that every object
+// created by the original migrations still exists.
+func assertObjects(ctx context.Context, migrations []string, expected, exclude []string, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	fmt.Fprintln(os.Stderr, "Checking objects created by squashed migration...")
+	var actual []string
+	if err := applyOnShadow(ctx, diff.NewShadowOptions(), migrations, func(ctx context.Context, conn *pgx.Conn) (err error) {
+		actual, err = listObjects(ctx, conn, exclude)
+		return err
+	}, fsys, options...); err != nil {
+		return err
+	}
+	return diffObjects(expected, actual)
+}
+
+// Reads the current contents of paths, returning a function that writes them back and removes
+// paths that did not exist.
+func snapshotFiles(paths []string, fsys afero.Fs) (func() error, error) {
+	original := make(map[string][]byte, len(paths))
+	for _, name := range paths {
+		contents, err := afero.ReadFile(fsys, name)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, errors.Errorf("failed to read file: %w", err)
+		}
+		if err == nil {
+			original[name] = contents
+		}
+	}
+	return func() error {
+		for _, name := range paths {
+			if contents, ok := original[name]; ok {
+				if err := utils.WriteFile(name, contents, fsys); err != nil {
+					return errors.Errorf("failed to restore %s: %w", name, err)
+				}
+			} else if err := fsys.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return errors.Errorf("failed to remove %s: %w", name, err)
+			}
+		}
+		return nil
+	}, nil
+}
+
+func diffObjects(expected, actual []string) error {
+	found := make(map[string]bool, len(actual))
+	for _, name := range actual {
+		found[name] = true
+	}
+	var missing []string
+	for _, name := range expected {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		fmt.Fprintf(os.Stderr, "Squashed migration creates all %d objects.\n", len(expected))
+		return nil
+	}
+	utils.CmdSuggestion = "Merged migrations are kept because the squashed file would drop objects."
+	return errors.Errorf("%w: %d of %d\n  %s", ErrMissingObjects, len(missing), len(expected), strings.Join(missing, "\n  "))
+}
@@ -0,0 +1,77 @@
+package squash
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestListObjects(t *testing.T) {
+	t.Run("skips internal and excluded schemas", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(LIST_SCHEMA_OBJECTS).
+			Reply("SELECT 4",
+				[]interface{}{"public", "users", "table"},
+				[]interface{}{"auth", "users", "table"},
+				[]interface{}{"cron", "job", "table"},
+				[]interface{}{"public", "uid()", "function"},
+			)
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		objects, err := listObjects(ctx, mock, []string{"cron"})
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"function public.uid()", "table public.users"}, objects)
+	})
+}
+
+func TestDiffObjects(t *testing.T) {
+	t.Run("passes when all objects exist", func(t *testing.T) {
+		err := diffObjects([]string{"table public.users"}, []string{"table public.users", "view public.stats"})
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on missing objects", func(t *testing.T) {
+		err := diffObjects([]string{"table public.users", "trigger public.users.on_insert"}, []string{"table public.users"})
+		// Check error
+		assert.ErrorIs(t, err, ErrMissingObjects)
+		assert.ErrorContains(t, err, "1 of 2\n  trigger public.users.on_insert")
+	})
+}
+
+func TestSnapshotFiles(t *testing.T) {
+	t.Run("restores overwritten and created files", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		target := filepath.Join(utils.MigrationsDir, "1_target.sql")
+		require.NoError(t, afero.WriteFile(fsys, target, []byte("create table t ();"), 0644))
+		restore, err := snapshotFiles([]string{target, utils.SeedDataPath}, fsys)
+		require.NoError(t, err)
+		require.NoError(t, afero.WriteFile(fsys, target, []byte("-- squashed"), 0644))
+		require.NoError(t, afero.WriteFile(fsys, utils.SeedDataPath, []byte("insert into t values (1);"), 0644))
+		// Run test
+		err = restore()
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, target)
+		assert.NoError(t, err)
+		assert.Equal(t, "create table t ();", string(contents))
+		exists, err := afero.Exists(fsys, utils.SeedDataPath)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
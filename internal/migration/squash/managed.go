@@ -0,0 +1,106 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/supabase/cli/internal/db/diff"
+	"github.com/supabase/cli/internal/utils"
+	"github.com/supabase/cli/internal/utils/parser"
+)
+
+// Defaults to auth and storage when config is not loaded, because an empty list would dump all schemas.
+func managedSchemas() []string {
+	if schemas := utils.Config.Db.Squash.ManagedSchemas; len(schemas) > 0 {
+		return schemas
+	}
+	return utils.DefaultManagedSchemas
+}
+
+const (
+	managedSnapshot             = "squash_managed_snapshot"
+	TERMINATE_DATABASE_SESSIONS = "SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()"
+)
+
+// Returns the engine configured to diff managed schemas, or nil to diff their dumps line by line.
+func managedDiffer() diff.DiffFunc {
+	if engine := utils.Config.Db.Diff.Engine; len(engine) > 0 && engine != utils.DiffDump {
+		return diff.GetDiffer(engine)
+	}
+	return nil
+}
+
+// Copies the shadow database before migrations so that a database diff engine can compare managed
+// schemas against it. Sessions such as background workers are terminated because a template
+// database cannot be copied while in use.
+func snapshotDatabase(ctx context.Context, config pgconn.Config, options ...func(*pgx.ConnConfig)) error {
+	template := config
+	template.Database = "template1"
+	conn, err := utils.ConnectLocalPostgres(ctx, template, options...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, TERMINATE_DATABASE_SESSIONS, config.Database); err != nil {
+		return errors.Errorf("failed to terminate database sessions: %w", err)
+	}
+	sql := fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", pgx.Identifier{managedSnapshot}.Sanitize(), pgx.Identifier{config.Database}.Sanitize())
+	if _, err := conn.Exec(ctx, sql); err != nil {
+		return errors.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// Groups whole statements of a managed schema dump or diff by schema, in the order of
+// db.squash.managed_schemas, so that the appended section is stable regardless of how pg_dump
+// interleaves them. Statements without a listed schema are kept last. With sorted, statements
+// of each schema are also sorted.
+func orderManagedDiff(diffs io.Reader, schemas []string, sorted bool, w io.Writer) error {
+	stats, err := parser.Split(diffs, strings.TrimSpace)
+	if err != nil {
+		return err
+	}
+	rank := make(map[string]int, len(schemas))
+	for i, name := range schemas {
+		rank[name] = i
+	}
+	rankOf := func(sql string) int {
+		if matches := qualifiedPattern.FindStringSubmatch(sql); len(matches) > 1 {
+			if i, ok := rank[matches[1]]; ok {
+				return i
+			}
+		}
+		return len(schemas)
+	}
+	ranks := make([]int, len(stats))
+	for i, sql := range stats {
+		ranks[i] = rankOf(sql)
+	}
+	order := make([]int, len(stats))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if ranks[a] != ranks[b] {
+			return ranks[a] < ranks[b]
+		}
+		return sorted && stats[a] < stats[b]
+	})
+	var out bytes.Buffer
+	for _, i := range order {
+		out.WriteString(stats[i])
+		out.WriteString("\n\n")
+	}
+	if _, err := w.Write(out.Bytes()); err != nil {
+		return errors.Errorf("failed to write managed diff: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+)
+
+const (
+	storagePolicy = `CREATE POLICY "read" ON "storage"."objects" FOR SELECT USING (true);`
+	storageGrant  = `GRANT ALL ON TABLE "storage"."objects" TO "anon";`
+	authTrigger   = `CREATE OR REPLACE TRIGGER "on_signup" AFTER INSERT ON "auth"."users" FOR EACH ROW EXECUTE FUNCTION "public"."handle"();`
+	authFunction  = `CREATE FUNCTION "auth"."role_of"() RETURNS "text"
+    LANGUAGE "plpgsql"
+    AS $$
+begin
+  return 'anon';
+end;
+$$;`
+)
+
+func TestOrderManagedDiff(t *testing.T) {
+	t.Run("groups statements by schema order", func(t *testing.T) {
+		diffs := strings.Join([]string{storagePolicy, authTrigger, `SELECT 1;`, authFunction}, "\n\n") + "\n\n"
+		// Run test
+		var out bytes.Buffer
+		err := orderManagedDiff(strings.NewReader(diffs), []string{"auth", "storage"}, false, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Join([]string{authTrigger, authFunction, storagePolicy, `SELECT 1;`}, "\n\n")+"\n\n", out.String())
+	})
+
+	t.Run("produces stable output when sorted", func(t *testing.T) {
+		inputs := [][]string{
+			{storageGrant, authTrigger, storagePolicy, authFunction},
+			{authFunction, storagePolicy, authTrigger, storageGrant},
+		}
+		var outputs []string
+		for _, stats := range inputs {
+			var out bytes.Buffer
+			// Run test
+			err := orderManagedDiff(strings.NewReader(strings.Join(stats, "\n")), []string{"storage", "auth"}, true, &out)
+			// Check error
+			require.NoError(t, err)
+			outputs = append(outputs, out.String())
+		}
+		assert.Equal(t, outputs[0], outputs[1])
+		assert.Equal(t, strings.Join([]string{storagePolicy, storageGrant, authFunction, authTrigger}, "\n\n")+"\n\n", outputs[0])
+	})
+
+	t.Run("keeps empty diff", func(t *testing.T) {
+		var out bytes.Buffer
+		// Run test
+		err := orderManagedDiff(strings.NewReader("\n"), []string{"auth", "storage"}, true, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, out.String())
+	})
+}
+
+func TestAppendManagedDiff(t *testing.T) {
+	t.Run("orders statements before diffing", func(t *testing.T) {
+		changed := strings.Replace(authFunction, "'anon'", "'authenticated'", 1)
+		before := authFunction + "\n"
+		after := storagePolicy + "\n\n" + changed + "\n"
+		// Run test
+		var out bytes.Buffer
+		_, err := appendManagedDiff(strings.NewReader(before), strings.NewReader(after), []string{"auth", "storage"}, "", false, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, separatorComment+"  return 'authenticated';\nend;\n$$;\n\n"+storagePolicy+"\n\n", out.String())
+	})
+}
+
+func TestManagedDiffer(t *testing.T) {
+	t.Run("diffs dumps by default", func(t *testing.T) {
+		assert.Nil(t, managedDiffer())
+	})
+
+	t.Run("diffs dumps with dump engine", func(t *testing.T) {
+		utils.Config.Db.Diff.Engine = utils.DiffDump
+		defer func() { utils.Config.Db.Diff.Engine = "" }()
+		assert.Nil(t, managedDiffer())
+	})
+
+	t.Run("selects configured engine", func(t *testing.T) {
+		utils.Config.Db.Diff.Engine = utils.DiffMigra
+		defer func() { utils.Config.Db.Diff.Engine = "" }()
+		assert.NotNil(t, managedDiffer())
+	})
+}
+
+func TestSnapshotDatabase(t *testing.T) {
+	t.Run("copies database from template", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(TERMINATE_DATABASE_SESSIONS, "postgres").
+			Reply("SELECT 0").
+			Query(`CREATE DATABASE "squash_managed_snapshot" TEMPLATE "postgres"`).
+			Reply("CREATE DATABASE")
+		// Run test
+		err := snapshotDatabase(context.Background(), dbConfig, conn.Intercept)
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on copy failure", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(TERMINATE_DATABASE_SESSIONS, "postgres").
+			Reply("SELECT 0").
+			Query(`CREATE DATABASE "squash_managed_snapshot" TEMPLATE "postgres"`).
+			ReplyError(pgerrcode.ObjectInUse, `source database "postgres" is being accessed by other users`)
+		// Run test
+		err := snapshotDatabase(context.Background(), dbConfig, conn.Intercept)
+		// Check error
+		assert.ErrorContains(t, err, "failed to snapshot database:")
+	})
+}
+
+func TestAppendEngineDiff(t *testing.T) {
+	t.Run("diffs against snapshot", func(t *testing.T) {
+		var source string
+		differ := func(ctx context.Context, src, dst string, schema []string) (string, error) {
+			source = src
+			return storagePolicy + "\n", nil
+		}
+		var out bytes.Buffer
+		// Run test
+		stats, err := appendEngineDiff(context.Background(), differ, dbConfig, []string{"storage"}, "", &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Contains(t, source, "/"+managedSnapshot)
+		assert.Contains(t, out.String(), separatorComment+storagePolicy+"\n")
+		assert.NotEmpty(t, stats)
+	})
+
+	t.Run("throws error on diff failure", func(t *testing.T) {
+		errDiff := errors.New("diff failed")
+		differ := func(ctx context.Context, src, dst string, schema []string) (string, error) {
+			return "", errDiff
+		}
+		// Run test
+		_, err := appendEngineDiff(context.Background(), differ, dbConfig, []string{"storage"}, "", io.Discard)
+		// Check error
+		assert.ErrorIs(t, err, errDiff)
+	})
+}
@@ -0,0 +1,30 @@
+package squash
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/supabase/cli/internal/utils"
+)
+
+const maskPlaceholder = "REDACTED"
+
+// Replaces secrets matching any pattern with a placeholder, warning about each redaction.
+// Only the matched text is replaced so statements still apply with the placeholder value.
+func maskSecrets(contents []byte, patterns []string, w io.Writer) []byte {
+	for _, p := range patterns {
+		// Patterns are already validated when loading config
+		r, err := regexp.Compile(p)
+		if err != nil {
+			continue
+		}
+		for _, loc := range r.FindAllIndex(contents, -1) {
+			line := bytes.Count(contents[:loc[0]], []byte("\n")) + 1
+			fmt.Fprintf(w, "%s masked secret matching %s on line %d\n", utils.Yellow("WARNING:"), utils.Bold(p), line)
+		}
+		contents = r.ReplaceAllLiteral(contents, []byte(maskPlaceholder))
+	}
+	return contents
+}
@@ -0,0 +1,38 @@
+package squash
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskSecrets(t *testing.T) {
+	t.Run("masks matched secrets", func(t *testing.T) {
+		sql := `CREATE TABLE "public"."config" (
+    "key" text DEFAULT 'sk_live_abc123'::text
+);
+CREATE FUNCTION "public"."key"() RETURNS text LANGUAGE sql AS $$ select 'sk_live_def456' $$;
+`
+		var warnings bytes.Buffer
+		// Run test
+		masked := maskSecrets([]byte(sql), []string{`sk_live_[0-9a-zA-Z]+`}, &warnings)
+		// Check output
+		assert.Equal(t, `CREATE TABLE "public"."config" (
+    "key" text DEFAULT 'REDACTED'::text
+);
+CREATE FUNCTION "public"."key"() RETURNS text LANGUAGE sql AS $$ select 'REDACTED' $$;
+`, string(masked))
+		assert.Contains(t, warnings.String(), "on line 2\n")
+		assert.Contains(t, warnings.String(), "on line 4\n")
+	})
+
+	t.Run("ignores unmatched patterns", func(t *testing.T) {
+		var warnings bytes.Buffer
+		// Run test
+		masked := maskSecrets([]byte("select 1;"), []string{`sk_live_[0-9a-zA-Z]+`}, &warnings)
+		// Check output
+		assert.Equal(t, "select 1;", string(masked))
+		assert.Empty(t, warnings.String())
+	})
+}
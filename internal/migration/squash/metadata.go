@@ -0,0 +1,27 @@
+package squash
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supabase/cli/internal/utils"
+)
+
+// Records the provenance of a squashed baseline as comment lines, which MigrateUp
+// attaches to the first statement without executing anything.
+func metadataHeader(migrations []string, squashedAt time.Time) string {
+	versions := make([]string, len(migrations))
+	for i, name := range migrations {
+		versions[i] = utils.MigrateFilePattern.FindStringSubmatch(name)[1]
+	}
+	// Image tags include the supabase patch version, ie. 15.1.0.147
+	image := utils.Config.Db.Image
+	postgres := image[strings.LastIndex(image, ":")+1:]
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "-- supabase: cli version %s\n", utils.Version)
+	fmt.Fprintf(&sb, "-- supabase: postgres version %s\n", postgres)
+	fmt.Fprintf(&sb, "-- supabase: squashed at %s\n", squashedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&sb, "-- supabase: merged versions %s\n", strings.Join(versions, ", "))
+	return sb.String()
+}
@@ -0,0 +1,40 @@
+package squash
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/utils"
+	"github.com/supabase/cli/internal/utils/parser"
+)
+
+func TestMetadataHeader(t *testing.T) {
+	image := utils.Config.Db.Image
+	utils.Config.Db.Image = "supabase/postgres:15.1.0.147"
+	defer func() { utils.Config.Db.Image = image }()
+	squashedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("SGT", 8*60*60))
+
+	t.Run("records squash provenance", func(t *testing.T) {
+		// Run test
+		header := metadataHeader([]string{"0_init.sql", "1_target.sql"}, squashedAt)
+		// Check output
+		assert.Equal(t, "-- supabase: cli version "+utils.Version+`
+-- supabase: postgres version 15.1.0.147
+-- supabase: squashed at 2024-01-01T19:04:05Z
+-- supabase: merged versions 0, 1
+`, header)
+	})
+
+	t.Run("parses as comments of first statement", func(t *testing.T) {
+		sql := metadataHeader([]string{"0_init.sql"}, squashedAt) + "create schema a;\ncreate schema b;\n"
+		// Run test
+		stats, err := parser.SplitAndTrim(strings.NewReader(sql))
+		// Check error
+		require.NoError(t, err)
+		assert.Len(t, stats, 2)
+		assert.True(t, strings.HasSuffix(stats[0], "create schema a"))
+	})
+}
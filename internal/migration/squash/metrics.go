@@ -0,0 +1,111 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/go-errors/errors"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/utils"
+)
+
+const (
+	MetricsPrometheus = "prometheus"
+	MetricsJson       = "json"
+)
+
+// Time taken by a named phase of squashing.
+type PhaseTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Collects squash health metrics for CI dashboards. A nil collector records nothing.
+type squashMetrics struct {
+	Phases       []PhaseTiming
+	MemoryBytes  uint64
+	Statements   int
+	lastStart    time.Time
+	currentPhase string
+}
+
+// Ends the current phase, if any, and starts timing the named phase.
+func (m *squashMetrics) startPhase(name string) {
+	if m == nil {
+		return
+	}
+	m.endPhase()
+	m.currentPhase = name
+	m.lastStart = time.Now()
+}
+
+func (m *squashMetrics) endPhase() {
+	if m == nil || len(m.currentPhase) == 0 {
+		return
+	}
+	m.Phases = append(m.Phases, PhaseTiming{Name: m.currentPhase, Duration: time.Since(m.lastStart)})
+	m.currentPhase = ""
+}
+
+// Samples the memory used by the shadow container, printing a warning instead of failing on error.
+func (m *squashMetrics) sampleMemory(ctx context.Context, container string) {
+	if m == nil {
+		return
+	}
+	if err := m.readMemory(ctx, container); err != nil {
+		fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "skipped sampling shadow memory:", err)
+	}
+}
+
+func (m *squashMetrics) readMemory(ctx context.Context, container string) error {
+	resp, err := utils.Docker.ContainerStats(ctx, container, false)
+	if err != nil {
+		return errors.Errorf("failed to read container stats: %w", err)
+	}
+	defer resp.Body.Close()
+	var stats types.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return errors.Errorf("failed to parse container stats: %w", err)
+	}
+	// Peak usage is only reported on cgroup v1
+	usage := max(stats.MemoryStats.MaxUsage, stats.MemoryStats.Usage)
+	m.MemoryBytes = max(m.MemoryBytes, usage)
+	return nil
+}
+
+// Writes the collected metrics in Prometheus text exposition format or as JSON lines.
+func (m *squashMetrics) write(path, format string, fsys afero.Fs) error {
+	m.endPhase()
+	var out bytes.Buffer
+	if format == MetricsJson {
+		enc := json.NewEncoder(&out)
+		now := time.Now().UTC().Format(time.RFC3339)
+		for _, p := range m.Phases {
+			enc.Encode(map[string]any{"timestamp": now, "metric": "phase_duration_seconds", "phase": p.Name, "value": p.Duration.Seconds()})
+		}
+		enc.Encode(map[string]any{"timestamp": now, "metric": "shadow_memory_bytes", "value": m.MemoryBytes})
+		enc.Encode(map[string]any{"timestamp": now, "metric": "statements_applied", "value": m.Statements})
+	} else {
+		fmt.Fprintln(&out, "# HELP supabase_squash_phase_duration_seconds Time taken by each squash phase.")
+		fmt.Fprintln(&out, "# TYPE supabase_squash_phase_duration_seconds gauge")
+		for _, p := range m.Phases {
+			fmt.Fprintf(&out, "supabase_squash_phase_duration_seconds{phase=%q} %g\n", p.Name, p.Duration.Seconds())
+		}
+		fmt.Fprintln(&out, "# HELP supabase_squash_shadow_memory_bytes Peak memory used by the shadow database.")
+		fmt.Fprintln(&out, "# TYPE supabase_squash_shadow_memory_bytes gauge")
+		fmt.Fprintf(&out, "supabase_squash_shadow_memory_bytes %d\n", m.MemoryBytes)
+		fmt.Fprintln(&out, "# HELP supabase_squash_statements_applied Statements applied to the shadow database.")
+		fmt.Fprintln(&out, "# TYPE supabase_squash_statements_applied gauge")
+		fmt.Fprintf(&out, "supabase_squash_statements_applied %d\n", m.Statements)
+	}
+	if err := utils.WriteFile(path, out.Bytes(), fsys); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Wrote metrics to", utils.Bold(path))
+	return nil
+}
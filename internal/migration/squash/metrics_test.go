@@ -0,0 +1,116 @@
+package squash
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/testing/apitest"
+	"github.com/supabase/cli/internal/utils"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	metrics := squashMetrics{
+		Phases:      []PhaseTiming{{Name: "start", Duration: 1500 * time.Millisecond}},
+		MemoryBytes: 1024,
+		Statements:  3,
+	}
+
+	t.Run("writes prometheus text", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		err := metrics.write("metrics/squash.prom", MetricsPrometheus, fsys)
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, "metrics/squash.prom")
+		assert.NoError(t, err)
+		assert.Contains(t, string(contents), `supabase_squash_phase_duration_seconds{phase="start"} 1.5`)
+		assert.Contains(t, string(contents), "supabase_squash_shadow_memory_bytes 1024\n")
+		assert.Contains(t, string(contents), "supabase_squash_statements_applied 3\n")
+	})
+
+	t.Run("writes json lines", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		err := metrics.write("squash.jsonl", MetricsJson, fsys)
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, "squash.jsonl")
+		assert.NoError(t, err)
+		assert.Contains(t, string(contents), `"metric":"phase_duration_seconds","phase":"start","timestamp":`)
+		assert.Contains(t, string(contents), `{"metric":"statements_applied","timestamp":`)
+	})
+
+	t.Run("throws error on permission denied", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewReadOnlyFs(afero.NewMemMapFs())
+		// Run test
+		err := metrics.write("squash.prom", MetricsPrometheus, fsys)
+		// Check error
+		assert.Error(t, err)
+	})
+}
+
+func TestPhaseMetrics(t *testing.T) {
+	t.Run("records consecutive phases", func(t *testing.T) {
+		var metrics squashMetrics
+		// Run test
+		metrics.startPhase("start")
+		metrics.startPhase("migrate")
+		metrics.endPhase()
+		// Check output
+		require.Len(t, metrics.Phases, 2)
+		assert.Equal(t, "start", metrics.Phases[0].Name)
+		assert.Equal(t, "migrate", metrics.Phases[1].Name)
+	})
+
+	t.Run("ignores nil collector", func(t *testing.T) {
+		var metrics *squashMetrics
+		// Run test
+		metrics.startPhase("start")
+		metrics.sampleMemory(context.Background(), "test-shadow")
+	})
+}
+
+func TestSampleMemory(t *testing.T) {
+	t.Run("keeps peak memory usage", func(t *testing.T) {
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		var stats types.StatsJSON
+		stats.MemoryStats.Usage = 2048
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow/stats").
+			Reply(http.StatusOK).
+			JSON(stats)
+		metrics := squashMetrics{MemoryBytes: 4096}
+		// Run test
+		metrics.sampleMemory(context.Background(), "test-shadow")
+		// Check output
+		assert.Equal(t, uint64(4096), metrics.MemoryBytes)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("skips on docker error", func(t *testing.T) {
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow/stats").
+			Reply(http.StatusServiceUnavailable)
+		var metrics squashMetrics
+		// Run test
+		metrics.sampleMemory(context.Background(), "test-shadow")
+		// Check output
+		assert.Zero(t, metrics.MemoryBytes)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
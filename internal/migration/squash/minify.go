@@ -0,0 +1,133 @@
+package squash
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Strips comments and collapses whitespace outside of quoted strings, keeping one statement
+// per line. Function bodies in dollar quotes are kept verbatim so the output remains valid sql.
+func minifySchema(sql string) string {
+	runes := []rune(sql)
+	var out strings.Builder
+	// Whitespace is written lazily so that it is never emitted at line boundaries
+	space, lineStart := false, true
+	writeSpace := func() {
+		if space && !lineStart {
+			out.WriteRune(' ')
+		}
+		space, lineStart = false, false
+	}
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '-' && at(runes, i+1) == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			space = true
+		case r == '/' && at(runes, i+1) == '*':
+			end := skipBlockComment(runes, i)
+			if end < 0 {
+				end = len(runes) - 1
+			}
+			i = end
+			space = true
+		case unicode.IsSpace(r):
+			space = true
+		case r == ';':
+			out.WriteString(";\n")
+			space, lineStart = false, true
+		case r == '\'' || r == '"':
+			writeSpace()
+			escape := r == '\'' && unicode.ToUpper(at(runes, i-1)) == 'E' && !isIdentifierRune(at(runes, i-2))
+			end := skipQuote(runes, i, escape)
+			if end < 0 {
+				end = len(runes) - 1
+			}
+			out.WriteString(string(runes[i : end+1]))
+			i = end
+		case r == '$' && !isIdentifierRune(at(runes, i-1)):
+			writeSpace()
+			end := i
+			if tag := dollarTag(runes, i); len(tag) > 0 {
+				if end = skipDollarQuote(runes, i, tag); end < 0 {
+					end = len(runes) - 1
+				}
+			}
+			out.WriteString(string(runes[i : end+1]))
+			i = end
+		default:
+			writeSpace()
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// Returns the rune at i, or zero when out of range.
+func at(runes []rune, i int) rune {
+	if i < 0 || i >= len(runes) {
+		return 0
+	}
+	return runes[i]
+}
+
+func isIdentifierRune(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Each skip function returns the index of the closing delimiter, or -1 if unterminated.
+func skipBlockComment(runes []rune, start int) int {
+	depth := 0
+	for i := start; i+1 < len(runes); i++ {
+		if runes[i] == '/' && runes[i+1] == '*' {
+			depth++
+			i++
+		} else if runes[i] == '*' && runes[i+1] == '/' {
+			depth--
+			i++
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func skipQuote(runes []rune, start int, escape bool) int {
+	delimiter := runes[start]
+	for i := start + 1; i < len(runes); i++ {
+		if escape && runes[i] == '\\' {
+			i++
+		} else if runes[i] == delimiter {
+			// Doubled delimiters are escaped quotes
+			if at(runes, i+1) != delimiter {
+				return i
+			}
+			i++
+		}
+	}
+	return -1
+}
+
+func dollarTag(runes []rune, start int) []rune {
+	for i := start + 1; i < len(runes); i++ {
+		r := runes[i]
+		if r == '$' {
+			return runes[start : i+1]
+		}
+		if r != '_' && !unicode.IsLetter(r) && (i == start+1 || !unicode.IsDigit(r)) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func skipDollarQuote(runes []rune, start int, tag []rune) int {
+	for i := start + len(tag); i+len(tag) <= len(runes); i++ {
+		if string(runes[i:i+len(tag)]) == string(tag) {
+			return i + len(tag) - 1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,58 @@
+package squash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinifySchema(t *testing.T) {
+	t.Run("strips comments and collapses whitespace", func(t *testing.T) {
+		sql := `--
+-- Name: users; Type: TABLE
+--
+
+CREATE TABLE IF NOT EXISTS "public"."users" (
+    "id" bigint NOT NULL, /* primary key */
+    "name" text DEFAULT '  --  '::text
+);
+
+ALTER TABLE "public"."users" OWNER TO "postgres";
+`
+		// Run test
+		out := minifySchema(sql)
+		// Check output
+		assert.Equal(t, `CREATE TABLE IF NOT EXISTS "public"."users" ( "id" bigint NOT NULL, "name" text DEFAULT '  --  '::text );
+ALTER TABLE "public"."users" OWNER TO "postgres";
+`, out)
+		assert.NoError(t, validateSyntax(out))
+	})
+
+	t.Run("keeps function bodies verbatim", func(t *testing.T) {
+		sql := `CREATE FUNCTION "public"."f"() RETURNS int
+    LANGUAGE plpgsql
+    AS $$
+begin
+  -- keep me
+  return 1;
+end;
+$$;
+`
+		// Run test
+		out := minifySchema(sql)
+		// Check output
+		assert.Equal(t, `CREATE FUNCTION "public"."f"() RETURNS int LANGUAGE plpgsql AS $$
+begin
+  -- keep me
+  return 1;
+end;
+$$;
+`, out)
+	})
+
+	t.Run("keeps positional parameters", func(t *testing.T) {
+		out := minifySchema("SELECT  $1 ,\n$2;")
+		// Check output
+		assert.Equal(t, "SELECT $1 , $2;\n", out)
+	})
+}
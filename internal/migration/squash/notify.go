@@ -0,0 +1,103 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/google/shlex"
+	"github.com/supabase/cli/internal/utils"
+)
+
+const (
+	completionSuccess = "success"
+	completionFailure = "failure"
+
+	notifyTimeout = 10 * time.Second
+)
+
+type completionReport struct {
+	Status    string   `json:"status"`
+	Version   string   `json:"version,omitempty"`
+	Path      string   `json:"path,omitempty"`
+	Merged    []string `json:"merged,omitempty"`
+	Baselined bool     `json:"baselined"`
+	Error     string   `json:"error,omitempty"`
+}
+
+func newCompletionReport(version string, result *SquashResult, err error) completionReport {
+	report := completionReport{Status: completionSuccess, Version: version}
+	if result != nil {
+		report.Version = result.Version
+		report.Path = result.Path
+		report.Merged = result.Merged
+		report.Baselined = result.Baselined
+	}
+	if err != nil {
+		report.Status = completionFailure
+		report.Error = err.Error()
+	}
+	return report
+}
+
+// Posts the squash outcome as json to a webhook url, or pipes it to the stdin of a command.
+// Notifications are best effort so failures only print a warning.
+func notifyCompletion(ctx context.Context, target string, report completionReport) {
+	// Squash timeouts and cancellation must not prevent reporting the failure they caused
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+	body, err := json.Marshal(report)
+	if err == nil {
+		if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+			err = postWebhook(ctx, target, body)
+		} else {
+			err = execHook(ctx, target, body)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "failed to notify squash completion:", err)
+	}
+}
+
+func postWebhook(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("unexpected webhook status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func execHook(ctx context.Context, command string, body []byte) error {
+	args, err := shlex.Split(command)
+	if err != nil {
+		return errors.Errorf("failed to parse completion command: %w", err)
+	}
+	if len(args) == 0 {
+		return errors.New("missing completion command")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Errorf("failed to run %s: %w\n%s", args[0], err, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package squash
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const webhookUrl = "http://hooks.example.com"
+
+func TestNotifyCompletion(t *testing.T) {
+	t.Run("posts result to webhook", func(t *testing.T) {
+		defer gock.OffAll()
+		gock.New(webhookUrl).
+			Post("/squash").
+			MatchType("json").
+			JSON(completionReport{
+				Status:    completionSuccess,
+				Version:   "1",
+				Path:      "supabase/migrations/1_target.sql",
+				Merged:    []string{"0_init.sql"},
+				Baselined: true,
+			}).
+			Reply(http.StatusOK)
+		result := &SquashResult{
+			Version:   "1",
+			Path:      "supabase/migrations/1_target.sql",
+			Merged:    []string{"0_init.sql"},
+			Baselined: true,
+		}
+		// Run test
+		notifyCompletion(context.Background(), webhookUrl+"/squash", newCompletionReport("", result, nil))
+		// Check error
+		assert.Empty(t, gock.Pending())
+	})
+
+	t.Run("reports squash failure", func(t *testing.T) {
+		defer gock.OffAll()
+		gock.New(webhookUrl).
+			Post("/squash").
+			BodyString(`"status":"failure","version":"1","baselined":false,"error":"glob .+: file does not exist"`).
+			Reply(http.StatusOK)
+		// Run test
+		err := Run(context.Background(), "1", dbConfig, RunParams{OnComplete: webhookUrl + "/squash"}, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.Empty(t, gock.Pending())
+	})
+
+	t.Run("ignores webhook failure", func(t *testing.T) {
+		defer gock.OffAll()
+		gock.New(webhookUrl).
+			Post("/squash").
+			ReplyError(errors.New("network error"))
+		// Run test
+		notifyCompletion(context.Background(), webhookUrl+"/squash", completionReport{Status: completionSuccess})
+		// Check error
+		assert.Empty(t, gock.Pending())
+	})
+
+	t.Run("throws error on webhook status", func(t *testing.T) {
+		defer gock.OffAll()
+		gock.New(webhookUrl).
+			Post("/squash").
+			Reply(http.StatusServiceUnavailable)
+		// Run test
+		err := postWebhook(context.Background(), webhookUrl+"/squash", []byte("{}"))
+		// Check error
+		assert.ErrorContains(t, err, "unexpected webhook status 503")
+	})
+
+	t.Run("pipes result to command", func(t *testing.T) {
+		// Run test
+		err := execHook(context.Background(), "cat", []byte("{}"))
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on unterminated quote", func(t *testing.T) {
+		// Run test
+		err := execHook(context.Background(), `echo "done`, []byte("{}"))
+		// Check error
+		assert.ErrorContains(t, err, "failed to parse completion command")
+	})
+
+	t.Run("throws error on command failure", func(t *testing.T) {
+		// Run test
+		err := execHook(context.Background(), "false", []byte("{}"))
+		// Check error
+		assert.ErrorContains(t, err, "failed to run false")
+	})
+}
@@ -0,0 +1,55 @@
+package squash
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ownerPattern         = regexp.MustCompile(`(?is)^ALTER .+ OWNER TO ("[^"]+"|\w+)\s*;?\s*$`)
+	qualifiedNamePattern = regexp.MustCompile(`"[^"]+"\."[^"]+"`)
+)
+
+// Keeps only statements for objects owned by role. Each statement belongs to the first
+// schema qualified name it references, ie. the table of an index, policy, or grant.
+func filterOwner(stats []string, role string) []string {
+	excluded := map[string]bool{}
+	for _, sql := range stats {
+		body := strings.TrimSpace(commentLinePattern.ReplaceAllString(sql, ""))
+		matches := ownerPattern.FindStringSubmatch(body)
+		if len(matches) < 2 {
+			continue
+		}
+		if name := qualifiedNamePattern.FindString(body); len(name) > 0 && strings.Trim(matches[1], `"`) != role {
+			excluded[name] = true
+		}
+	}
+	if len(excluded) == 0 {
+		return stats
+	}
+	result := make([]string, 0, len(stats))
+	for _, sql := range stats {
+		body := commentLinePattern.ReplaceAllString(sql, "")
+		if name := qualifiedNamePattern.FindString(body); excluded[name] {
+			continue
+		}
+		result = append(result, sql)
+	}
+	return result
+}
+
+const SELECT_ROLE_NAME = "SELECT rolname FROM pg_roles WHERE rolname = $1"
+
+func assertRoleExists(ctx context.Context, conn *pgx.Conn, role string) error {
+	var rolname string
+	if err := conn.QueryRow(ctx, SELECT_ROLE_NAME, role).Scan(&rolname); errors.Is(err, pgx.ErrNoRows) {
+		return errors.Errorf("%w: %s", ErrMissingRole, role)
+	} else if err != nil {
+		return errors.Errorf("failed to check role: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package squash
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestFilterOwner(t *testing.T) {
+	t.Run("keeps objects owned by role", func(t *testing.T) {
+		stats := []string{
+			`SET statement_timeout = 0;`,
+			"\n\nCREATE SCHEMA IF NOT EXISTS \"app\";",
+			"\n\nCREATE OR REPLACE FUNCTION \"app\".\"now_utc\"() RETURNS timestamp LANGUAGE sql AS $$ select now() $$;",
+			"\n\nALTER FUNCTION \"app\".\"now_utc\"() OWNER TO \"postgres\";",
+			"\n\nCREATE TABLE IF NOT EXISTS \"app\".\"todos\" (\"id\" bigint);",
+			"\n\nALTER TABLE \"app\".\"todos\" OWNER TO \"app\";",
+			"\n\nCREATE TABLE IF NOT EXISTS \"app\".\"audit\" (\"id\" bigint);",
+			"\n\nALTER TABLE \"app\".\"audit\" OWNER TO \"supabase_admin\";",
+			"\n\nCREATE INDEX \"audit_idx\" ON \"app\".\"audit\" USING btree (\"id\");",
+			"\n\nALTER TABLE ONLY \"app\".\"todos\"\n    ADD CONSTRAINT \"todos_audit_fkey\" FOREIGN KEY (\"id\") REFERENCES \"app\".\"audit\"(\"id\");",
+			"\n\nGRANT ALL ON TABLE \"app\".\"audit\" TO \"anon\";",
+			"\n\nRESET ALL;\n",
+		}
+		// Run test
+		result := filterOwner(stats, "app")
+		// Check output
+		assert.Equal(t, []string{
+			`SET statement_timeout = 0;`,
+			"\n\nCREATE SCHEMA IF NOT EXISTS \"app\";",
+			"\n\nCREATE TABLE IF NOT EXISTS \"app\".\"todos\" (\"id\" bigint);",
+			"\n\nALTER TABLE \"app\".\"todos\" OWNER TO \"app\";",
+			"\n\nALTER TABLE ONLY \"app\".\"todos\"\n    ADD CONSTRAINT \"todos_audit_fkey\" FOREIGN KEY (\"id\") REFERENCES \"app\".\"audit\"(\"id\");",
+			"\n\nRESET ALL;\n",
+		}, result)
+	})
+}
+
+func TestAssertRoleExists(t *testing.T) {
+	t.Run("throws error on missing role", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(SELECT_ROLE_NAME, "app").
+			Reply("SELECT 0")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = assertRoleExists(ctx, mock, "app")
+		// Check error
+		assert.ErrorIs(t, err, ErrMissingRole)
+	})
+}
@@ -0,0 +1,68 @@
+package squash
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/supabase/cli/internal/utils/parser"
+)
+
+var (
+	createTablePattern     = regexp.MustCompile(`(?i)^\s*CREATE TABLE (?:IF NOT EXISTS )?("[^"]+"\."[^"]+")`)
+	attachPartitionPattern = regexp.MustCompile(`(?i)^\s*ALTER TABLE (?:ONLY )?("[^"]+"\."[^"]+") ATTACH PARTITION ("[^"]+"\."[^"]+")`)
+)
+
+// Writes the dumped schema with statements placed after the schemas and tables
+// they reference, each ATTACH PARTITION statement placed after
+// both its parent and partition tables are created, and functions used by
+// generated columns placed before their tables, and triggers placed after their
+// functions and the views those functions reference. Objects not owned by params.Owner
+// are dropped and pretty output adds section headers.
+func writeOrderedSchema(r io.Reader, w io.Writer, params RunParams) error {
+	// Statements are split without trimming so the output is otherwise unchanged
+	stats, err := parser.Split(r)
+	if err != nil {
+		return err
+	}
+	if len(params.Owner) > 0 {
+		stats = filterOwner(stats, params.Owner)
+	}
+	stats = orderTriggers(orderGeneratedColumns(orderPartitions(orderReferences(stats))))
+	if params.Pretty {
+		stats = annotateSections(stats)
+	}
+	if _, err := io.WriteString(w, strings.Join(stats, "")); err != nil {
+		return errors.Errorf("failed to write schema: %w", err)
+	}
+	return nil
+}
+
+func orderPartitions(stats []string) []string {
+	created := map[string]int{}
+	for i, sql := range stats {
+		if matches := createTablePattern.FindStringSubmatch(sql); len(matches) > 1 {
+			created[matches[1]] = i
+		}
+	}
+	deferred := map[int][]string{}
+	result := make([]string, 0, len(stats))
+	for i, sql := range stats {
+		if matches := attachPartitionPattern.FindStringSubmatch(sql); len(matches) > 2 {
+			last := i
+			for _, table := range matches[1:] {
+				if j, ok := created[table]; ok && j > last {
+					last = j
+				}
+			}
+			if last > i {
+				deferred[last] = append(deferred[last], sql)
+				continue
+			}
+		}
+		result = append(result, sql)
+		result = append(result, deferred[i]...)
+	}
+	return result
+}
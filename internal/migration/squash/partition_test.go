@@ -0,0 +1,72 @@
+package squash
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionOrder(t *testing.T) {
+	t.Run("moves attach after partition is created", func(t *testing.T) {
+		sql := `CREATE TABLE IF NOT EXISTS "public"."measurement" (
+    "logdate" "date" NOT NULL
+)
+PARTITION BY RANGE ("logdate");
+
+ALTER TABLE ONLY "public"."measurement" ATTACH PARTITION "public"."measurement_y2023" FOR VALUES FROM ('2023-01-01') TO ('2024-01-01');
+
+ALTER TABLE ONLY "public"."measurement" ATTACH PARTITION "public"."measurement_y2024" FOR VALUES FROM ('2024-01-01') TO ('2025-01-01');
+
+CREATE TABLE IF NOT EXISTS "public"."measurement_y2023" (
+    "logdate" "date" NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "public"."measurement_y2024" (
+    "logdate" "date" NOT NULL
+);
+
+RESET ALL;
+`
+		var out bytes.Buffer
+		// Run test
+		err := writeOrderedSchema(strings.NewReader(sql), &out, RunParams{})
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, `CREATE TABLE IF NOT EXISTS "public"."measurement" (
+    "logdate" "date" NOT NULL
+)
+PARTITION BY RANGE ("logdate");
+
+CREATE TABLE IF NOT EXISTS "public"."measurement_y2023" (
+    "logdate" "date" NOT NULL
+);
+
+ALTER TABLE ONLY "public"."measurement" ATTACH PARTITION "public"."measurement_y2023" FOR VALUES FROM ('2023-01-01') TO ('2024-01-01');
+
+CREATE TABLE IF NOT EXISTS "public"."measurement_y2024" (
+    "logdate" "date" NOT NULL
+);
+
+ALTER TABLE ONLY "public"."measurement" ATTACH PARTITION "public"."measurement_y2024" FOR VALUES FROM ('2024-01-01') TO ('2025-01-01');
+
+RESET ALL;
+`, out.String())
+	})
+
+	t.Run("preserves dump already in order", func(t *testing.T) {
+		sql := `CREATE TABLE IF NOT EXISTS "public"."measurement" ("logdate" "date") PARTITION BY RANGE ("logdate");
+
+CREATE TABLE IF NOT EXISTS "public"."measurement_y2023" ("logdate" "date");
+
+ALTER TABLE ONLY "public"."measurement" ATTACH PARTITION "public"."measurement_y2023" FOR VALUES FROM ('2023-01-01') TO ('2024-01-01');
+`
+		var out bytes.Buffer
+		// Run test
+		err := writeOrderedSchema(strings.NewReader(sql), &out, RunParams{})
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, sql, out.String())
+	})
+}
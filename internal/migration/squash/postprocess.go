@@ -0,0 +1,117 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/go-errors/errors"
+	"github.com/google/shlex"
+	"github.com/supabase/cli/internal/utils"
+)
+
+// Transforms the squashed migration before it is written. Processors run in sequence,
+// each reading the output of the previous one.
+type SquashPostProcessor interface {
+	Process(in io.Reader, out io.Writer) error
+}
+
+// Adapts an ordinary function to the SquashPostProcessor interface.
+type PostProcessorFunc func(in io.Reader, out io.Writer) error
+
+func (f PostProcessorFunc) Process(in io.Reader, out io.Writer) error {
+	return f(in, out)
+}
+
+// Masks secrets first so that they are never passed to external processors.
+func newPostProcessors(params RunParams) []SquashPostProcessor {
+	var result []SquashPostProcessor
+	if !params.FdwCredentials {
+		result = append(result, fdwCredentialProcessor{warn: os.Stderr})
+	}
+	result = append(result, maskProcessor{
+		patterns: utils.Config.Db.Squash.MaskSecrets,
+		warn:     os.Stderr,
+	})
+	if params.NormalizeDefaults {
+		result = append(result, PostProcessorFunc(normalizeDefaults))
+	}
+	if params.Format == FormatMinified {
+		result = append(result, PostProcessorFunc(minifyProcessor))
+	}
+	return append(result, params.PostProcessors...)
+}
+
+func postProcess(contents []byte, processors []SquashPostProcessor) ([]byte, error) {
+	for _, p := range processors {
+		var out bytes.Buffer
+		if err := p.Process(bytes.NewReader(contents), &out); err != nil {
+			return nil, err
+		}
+		contents = out.Bytes()
+	}
+	return contents, nil
+}
+
+type maskProcessor struct {
+	patterns []string
+	warn     io.Writer
+}
+
+func (m maskProcessor) Process(in io.Reader, out io.Writer) error {
+	contents, err := io.ReadAll(in)
+	if err != nil {
+		return errors.Errorf("failed to read squashed migration: %w", err)
+	}
+	if _, err := out.Write(maskSecrets(contents, m.patterns, m.warn)); err != nil {
+		return errors.Errorf("failed to write masked migration: %w", err)
+	}
+	return nil
+}
+
+func minifyProcessor(in io.Reader, out io.Writer) error {
+	contents, err := io.ReadAll(in)
+	if err != nil {
+		return errors.Errorf("failed to read squashed migration: %w", err)
+	}
+	if _, err := io.WriteString(out, minifySchema(string(contents))); err != nil {
+		return errors.Errorf("failed to write minified migration: %w", err)
+	}
+	return nil
+}
+
+type execProcessor struct {
+	ctx  context.Context
+	args []string
+	err  error
+}
+
+// Pipes the squashed migration through an external executable, ie. "sqlfluff fix -". Arguments
+// are split with shell quoting rules, but the command is not run by a shell.
+func NewExecProcessor(ctx context.Context, command string) SquashPostProcessor {
+	args, err := shlex.Split(command)
+	if err != nil {
+		err = errors.Errorf("failed to parse post-processor command: %w", err)
+	}
+	return execProcessor{ctx: ctx, args: args, err: err}
+}
+
+func (e execProcessor) Process(in io.Reader, out io.Writer) error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.args) == 0 {
+		return errors.New("missing post-processor command")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(e.ctx, e.args[0], e.args[1:]...)
+	cmd.Stdin = in
+	cmd.Stdout = out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Errorf("failed to run post-processor %s: %w\n%s", e.args[0], err, stderr.String())
+	}
+	return nil
+}
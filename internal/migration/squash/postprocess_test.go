@@ -0,0 +1,111 @@
+package squash
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostProcess(t *testing.T) {
+	t.Run("runs processors in sequence", func(t *testing.T) {
+		upper := PostProcessorFunc(func(in io.Reader, out io.Writer) error {
+			contents, err := io.ReadAll(in)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(out, strings.ToUpper(string(contents)))
+			return err
+		})
+		processors := newPostProcessors(RunParams{
+			Format:         FormatMinified,
+			PostProcessors: []SquashPostProcessor{upper},
+		})
+		// Run test
+		processed, err := postProcess([]byte("-- comment\ncreate table a(\n  id int\n);\n"), processors)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "CREATE TABLE A( ID INT );\n", string(processed))
+	})
+
+	t.Run("masks secrets before custom processors", func(t *testing.T) {
+		var seen string
+		capture := PostProcessorFunc(func(in io.Reader, out io.Writer) error {
+			contents, err := io.ReadAll(in)
+			seen = string(contents)
+			return err
+		})
+		processors := []SquashPostProcessor{
+			maskProcessor{patterns: []string{`sk_live_[0-9a-zA-Z]+`}, warn: io.Discard},
+			capture,
+		}
+		// Run test
+		_, err := postProcess([]byte("select 'sk_live_abc123';"), processors)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "select 'REDACTED';", seen)
+	})
+
+	t.Run("throws error on processor failure", func(t *testing.T) {
+		errProcessor := PostProcessorFunc(func(in io.Reader, out io.Writer) error {
+			return io.ErrUnexpectedEOF
+		})
+		// Run test
+		_, err := postProcess([]byte("select 1;"), []SquashPostProcessor{errProcessor})
+		// Check error
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
+
+func TestExecProcessor(t *testing.T) {
+	t.Run("pipes migration through command", func(t *testing.T) {
+		if _, err := exec.LookPath("tr"); err != nil {
+			t.Skip("tr not found")
+		}
+		processor := NewExecProcessor(context.Background(), "tr a-z A-Z")
+		// Run test
+		processed, err := postProcess([]byte("select 1;"), []SquashPostProcessor{processor})
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT 1;", string(processed))
+	})
+
+	t.Run("keeps quoted arguments", func(t *testing.T) {
+		if _, err := exec.LookPath("sed"); err != nil {
+			t.Skip("sed not found")
+		}
+		processor := NewExecProcessor(context.Background(), `sed "s/select 1/select 'a b'/"`)
+		// Run test
+		processed, err := postProcess([]byte("select 1;"), []SquashPostProcessor{processor})
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "select 'a b';", string(processed))
+	})
+
+	t.Run("throws error on unterminated quote", func(t *testing.T) {
+		processor := NewExecProcessor(context.Background(), `sed "s/a/b/`)
+		// Run test
+		_, err := postProcess([]byte("select 1;"), []SquashPostProcessor{processor})
+		// Check error
+		assert.ErrorContains(t, err, "failed to parse post-processor command")
+	})
+
+	t.Run("throws error on missing command", func(t *testing.T) {
+		processor := NewExecProcessor(context.Background(), "  ")
+		// Run test
+		_, err := postProcess([]byte("select 1;"), []SquashPostProcessor{processor})
+		// Check error
+		assert.ErrorContains(t, err, "missing post-processor command")
+	})
+
+	t.Run("throws error on command failure", func(t *testing.T) {
+		processor := NewExecProcessor(context.Background(), "supabase-missing-processor")
+		// Run test
+		_, err := postProcess([]byte("select 1;"), []SquashPostProcessor{processor})
+		// Check error
+		assert.ErrorIs(t, err, exec.ErrNotFound)
+	})
+}
@@ -0,0 +1,64 @@
+package squash
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type section struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// Ordered by precedence since some statements, like ALTER TABLE, match several sections.
+var sections = []section{
+	{"", regexp.MustCompile(`(?is)^(SET|RESET|SELECT pg_catalog\.set_config|COMMENT ON|ALTER \w+(?: \w+)? .+ OWNER TO)\b`)},
+	{"Privileges", regexp.MustCompile(`(?i)^(GRANT|REVOKE|ALTER DEFAULT PRIVILEGES)\b`)},
+	{"Policies", regexp.MustCompile(`(?is)^(CREATE POLICY|ALTER TABLE .+ (ENABLE|FORCE) ROW LEVEL SECURITY)\b`)},
+	{"Constraints", regexp.MustCompile(`(?is)^ALTER TABLE .+ ADD CONSTRAINT\b`)},
+	{"Schemas", regexp.MustCompile(`(?i)^CREATE SCHEMA\b`)},
+	{"Extensions", regexp.MustCompile(`(?i)^CREATE EXTENSION\b`)},
+	{"Types", regexp.MustCompile(`(?i)^(CREATE|ALTER) (TYPE|DOMAIN)\b`)},
+	{"Functions", regexp.MustCompile(`(?i)^(CREATE (OR REPLACE )?(FUNCTION|PROCEDURE|AGGREGATE)|ALTER (FUNCTION|PROCEDURE))\b`)},
+	{"Sequences", regexp.MustCompile(`(?i)^(CREATE|ALTER) SEQUENCE\b`)},
+	{"Views", regexp.MustCompile(`(?i)^(CREATE (OR REPLACE )?(MATERIALIZED )?VIEW|ALTER (MATERIALIZED )?VIEW)\b`)},
+	{"Indexes", regexp.MustCompile(`(?i)^CREATE (UNIQUE )?INDEX\b`)},
+	{"Triggers", regexp.MustCompile(`(?i)^(CREATE (OR REPLACE )?(CONSTRAINT )?(EVENT )?TRIGGER)\b`)},
+	{"Publications", regexp.MustCompile(`(?i)^(CREATE|ALTER) PUBLICATION\b`)},
+	{"Tables", regexp.MustCompile(`(?i)^(CREATE|ALTER) (UNLOGGED |FOREIGN )?TABLE\b`)},
+}
+
+var commentLinePattern = regexp.MustCompile(`(?m)^\s*--.*$`)
+
+// Returns the section of a dumped statement, or empty string if it belongs to the preceding section.
+func classifyStatement(sql string) string {
+	body := strings.TrimSpace(commentLinePattern.ReplaceAllString(sql, ""))
+	for _, s := range sections {
+		if s.pattern.MatchString(body) {
+			return s.name
+		}
+	}
+	return ""
+}
+
+// Inserts a header comment whenever the section of consecutive statements changes.
+// Statements are never reordered because pg_dump already sorts them by dependency.
+func annotateSections(stats []string) []string {
+	result := make([]string, 0, len(stats))
+	var current string
+	for _, sql := range stats {
+		if name := classifyStatement(sql); len(name) > 0 && name != current {
+			lead, body := splitLeadingSpace(sql)
+			sql = lead + "--\n-- " + name + "\n--\n\n" + body
+			current = name
+		}
+		result = append(result, sql)
+	}
+	return result
+}
+
+func splitLeadingSpace(sql string) (string, string) {
+	body := strings.TrimLeftFunc(sql, unicode.IsSpace)
+	return sql[:len(sql)-len(body)], body
+}
@@ -0,0 +1,101 @@
+package squash
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotateSections(t *testing.T) {
+	t.Run("inserts headers between groups", func(t *testing.T) {
+		sql := `SET statement_timeout = 0;
+
+CREATE OR REPLACE FUNCTION "public"."now_utc"() RETURNS timestamp
+    LANGUAGE "sql"
+    AS $$ select now() $$;
+
+ALTER FUNCTION "public"."now_utc"() OWNER TO "postgres";
+
+CREATE TABLE IF NOT EXISTS "public"."todos" (
+    "id" bigint NOT NULL
+);
+
+ALTER TABLE "public"."todos" OWNER TO "postgres";
+
+COMMENT ON TABLE "public"."todos" IS 'Things to do';
+
+CREATE TABLE IF NOT EXISTS "public"."tags" (
+    "id" bigint NOT NULL
+);
+
+ALTER TABLE ONLY "public"."todos"
+    ADD CONSTRAINT "todos_pkey" PRIMARY KEY ("id");
+
+CREATE POLICY "read" ON "public"."todos" FOR SELECT USING (true);
+
+ALTER TABLE "public"."todos" ENABLE ROW LEVEL SECURITY;
+
+GRANT ALL ON TABLE "public"."todos" TO "anon";
+
+RESET ALL;
+`
+		var out bytes.Buffer
+		// Run test
+		err := writeOrderedSchema(strings.NewReader(sql), &out, RunParams{Pretty: true})
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, `SET statement_timeout = 0;
+
+--
+-- Functions
+--
+
+CREATE OR REPLACE FUNCTION "public"."now_utc"() RETURNS timestamp
+    LANGUAGE "sql"
+    AS $$ select now() $$;
+
+ALTER FUNCTION "public"."now_utc"() OWNER TO "postgres";
+
+--
+-- Tables
+--
+
+CREATE TABLE IF NOT EXISTS "public"."todos" (
+    "id" bigint NOT NULL
+);
+
+ALTER TABLE "public"."todos" OWNER TO "postgres";
+
+COMMENT ON TABLE "public"."todos" IS 'Things to do';
+
+CREATE TABLE IF NOT EXISTS "public"."tags" (
+    "id" bigint NOT NULL
+);
+
+--
+-- Constraints
+--
+
+ALTER TABLE ONLY "public"."todos"
+    ADD CONSTRAINT "todos_pkey" PRIMARY KEY ("id");
+
+--
+-- Policies
+--
+
+CREATE POLICY "read" ON "public"."todos" FOR SELECT USING (true);
+
+ALTER TABLE "public"."todos" ENABLE ROW LEVEL SECURITY;
+
+--
+-- Privileges
+--
+
+GRANT ALL ON TABLE "public"."todos" TO "anon";
+
+RESET ALL;
+`, out.String())
+	})
+}
@@ -0,0 +1,37 @@
+package squash
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/branches/get"
+	"github.com/supabase/cli/internal/utils"
+	"github.com/supabase/cli/internal/utils/flags"
+)
+
+var ErrBranchUnreachable = errors.New("preview branch database is unreachable")
+
+// Resolves the preview branch database of the linked project, checking that it accepts
+// connections before any migration files are squashed.
+func resolveBranch(ctx context.Context, branch string, fsys afero.Fs, options ...func(*pgx.ConnConfig)) (pgconn.Config, error) {
+	ref, err := flags.LoadProjectRef(fsys)
+	if err != nil {
+		return pgconn.Config{}, err
+	}
+	config, err := get.GetDbConfig(ctx, ref, branch)
+	if err != nil {
+		return config, err
+	}
+	fmt.Fprintln(os.Stderr, "Connecting to preview branch", utils.Bold(branch)+"...")
+	conn, err := utils.ConnectByConfig(ctx, config, options...)
+	if err != nil {
+		return config, errors.Errorf("%w: %s: %w", ErrBranchUnreachable, branch, err)
+	}
+	conn.Close(context.Background())
+	return config, nil
+}
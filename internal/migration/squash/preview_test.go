@@ -0,0 +1,166 @@
+package squash
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/branches/get"
+	"github.com/supabase/cli/internal/testing/apitest"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+	"github.com/supabase/cli/pkg/api"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestResolveBranch(t *testing.T) {
+	user, password := "postgres", "branch-password"
+	branches := []api.BranchResponse{
+		{Id: "main-uuid", Name: "main"},
+		{Id: "test-uuid", Name: "feature"},
+	}
+
+	t.Run("resolves preview branch by name", func(t *testing.T) {
+		ref := apitest.RandomProjectRef()
+		t.Setenv("SUPABASE_ACCESS_TOKEN", string(apitest.RandomAccessToken(t)))
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, utils.ProjectRefPath, []byte(ref), 0644))
+		// Setup mock api
+		defer gock.OffAll()
+		gock.New(utils.DefaultApiHost).
+			Get("/v1/projects/" + ref + "/branches").
+			Reply(http.StatusOK).
+			JSON(branches)
+		gock.New(utils.DefaultApiHost).
+			Get("/v1/branches/test-uuid").
+			Reply(http.StatusOK).
+			JSON(api.BranchDetailResponse{
+				DbHost: "db.branch.supabase.co",
+				DbPort: 5432,
+				DbUser: &user,
+				DbPass: &password,
+			})
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		// Run test
+		config, err := resolveBranch(context.Background(), "feature", fsys, conn.Intercept)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "db.branch.supabase.co", config.Host)
+		assert.Equal(t, uint16(5432), config.Port)
+		assert.Equal(t, password, config.Password)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("throws error on unknown branch", func(t *testing.T) {
+		ref := apitest.RandomProjectRef()
+		t.Setenv("SUPABASE_ACCESS_TOKEN", string(apitest.RandomAccessToken(t)))
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, utils.ProjectRefPath, []byte(ref), 0644))
+		// Setup mock api
+		defer gock.OffAll()
+		gock.New(utils.DefaultApiHost).
+			Get("/v1/projects/" + ref + "/branches").
+			Reply(http.StatusOK).
+			JSON(branches)
+		// Run test
+		_, err := resolveBranch(context.Background(), "missing", fsys)
+		// Check error
+		assert.ErrorIs(t, err, get.ErrBranchNotFound)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("throws error on unreachable database", func(t *testing.T) {
+		ref := apitest.RandomProjectRef()
+		t.Setenv("SUPABASE_ACCESS_TOKEN", string(apitest.RandomAccessToken(t)))
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, utils.ProjectRefPath, []byte(ref), 0644))
+		// Setup mock api
+		defer gock.OffAll()
+		gock.New(utils.DefaultApiHost).
+			Get("/v1/projects/" + ref + "/branches").
+			Reply(http.StatusOK).
+			JSON(branches)
+		gock.New(utils.DefaultApiHost).
+			Get("/v1/branches/test-uuid").
+			Reply(http.StatusOK).
+			JSON(api.BranchDetailResponse{
+				DbHost: "db.branch.supabase.co",
+				DbUser: &user,
+				DbPass: &password,
+			})
+		// Run test
+		_, err := resolveBranch(context.Background(), "test-uuid", fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrBranchUnreachable)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("throws error on missing credentials", func(t *testing.T) {
+		ref := apitest.RandomProjectRef()
+		t.Setenv("SUPABASE_ACCESS_TOKEN", string(apitest.RandomAccessToken(t)))
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, utils.ProjectRefPath, []byte(ref), 0644))
+		// Setup mock api
+		defer gock.OffAll()
+		gock.New(utils.DefaultApiHost).
+			Get("/v1/projects/" + ref + "/branches").
+			Reply(http.StatusOK).
+			JSON(branches)
+		gock.New(utils.DefaultApiHost).
+			Get("/v1/branches/test-uuid").
+			Reply(http.StatusOK).
+			JSON(api.BranchDetailResponse{Status: "CREATING_PROJECT"})
+		// Run test
+		_, err := resolveBranch(context.Background(), "feature", fsys)
+		// Check error
+		assert.ErrorContains(t, err, "missing database credentials of preview branch feature: CREATING_PROJECT")
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
+
+func TestSquashBranch(t *testing.T) {
+	user, password := "postgres", "branch-password"
+
+	t.Run("throws error on preview branch without terminal", func(t *testing.T) {
+		ref := apitest.RandomProjectRef()
+		t.Setenv("SUPABASE_ACCESS_TOKEN", string(apitest.RandomAccessToken(t)))
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		require.NoError(t, afero.WriteFile(fsys, utils.ProjectRefPath, []byte(ref), 0644))
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "0_init.sql"), []byte("create schema test"), 0644))
+		// Setup mock api
+		defer gock.OffAll()
+		gock.New(utils.DefaultApiHost).
+			Get("/v1/projects/" + ref + "/branches").
+			Reply(http.StatusOK).
+			JSON([]api.BranchResponse{{Id: "test-uuid", Name: "feature"}})
+		gock.New(utils.DefaultApiHost).
+			Get("/v1/branches/test-uuid").
+			Reply(http.StatusOK).
+			JSON(api.BranchDetailResponse{
+				DbHost: "db.branch.supabase.co",
+				DbPort: 5432,
+				DbUser: &user,
+				DbPass: &password,
+			})
+		// Setup mock postgres without history queries
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		// Run test
+		_, err := RunWithResult(context.Background(), "0", dbConfig, RunParams{Branch: "feature"}, fsys, conn.Intercept)
+		// Check error
+		assert.ErrorIs(t, err, utils.ErrNotTerminal)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
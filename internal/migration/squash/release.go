@@ -0,0 +1,73 @@
+package squash
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/repair"
+)
+
+const (
+	releasePrefix      = "-- supabase: release "
+	ADD_RELEASE_COLUMN = "ALTER TABLE supabase_migrations.schema_migrations ADD COLUMN IF NOT EXISTS release text"
+	UPDATE_RELEASE     = "UPDATE supabase_migrations.schema_migrations SET release = $1 WHERE version = $2"
+)
+
+var (
+	ErrInvalidRelease = errors.New("invalid release identifier")
+	// Allows semver and branch style tags, ie. v2.0.0-rc.1 or release/2024-01
+	releasePattern = regexp.MustCompile(`^[A-Za-z0-9][\w.+/-]{0,127}$`)
+)
+
+func assertRelease(release string) error {
+	if len(release) == 0 || releasePattern.MatchString(release) {
+		return nil
+	}
+	return errors.Errorf("%w: %s", ErrInvalidRelease, release)
+}
+
+// Finds the release recorded in the header comments of a squashed migration.
+func readRelease(version string, fsys afero.Fs) (string, error) {
+	path, err := repair.GetMigrationFile(version, fsys)
+	if err != nil {
+		return "", err
+	}
+	f, err := fsys.Open(path)
+	if err != nil {
+		return "", errors.Errorf("failed to open migration file: %w", err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "--") {
+			return "", nil
+		}
+		if strings.HasPrefix(line, releasePrefix) {
+			return strings.TrimSpace(strings.TrimPrefix(line, releasePrefix)), nil
+		}
+		if errors.Is(err, io.EOF) {
+			return "", nil
+		} else if err != nil {
+			return "", errors.Errorf("failed to read migration file: %w", err)
+		}
+	}
+}
+
+// Queues the release update of the baseline row, returning the statements that add the release
+// column. They run before the batch in the same transaction because the update cannot be prepared
+// before the column exists.
+func queueRelease(batch *pgx.Batch, version string, fsys afero.Fs) ([]string, error) {
+	release, err := readRelease(version, fsys)
+	if err != nil || len(release) == 0 {
+		return nil, err
+	}
+	batch.Queue(UPDATE_RELEASE, release, version)
+	return []string{ADD_RELEASE_COLUMN}, nil
+}
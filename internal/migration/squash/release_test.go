@@ -0,0 +1,107 @@
+package squash
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestAssertRelease(t *testing.T) {
+	t.Run("accepts tag strings", func(t *testing.T) {
+		for _, release := range []string{"", "v2.0.0", "v2.0.0-rc.1+build.5", "release/2024-01"} {
+			assert.NoError(t, assertRelease(release), release)
+		}
+	})
+
+	t.Run("throws error on invalid tag", func(t *testing.T) {
+		for _, release := range []string{"-v2", "v2 0", "v2\n-- drop", "v2;"} {
+			assert.ErrorIs(t, assertRelease(release), ErrInvalidRelease, release)
+		}
+	})
+}
+
+func TestReadRelease(t *testing.T) {
+	path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+
+	t.Run("reads release from header", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, writeSquashed(path, []byte("create schema test;\n"), RunParams{Release: "v2.0.0"}, fsys))
+		// Run test
+		release, err := readRelease("0", fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "v2.0.0", release)
+	})
+
+	t.Run("ignores release comment after statements", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema test;\n"+releasePrefix+"v2.0.0\n"), 0644))
+		// Run test
+		release, err := readRelease("0", fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, release)
+	})
+}
+
+func TestQueueRelease(t *testing.T) {
+	path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+
+	t.Run("queues update after release column", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, writeSquashed(path, []byte("create schema test;\n"), RunParams{Release: "v2.0.0"}, fsys))
+		// Run test
+		batch := pgx.Batch{}
+		setup, err := queueRelease(&batch, "0", fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{ADD_RELEASE_COLUMN}, setup)
+		assert.Equal(t, 1, batch.Len())
+	})
+
+	t.Run("skips migration without release", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, writeSquashed(path, []byte("create schema test;\n"), RunParams{}, fsys))
+		// Run test
+		batch := pgx.Batch{}
+		setup, err := queueRelease(&batch, "0", fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, setup)
+		assert.Equal(t, 0, batch.Len())
+	})
+}
+
+func TestReplaceHistory(t *testing.T) {
+	t.Run("rolls back on permission denied", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query("begin").Reply("BEGIN").
+			Query(ADD_RELEASE_COLUMN).
+			ReplyError(pgerrcode.InsufficientPrivilege, "must be owner of table schema_migrations").
+			Query("rollback").Reply("ROLLBACK")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, dbConfig, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = replaceHistory(ctx, mock, []string{ADD_RELEASE_COLUMN}, &pgx.Batch{})
+		// Check error
+		assert.ErrorContains(t, err, "must be owner of table schema_migrations")
+		assert.ErrorContains(t, err, "failed to update migration history")
+	})
+}
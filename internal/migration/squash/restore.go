@@ -0,0 +1,158 @@
+package squash
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var (
+	ErrCorruptBackup    = errors.New("backup does not match its recorded hash")
+	ErrNothingToRestore = errors.New("no unrestored squash found in audit log")
+)
+
+// Undoes the file mutations of the latest unrestored squash recorded in an audit log,
+// replaying its entries in reverse and marking them as restored.
+func RunRestore(auditPath string, fsys afero.Fs) error {
+	entries, err := readAuditLog(auditPath, fsys)
+	if err != nil {
+		return err
+	}
+	last := latestRun(entries)
+	if last < 0 {
+		return errors.Errorf("%w: %s", ErrNothingToRestore, auditPath)
+	}
+	runId := entries[last].RunId
+	now := time.Now().UTC()
+	var count int
+	for i := last; i >= 0; i-- {
+		if entries[i].RunId != runId || entries[i].Restored != nil {
+			continue
+		}
+		if err := revertEntry(entries[i], fsys); err != nil {
+			return err
+		}
+		entries[i].Restored = &now
+		count++
+	}
+	if err := writeAuditLog(auditPath, entries, fsys); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Replayed", count, "entries from audit log", utils.Bold(auditPath))
+	return nil
+}
+
+// Returns the index of the last unrestored entry, whose run id identifies the latest squash, or -1.
+func latestRun(entries []auditEntry) int {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Restored == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func readAuditLog(path string, fsys afero.Fs) ([]auditEntry, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, errors.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errors.Errorf("failed to parse audit log line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+func writeAuditLog(path string, entries []auditEntry, fsys afero.Fs) error {
+	var out bytes.Buffer
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return errors.Errorf("failed to encode audit entry: %w", err)
+		}
+		out.Write(append(line, '\n'))
+	}
+	if err := utils.WriteFile(path, out.Bytes(), fsys); err != nil {
+		return errors.Errorf("failed to update audit log: %w", err)
+	}
+	return nil
+}
+
+// Failed operations are skipped because the file was left as it was before.
+func revertEntry(entry auditEntry, fsys afero.Fs) error {
+	if len(entry.Error) > 0 {
+		return nil
+	}
+	switch entry.Operation {
+	case auditCreate:
+		if err := fsys.Remove(entry.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.Errorf("failed to remove created file: %w", err)
+		}
+		fmt.Fprintln(os.Stderr, "Removed", utils.Bold(entry.Path))
+	case auditOverwrite, auditDelete:
+		if len(entry.Backup) == 0 {
+			fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "no backup recorded for", utils.Bold(entry.Path))
+			return nil
+		}
+		contents, err := readBackup(entry.Backup, fsys)
+		if err != nil {
+			return err
+		}
+		if err := utils.WriteFile(entry.Path, contents, fsys); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Restored", utils.Bold(entry.Path))
+	case auditRename:
+		if err := fsys.Rename(entry.Path, entry.Source); err != nil {
+			return errors.Errorf("failed to rename migration: %w", err)
+		}
+		fmt.Fprintln(os.Stderr, "Renamed", utils.Bold(entry.Path), "back to", utils.Bold(entry.Source))
+		// A file replaced by the rename is restored from its backup
+		if len(entry.Backup) > 0 {
+			contents, err := readBackup(entry.Backup, fsys)
+			if err != nil {
+				return err
+			}
+			if err := utils.WriteFile(entry.Path, contents, fsys); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Backups are named by the hash of their contents, which guards against restoring edited files.
+func readBackup(path string, fsys afero.Fs) ([]byte, error) {
+	contents, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return nil, errors.Errorf("failed to read backup: %w", err)
+	}
+	hash := sha256.Sum256(contents)
+	if hex.EncodeToString(hash[:]) != filepath.Base(path) {
+		return nil, errors.Errorf("%w: %s", ErrCorruptBackup, path)
+	}
+	return contents, nil
+}
@@ -0,0 +1,131 @@
+package squash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestRunRestore(t *testing.T) {
+	auditPath := filepath.Join(utils.SupabaseDirPath, "squash_audit.jsonl")
+	utils.Config.Db.Squash.AuditPath = auditPath
+	defer func() { utils.Config.Db.Squash.AuditPath = "" }()
+
+	t.Run("restores squashed migrations", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		files := map[string]string{
+			"0_init.sql":   "create schema a;",
+			"1_tables.sql": "create table a.t();",
+			"2_target.sql": "create schema b;",
+		}
+		for name, sql := range files {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte(sql), 0644))
+		}
+		_, err := squashToVersion(context.Background(), "2", RunParams{Textual: true}, fsys)
+		require.NoError(t, err)
+		// Run test
+		err = RunRestore(auditPath, fsys)
+		// Check error
+		assert.NoError(t, err)
+		for name, sql := range files {
+			contents, err := afero.ReadFile(fsys, filepath.Join(utils.MigrationsDir, name))
+			assert.NoError(t, err)
+			assert.Equal(t, sql, string(contents))
+		}
+	})
+
+	t.Run("restores only the last run", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		renamed := filepath.Join(utils.MigrationsDir, "0_baseline.sql")
+		startAuditRun()
+		require.NoError(t, newAuditLog(fsys).writeFile(path, []byte("create schema a;"), fsys))
+		startAuditRun()
+		require.NoError(t, newAuditLog(fsys).writeFile(path, []byte("create schema b;"), fsys))
+		require.NoError(t, newAuditLog(fsys).rename(path, renamed, fsys))
+		// Run test
+		err := RunRestore(auditPath, fsys)
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		assert.Equal(t, "create schema a;", string(contents))
+		exists, err := afero.Exists(fsys, renamed)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		// Check restored entries
+		entries := readAudit(t, auditPath, fsys)
+		require.Len(t, entries, 3)
+		assert.Nil(t, entries[0].Restored)
+		assert.NotNil(t, entries[1].Restored)
+		assert.NotNil(t, entries[2].Restored)
+		assert.NotEqual(t, entries[0].RunId, entries[1].RunId)
+	})
+
+	t.Run("throws error on restored log", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, newAuditLog(fsys).writeFile(path, []byte("create schema a;"), fsys))
+		require.NoError(t, RunRestore(auditPath, fsys))
+		// Run test
+		err := RunRestore(auditPath, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrNothingToRestore)
+	})
+
+	t.Run("removes created files", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, newAuditLog(fsys).writeFile(path, []byte("create schema a;"), fsys))
+		// Run test
+		err := RunRestore(auditPath, fsys)
+		// Check error
+		assert.NoError(t, err)
+		exists, err := afero.Exists(fsys, path)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("throws error on corrupt backup", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema a;"), 0644))
+		require.NoError(t, newAuditLog(fsys).remove(path, fsys))
+		entries := readAudit(t, auditPath, fsys)
+		require.NoError(t, afero.WriteFile(fsys, entries[0].Backup, []byte("drop schema a;"), 0644))
+		// Run test
+		err := RunRestore(auditPath, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrCorruptBackup)
+	})
+
+	t.Run("throws error on malformed log", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, auditPath, []byte("{}\nnot json\n"), 0644))
+		// Run test
+		err := RunRestore(auditPath, fsys)
+		// Check error
+		assert.ErrorContains(t, err, "failed to parse audit log line 2")
+	})
+
+	t.Run("throws error on missing log", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		err := RunRestore(auditPath, fsys)
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,56 @@
+package squash
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/go-errors/errors"
+)
+
+// Scans lines like bufio.Scanner but without a maximum line length, since
+// function bodies and default values in pg_dump output may exceed 64KB.
+// Lines are kept as raw bytes so invalid UTF-8 passes through unchanged.
+type lineScanner struct {
+	reader *bufio.Reader
+	line   []byte
+	err    error
+}
+
+func newLineScanner(r io.Reader) *lineScanner {
+	return &lineScanner{reader: bufio.NewReader(r)}
+}
+
+func (s *lineScanner) Scan() bool {
+	if s.err != nil {
+		s.line = nil
+		return false
+	}
+	line, err := s.reader.ReadBytes('\n')
+	if err != nil {
+		s.err = err
+		if len(line) == 0 {
+			s.line = nil
+			return false
+		}
+	}
+	s.line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+	return true
+}
+
+func (s *lineScanner) Text() string {
+	return string(s.line)
+}
+
+// Returns the raw bytes of the current line, which are not overwritten by the next scan.
+func (s *lineScanner) Bytes() []byte {
+	return s.line
+}
+
+// Returns the first non-EOF error encountered while scanning.
+func (s *lineScanner) Err() error {
+	if s.err == nil || errors.Is(s.err, io.EOF) {
+		return nil
+	}
+	return errors.Errorf("failed to read line: %w", s.err)
+}
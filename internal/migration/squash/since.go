@@ -0,0 +1,62 @@
+package squash
+
+import (
+	"context"
+	"io"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/db/diff"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/utils"
+)
+
+// Splits migrations up to version into those applied before since and the window to squash.
+func splitSince(version, since string, fsys afero.Fs) ([]string, []string, error) {
+	migrations, err := list.LoadOrderedMigrations(version, fsys)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, name := range migrations {
+		if utils.MigrateFilePattern.FindStringSubmatch(name)[1] == since {
+			return migrations[:i], migrations[i:], nil
+		}
+	}
+	return nil, nil, errors.Errorf("%w: %s", ErrMissingVersion, since)
+}
+
+// Returns the engine configured to diff the squashed window, defaulting to migra because
+// diffing dumps line by line cannot alter objects created by earlier migrations.
+func sinceDiffer() diff.DiffFunc {
+	if differ := managedDiffer(); differ != nil {
+		return differ
+	}
+	return diff.DiffSchemaMigra
+}
+
+// Diffs user schemas of the migrated database against the snapshot taken before the window, so
+// that objects of earlier migrations are altered, renamed or dropped instead of created again.
+func diffSince(ctx context.Context, differ diff.DiffFunc, conn *pgx.Conn, config pgconn.Config, exclude []string, w io.Writer) error {
+	all, err := diff.LoadUserSchemas(ctx, conn)
+	if err != nil {
+		return err
+	}
+	var schemas []string
+	for _, name := range all {
+		if !matchesAny(name, exclude) {
+			schemas = append(schemas, name)
+		}
+	}
+	source := config
+	source.Database = managedSnapshot
+	out, err := differ(ctx, utils.ToPostgresURL(source), utils.ToPostgresURL(config), schemas)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, out); err != nil {
+		return errors.Errorf("failed to write schema diff: %w", err)
+	}
+	return nil
+}
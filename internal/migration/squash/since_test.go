@@ -0,0 +1,87 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/db/reset"
+	"github.com/supabase/cli/internal/testing/pgtest"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestSplitSince(t *testing.T) {
+	t.Run("splits migrations at since version", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"0_init.sql", "1_users.sql", "2_posts.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte{}, 0644))
+		}
+		// Run test
+		prior, window, err := splitSince("", "1", fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"0_init.sql"}, prior)
+		assert.Equal(t, []string{"1_users.sql", "2_posts.sql"}, window)
+	})
+
+	t.Run("throws error on since after version", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"0_init.sql", "1_users.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte{}, 0644))
+		}
+		// Run test
+		_, _, err := splitSince("0", "1", fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrMissingVersion)
+	})
+}
+
+func TestDiffSince(t *testing.T) {
+	excluded := reset.LikeEscapeSchema(append([]string{
+		"auth",
+		"pgbouncer",
+		"realtime",
+		"_realtime",
+		"storage",
+		"_analytics",
+		"supabase_functions",
+		"supabase_migrations",
+	}, utils.SystemSchemas...))
+
+	t.Run("diffs user schemas against snapshot", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(reset.LIST_SCHEMAS, excluded).
+			Reply("SELECT 2", []interface{}{"public"}, []interface{}{"cron_jobs"})
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Setup mock differ
+		var source, target string
+		var schemas []string
+		differ := func(_ context.Context, src, dst string, names []string) (string, error) {
+			source, target, schemas = src, dst, names
+			return "alter table \"public\".\"users\" add column \"name\" text;\n", nil
+		}
+		config := pgconn.Config{Host: "127.0.0.1", Port: 54320, User: "postgres", Password: "postgres", Database: "postgres"}
+		// Run test
+		var out bytes.Buffer
+		err = diffSince(ctx, differ, mock, config, []string{"cron*"}, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Contains(t, source, "/"+managedSnapshot)
+		assert.Equal(t, utils.ToPostgresURL(config), target)
+		assert.Equal(t, []string{"public"}, schemas)
+		assert.Equal(t, "alter table \"public\".\"users\" add column \"name\" text;\n", out.String())
+	})
+}
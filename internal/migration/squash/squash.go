@@ -1,33 +1,420 @@
 package squash
 
 import (
-	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/docker/go-units"
 	"github.com/go-errors/errors"
+	"github.com/gofrs/flock"
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgconn/stmtcache"
 	"github.com/jackc/pgx/v4"
 	"github.com/spf13/afero"
 	"github.com/supabase/cli/internal/db/diff"
 	"github.com/supabase/cli/internal/db/dump"
+	"github.com/supabase/cli/internal/db/push"
 	"github.com/supabase/cli/internal/db/start"
 	"github.com/supabase/cli/internal/migration/apply"
 	"github.com/supabase/cli/internal/migration/history"
 	"github.com/supabase/cli/internal/migration/list"
 	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/migration/verify"
 	"github.com/supabase/cli/internal/utils"
 )
 
-var ErrMissingVersion = errors.New("version not found")
+var (
+	ErrMissingVersion = errors.New("version not found")
+	// Tablespaces must exist before restoring a custom format dump
+	ErrFullCustom      = errors.New("cluster objects cannot be included in custom format")
+	ErrFileTooLarge    = errors.New("squashed migration exceeds db.squash.max_file_size")
+	ErrInProgress      = errors.New("another squash is in progress")
+	ErrMissingRole     = errors.New("role not found in shadow")
+	ErrOwnerCustom     = errors.New("owner filter cannot be applied to custom format")
+	ErrInvalidName     = errors.New("invalid migration name")
+	ErrInvalidShadow   = errors.New("invalid shadow container name")
+	ErrDockerRequired  = errors.New("Docker is required for squash")
+	ErrSinceCustom     = errors.New("since filter cannot be applied to custom format")
+	ErrFromEmptyCustom = errors.New("from empty baseline cannot be written in custom format")
+	ErrDownCustom      = errors.New("down migration cannot be generated from custom format")
+	ErrKeepRecent      = errors.New("not enough migrations to keep")
+	ErrVersionConflict = errors.New("version conflicts with --keep-recent")
+	ErrStdoutFiles     = errors.New("stdout output cannot be combined with flags that read or write the squashed file")
+)
+
+const (
+	FormatSql      = "sql"
+	FormatCustom   = "custom"
+	FormatMinified = "minified"
+	FormatPretty   = "pretty"
+)
+
+type RunParams struct {
+	// Writes a SHA-256 checksum file next to the squashed migration
+	Checksum bool
+	// Signs the squashed migration with the specified gpg key
+	SignKey string
+	// Applies seed.sql to the shadow database before dumping the migrated schema
+	Seed bool
+	// Output format of the squashed schema, defaults to plain sql
+	Format string
+	// Skips diffing auth and storage schemas for projects that don't use them
+	NoManagedDiff bool
+	// Dumps all schemas, including auth and storage, as a self-contained baseline instead of diffing managed schemas
+	FromEmpty bool
+	// Prints the slowest migrations applied to the shadow database
+	Profile bool
+	// Includes tablespaces and comments on roles for restoring to self-hosted databases
+	Full bool
+	// Concatenates migration files instead of dumping from a shadow database
+	Textual bool
+	// Concatenates migration files without confirmation when Docker is unavailable
+	TextualFallback bool
+	// Set when falling back to concatenation, marking the squashed migration as not normalized
+	unnormalized bool
+	// Pushes the squashed migration to the target database after baselining
+	Push bool
+	// Applies migrations to the shadow database as this role, defaults to postgres
+	Role string
+	// Prints the managed schema diff to stdout in this format, ie. json
+	DiffFormat string
+	// Continues applying migrations to the shadow database after a statement fails
+	ContinueOnError bool
+	// Writes the squashed migration even if it exceeds the configured size limit
+	Force bool
+	// Skips the git cleanliness check of the migrations directory
+	AllowDirty bool
+	// Adds section headers grouping the dumped statements by object type
+	Pretty bool
+	// Only keeps objects owned by this role in the squashed schema
+	Owner string
+	// Renames the squashed migration to this name while keeping its version
+	Rename string
+	// Only squashes migrations that are not committed to this git branch
+	Base string
+	// Checks the squashed migration with the Postgres parser before writing
+	Validate bool
+	// Names the shadow database container, overriding db.shadow.container_name
+	ShadowName string
+	// Preserves data statements from merged files as a section or in seed.sql
+	Data string
+	// Writes typescript types generated from the migrated shadow database to this path
+	GenTypes string
+	// Fails when types generated from the squashed schema differ from the GenTypes file, instead of writing it
+	CheckTypes bool
+	// Only squashes migrations from this version, keeping objects they created or altered
+	Since string
+	// Writes phase durations, shadow memory and statements applied to this file
+	MetricsPath string
+	// Format of the metrics file, either prometheus text or json lines
+	MetricsFormat string
+	// Schemas to leave out of the squashed dump, such as cron or supabase_functions
+	ExcludeSchemas []string
+	// Applies the squashed migration on a shadow database of each postgres major version
+	VerifyVersions []uint
+	// Maximum number of shadow databases verifying versions at once
+	VerifyParallel uint
+	// Checks that the squashed migration creates every object created by the merged migrations
+	AssertObjects bool
+	// Keeps this many of the most recent migrations unsquashed
+	KeepRecent uint
+	// Writes the squashed migration to stdout without modifying any migration files
+	Stdout bool
+	// Runs ANALYZE on the shadow database before dumping so that catalog statistics are settled
+	Analyze bool
+	// Transforms the squashed migration in sequence after the built-in processors
+	PostProcessors []SquashPostProcessor
+	// Ignores whitespace-only changes when diffing managed schemas
+	IgnoreWhitespace bool
+	// Records this release identifier in the squashed migration header and the baseline history row
+	Release string
+	// Fails the squash once this duration has elapsed across all phases
+	Timeout time.Duration
+	// Restores unchanged earlier migrations from a cached dump instead of applying them
+	Cache bool
+	// Quotes all identifiers in the custom format dump for restoring on servers with more reserved words
+	QuoteAllIdentifiers bool
+	// Webhook url to post, or command to pipe, a json summary of the squash outcome to
+	OnComplete string
+	// Rewrites equivalent column defaults, such as CURRENT_TIMESTAMP and now(), to a single form
+	NormalizeDefaults bool
+	// Writes a best effort down migration dropping the objects created by the squashed migration
+	WithDown bool
+	// Switches to this role before updating the migration history table of the target database
+	HistoryRole string
+	// Replaces only the history rows of squashed migrations, keeping remote rows without a local file
+	PartialBaseline bool
+	// Omits security labels from all dumps, for schemas whose labels are environment specific
+	NoSecurityLabels bool
+	// Keeps user mapping credentials of foreign servers instead of redacting them
+	FdwCredentials bool
+	// Starts the squashed migration with comments recording the cli and postgres versions, time and merged versions
+	Metadata bool
+	// Applies the squashed baseline to this preview branch instead of the target database
+	Branch string
+	// Diffs the squashed baseline against the schema of the baselined database
+	Verify bool
+}
+
+var migrationNamePattern = regexp.MustCompile(`^[\w-]+$`)
+
+// Reports whether params touch files other than the squashed output, or print to stdout.
+func writesFiles(params RunParams) bool {
+	return params.Push || params.Checksum || len(params.SignKey) > 0 || len(params.Rename) > 0 ||
+		params.Format == FormatCustom || params.Data == DataSeed || len(params.GenTypes) > 0 ||
+		len(params.MetricsPath) > 0 || len(params.VerifyVersions) > 0 || params.AssertObjects ||
+		len(params.Branch) > 0 || params.Verify || params.WithDown ||
+		params.DiffFormat == utils.OutputJson
+}
+
+// Outcome of a squash for callers embedding the squash command.
+type SquashResult struct {
+	// Version of the squashed migration that becomes the baseline
+	Version string
+	// Path to the squashed migration, after any rename
+	Path string
+	// Migration files merged into the squashed migration and removed
+	Merged []string
+	// Whether the migration history of the target database was baselined
+	Baselined bool
+	// Time taken by each phase of squashing in the shadow database
+	Phases []PhaseTiming
+}
+
+func Run(ctx context.Context, version string, config pgconn.Config, params RunParams, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	result, err := RunWithResult(ctx, version, config, params, fsys, options...)
+	err = withFileExitCode(err)
+	if len(params.OnComplete) > 0 {
+		notifyCompletion(ctx, params.OnComplete, newCompletionReport(version, result, err))
+	}
+	return err
+}
+
+func RunWithResult(ctx context.Context, version string, config pgconn.Config, params RunParams, fsys afero.Fs, options ...func(*pgx.ConnConfig)) (*SquashResult, error) {
+	if params.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, params.Timeout)
+		defer cancel()
+	}
+	// Held before starting any shadow database because concurrent squashes bind the same port
+	lock, err := lockMigrations(ctx, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+	if version, err = resolveVersion(version, os.Stdin); err != nil {
+		return nil, err
+	}
+	if params.KeepRecent > 0 {
+		if version, err = keepRecentVersion(version, params.KeepRecent, fsys); err != nil {
+			return nil, err
+		}
+	}
+	if params.Format == FormatPretty {
+		params.Pretty = true
+	}
+	// Managed schemas are dumped in full so there is nothing to diff
+	if params.FromEmpty {
+		params.NoManagedDiff = true
+	}
+	if params.Full && params.Format == FormatCustom {
+		return nil, errors.New(ErrFullCustom)
+	}
+	if len(params.Owner) > 0 && params.Format == FormatCustom {
+		return nil, errors.New(ErrOwnerCustom)
+	}
+	if len(params.Since) > 0 && params.Format == FormatCustom {
+		return nil, errors.New(ErrSinceCustom)
+	}
+	if params.FromEmpty && params.Format == FormatCustom {
+		return nil, errors.New(ErrFromEmptyCustom)
+	}
+	if params.WithDown && params.Format == FormatCustom {
+		return nil, errors.New(ErrDownCustom)
+	}
+	if params.CheckTypes && len(params.GenTypes) == 0 {
+		return nil, errors.New(ErrCheckTypesPath)
+	}
+	// Cached dumps exclude managed schemas so their changes would be missing from the diff
+	if params.Cache && !params.NoManagedDiff {
+		return nil, errors.New(ErrCacheManaged)
+	}
+	if params.Cache && len(params.Since) > 0 {
+		return nil, errors.New(ErrCacheSince)
+	}
+	if len(params.Rename) > 0 && !migrationNamePattern.MatchString(params.Rename) {
+		return nil, errors.Errorf("%w: %s", ErrInvalidName, params.Rename)
+	}
+	if err := assertSupportedVersions(params.VerifyVersions); err != nil {
+		return nil, err
+	}
+	if err := assertRelease(params.Release); err != nil {
+		return nil, err
+	}
+	if params.Stdout && writesFiles(params) {
+		return nil, errors.New(ErrStdoutFiles)
+	}
+	if len(params.ShadowName) > 0 && !utils.ContainerNamePattern.MatchString(params.ShadowName) {
+		return nil, errors.Errorf("%w: %s", ErrInvalidShadow, params.ShadowName)
+	}
+	if err := assertVersion(version, fsys); err != nil {
+		return nil, err
+	}
+	if err := assertVersion(params.Since, fsys); err != nil {
+		return nil, err
+	}
+	// Merged files are removed so uncommitted edits would be lost
+	if !params.AllowDirty && !params.Stdout {
+		if err := assertCleanMigrations(); err != nil {
+			return nil, err
+		}
+	}
+	if err := utils.LoadConfigFS(fsys); err != nil {
+		return nil, err
+	}
+	if err := assertUniqueLocalVersions(fsys); err != nil {
+		return nil, err
+	}
+	if len(params.ShadowName) > 0 {
+		utils.Config.Db.Shadow.ContainerName = params.ShadowName
+	}
+	// Loaded before squashing because merged files are removed
+	window, partial, err := loadSquashWindow(version, params, fsys)
+	if err != nil {
+		return nil, err
+	}
+	// Only a dump of more than one migration, or verifying versions, starts a shadow database
+	dumped := (!partial || len(params.Since) > 0) && !params.Textual
+	if (dumped || len(params.VerifyVersions) > 0) && len(window) > 1 || params.Verify {
+		if err := assertDockerRunning(ctx); err != nil && (!dumped || !fallbackToTextual(&params, err)) {
+			return nil, err
+		}
+	}
+	if len(params.Branch) > 0 {
+		if config, err = resolveBranch(ctx, params.Branch, fsys, options...); err != nil {
+			return nil, err
+		}
+	}
+	if !partial && !params.PartialBaseline {
+		// A manifest may order later versions before the target, so only the squashed rows are replaced
+		if ordered, err := isFilenameOrdered(fsys); err != nil {
+			return nil, err
+		} else if ordered {
+			window = nil
+		}
+	}
+	// Confirmed before squashing so that an unconfirmed baseline fails before merged files are removed
+	baseline := params.Push
+	if params.Stdout {
+		baseline = false
+	} else if len(params.Branch) > 0 {
+		// Preview branches apply the baseline so that verify diffs the squashed schema
+		if baseline, err = utils.PromptDestructive(fmt.Sprintf("Apply squashed baseline to preview branch %s?", utils.Aqua(params.Branch)), os.Stdin); err != nil {
+			return nil, err
+		}
+		params.Push = baseline
+	} else if !params.Push && !utils.IsLocalDatabase(config) {
+		if baseline, err = utils.PromptDestructive("Update remote migration history table?", os.Stdin); err != nil {
+			return nil, err
+		}
+	}
+	// 1. Squash local migrations
+	result, err := squashToVersion(ctx, version, params, fsys, options...)
+	if err != nil {
+		err = timeoutError(ctx, err, "squash")
+		if params.Push {
+			return result, errors.Errorf("failed to squash migrations: %w", err)
+		}
+		return result, err
+	}
+	if params.Stdout || len(params.Branch) > 0 && !baseline {
+		return result, nil
+	}
+	if params.Push {
+		if err := pushMigrations(ctx, config, version, window, params.HistoryRole, fsys, options...); err != nil {
+			return result, withExitCode(timeoutError(ctx, err, "push"), ExitBaselineFailed)
+		}
+		result.Baselined = true
+	} else if baseline {
+		// 2. Update migration history
+		if err := updateHistory(ctx, config, version, window, params.HistoryRole, fsys, options...); err != nil {
+			return result, withExitCode(timeoutError(ctx, err, "baseline"), ExitBaselineFailed)
+		}
+		result.Baselined = true
+	}
+	// 3. Check that the baseline reproduces the database schema
+	if params.Verify {
+		if err := verify.Run(ctx, result.Version, config, fsys, options...); err != nil {
+			return result, timeoutError(ctx, err, "verify")
+		}
+	}
+	return result, nil
+}
 
-func Run(ctx context.Context, version string, config pgconn.Config, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+func isFilenameOrdered(fsys afero.Fs) (bool, error) {
+	local, err := list.LoadOrderedMigrations("", fsys)
+	if err != nil {
+		return false, err
+	}
+	return sort.StringsAreSorted(local), nil
+}
+
+// Replaces only the rows of merged migrations when squashing a window, otherwise resets all earlier rows.
+// Updates the history table as role when set, so that the connecting user may be restricted otherwise.
+func updateHistory(ctx context.Context, config pgconn.Config, version string, window []string, role string, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	if window == nil {
+		return baselineMigrations(ctx, config, version, role, fsys, options...)
+	}
+	if len(window) < 2 {
+		return nil
+	}
+	return baselineWindow(ctx, config, window, role, fsys, options...)
+}
+
+// Reads the version from stdin when it is "-", so that a computed cutoff can be piped to squash.
+func resolveVersion(version string, stdin io.Reader) (string, error) {
+	if version != "-" {
+		return version, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(stdin, 256))
+	if err != nil {
+		return "", errors.Errorf("failed to read version from stdin: %w", err)
+	}
+	// An empty version would otherwise squash to the latest migration
+	if version = strings.TrimSpace(string(data)); len(version) == 0 {
+		return "", errors.Errorf("%w: empty stdin", repair.ErrInvalidVersion)
+	}
+	return version, nil
+}
+
+// Returns the version of the latest migration to squash such that the n most recent are kept.
+func keepRecentVersion(version string, n uint, fsys afero.Fs) (string, error) {
+	migrations, err := list.LoadOrderedMigrations("", fsys)
+	if err != nil {
+		return "", err
+	}
+	if uint(len(migrations)) <= n {
+		return "", errors.Errorf("%w: found %d migrations", ErrKeepRecent, len(migrations))
+	}
+	target := utils.MigrateFilePattern.FindStringSubmatch(migrations[uint(len(migrations))-n-1])[1]
+	if len(version) > 0 && version != target {
+		return "", errors.Errorf("%w: expected %s", ErrVersionConflict, target)
+	}
+	fmt.Fprintln(os.Stderr, "Keeping the", n, "most recent migrations, squashing up to", utils.Bold(target))
+	return target, nil
+}
+
+func assertVersion(version string, fsys afero.Fs) error {
 	if len(version) > 0 {
 		if _, err := strconv.Atoi(version); err != nil {
 			return errors.New(repair.ErrInvalidVersion)
@@ -36,99 +423,653 @@ func Run(ctx context.Context, version string, config pgconn.Config, fsys afero.F
 			return err
 		}
 	}
-	if err := utils.LoadConfigFS(fsys); err != nil {
+	return nil
+}
+
+func assertDockerRunning(ctx context.Context) error {
+	if err := utils.AssertDockerIsRunning(ctx); err != nil {
+		utils.CmdSuggestion = fmt.Sprintf("Start Docker Desktop, or use %s to squash without a shadow database.", utils.Aqua("--textual"))
+		return withExitCode(errors.Errorf("%w: %w", ErrDockerRequired, err), ExitDockerUnavailable)
+	}
+	return nil
+}
+
+// Prints the migrations that would be merged by squash without starting any database.
+func RunList(version string, fsys afero.Fs) error {
+	version, err := resolveVersion(version, os.Stdin)
+	if err != nil {
 		return err
 	}
-	// 1. Squash local migrations
-	if err := squashToVersion(ctx, version, fsys, options...); err != nil {
+	if err := assertVersion(version, fsys); err != nil {
 		return err
 	}
-	// 2. Update migration history
-	if utils.IsLocalDatabase(config) || !utils.PromptYesNo("Update remote migration history table?", true, os.Stdin) {
+	migrations, _, err := loadSquashWindow(version, RunParams{}, fsys)
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		return errors.New(ErrMissingVersion)
+	}
+	target := filepath.Join(utils.MigrationsDir, migrations[len(migrations)-1])
+	if len(migrations) == 1 {
+		fmt.Fprintln(os.Stderr, utils.Bold(target), "is already the earliest migration.")
 		return nil
 	}
-	return baselineMigrations(ctx, config, version, fsys, options...)
+	fmt.Fprintln(os.Stderr, "Migrations to be merged:")
+	for _, name := range migrations[:len(migrations)-1] {
+		fmt.Println(filepath.Join(utils.MigrationsDir, name))
+	}
+	fmt.Fprintln(os.Stderr, "Migration to be overwritten:")
+	fmt.Println(target)
+	return nil
 }
 
-func squashToVersion(ctx context.Context, version string, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
-	migrations, err := list.LoadPartialMigrations(version, fsys)
+func squashToVersion(ctx context.Context, version string, params RunParams, fsys afero.Fs, options ...func(*pgx.ConnConfig)) (*SquashResult, error) {
+	migrations, partial, err := loadSquashWindow(version, params, fsys)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if partial && len(migrations) > 0 {
+		fmt.Fprintln(os.Stderr, "Keeping earlier migrations, squashing from", utils.Bold(migrations[0]))
+		// Dumping the shadow database would also include objects created by earlier migrations
+		params.Textual = params.Textual || len(params.Since) == 0
 	}
 	if len(migrations) == 0 {
-		return errors.New(ErrMissingVersion)
+		return nil, errors.New(ErrMissingVersion)
 	}
 	// Migrate to target version and dump
+	startAuditRun()
 	path := filepath.Join(utils.MigrationsDir, migrations[len(migrations)-1])
+	logger := utils.GetDebugLogger()
+	if len(version) == 0 {
+		fmt.Fprintln(logger, "No version specified, squashing to the latest migration", path)
+	}
+	fmt.Fprintln(logger, "Squashing from", migrations[0], "to", path)
+	result := SquashResult{
+		Version: utils.MigrateFilePattern.FindStringSubmatch(migrations[len(migrations)-1])[1],
+		Path:    path,
+	}
 	if len(migrations) == 1 {
 		fmt.Fprintln(os.Stderr, utils.Bold(path), "is already the earliest migration.")
-		return nil
+		return &result, nil
 	}
-	if err := squashMigrations(ctx, migrations, fsys, options...); err != nil {
-		return err
+	redefined, err := findRedefinitions(migrations, fsys)
+	if err != nil {
+		return nil, err
+	}
+	printRedefinitions(redefined, os.Stderr)
+	if params.Textual {
+		if len(params.GenTypes) > 0 {
+			fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "skipped generating types because no shadow database is started.")
+		}
+		if len(params.MetricsPath) > 0 {
+			fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "skipped writing metrics because no shadow database is started.")
+		}
+		if err := concatMigrations(migrations, params, fsys); err != nil {
+			return nil, err
+		}
+	} else {
+		// Concatenated migrations keep the upgrade path so only dumps need a warning
+		if err := warnExtensionUpgrades(migrations, fsys); err != nil {
+			return nil, err
+		}
+		if err := squashMigrations(ctx, migrations, params, &result.Phases, fsys, options...); err != nil {
+			return nil, err
+		}
+	}
+	if params.Stdout {
+		return &result, nil
 	}
 	fmt.Fprintln(os.Stderr, "Squashed local migrations to", utils.Bold(path))
+	// Renamed before checksum so that the digest references the final file name
+	if len(params.Rename) > 0 {
+		if path, err = renameSquashed(path, params.Rename, fsys); err != nil {
+			return nil, err
+		}
+		result.Path = path
+	}
+	if params.Checksum {
+		if err := writeChecksum(path, fsys); err != nil {
+			return nil, err
+		}
+	}
+	if len(params.SignKey) > 0 {
+		signMigration(ctx, path, params.SignKey, fsys)
+	}
+	if params.WithDown {
+		if err := writeDown(path, fsys); err != nil {
+			return nil, err
+		}
+	}
+	if changelog := utils.Config.Db.Squash.ChangelogPath; len(changelog) > 0 {
+		if err := writeChangelog(changelog, migrations[:len(migrations)-1], path, time.Now(), fsys); err != nil {
+			return nil, err
+		}
+	}
 	// Remove merged files
-	for _, name := range migrations[:len(migrations)-1] {
+	result.Merged = migrations[:len(migrations)-1]
+	audit := newAuditLog(fsys)
+	for _, name := range result.Merged {
 		path := filepath.Join(utils.MigrationsDir, name)
-		if err := fsys.Remove(path); err != nil {
-			fmt.Fprintln(os.Stderr, err)
+		// Files only in a read-only source, such as object storage, cannot be removed
+		if err := audit.remove(path, fsys); err != nil {
+			return &result, errors.Errorf("failed to remove merged migration: %w", err)
+		}
+		version := utils.MigrateFilePattern.FindStringSubmatch(name)[1]
+		for _, sidecar := range []string{
+			repair.GetCustomDumpPath(version),
+			repair.GetDownPath(version),
+			repair.GetChecksumPath(version),
+			repair.GetSignaturePath(version),
+		} {
+			if err := audit.remove(sidecar, fsys); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return &result, errors.Errorf("failed to remove merged migration: %w", err)
+			}
 		}
 	}
+	if len(params.VerifyVersions) > 0 {
+		if err := verifyVersions(ctx, filepath.Base(path), params.VerifyVersions, params.VerifyParallel, fsys, options...); err != nil {
+			return &result, err
+		}
+	}
+	return &result, nil
+}
+
+func squashMigrations(ctx context.Context, migrations []string, params RunParams, phases *[]PhaseTiming, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	if !params.AssertObjects {
+		return squashInShadow(ctx, migrations, params, nil, phases, fsys, options...)
+	}
+	// The squashed schema overwrites the target, so files are restored if objects are missing
+	name := migrations[len(migrations)-1]
+	version := utils.MigrateFilePattern.FindStringSubmatch(name)[1]
+	touched := []string{
+		filepath.Join(utils.MigrationsDir, name),
+		repair.GetCustomDumpPath(version),
+		repair.GetDownPath(version),
+		repair.GetChecksumPath(version),
+		repair.GetSignaturePath(version),
+	}
+	if params.Data == DataSeed {
+		touched = append(touched, utils.SeedDataPath)
+	}
+	restore, err := snapshotFiles(touched, fsys)
+	if err != nil {
+		return err
+	}
+	var expected []string
+	if err := squashInShadow(ctx, migrations, params, &expected, phases, fsys, options...); err != nil {
+		return err
+	}
+	// Checked after the first shadow database is removed because both bind the same port
+	applied := migrations[len(migrations)-1:]
+	if len(params.Since) > 0 {
+		target := utils.MigrateFilePattern.FindStringSubmatch(applied[0])[1]
+		prior, _, err := splitSince(target, params.Since, fsys)
+		if err != nil {
+			return err
+		}
+		applied = append(prior, applied...)
+	}
+	if err := assertObjects(ctx, applied, expected, params.ExcludeSchemas, fsys, options...); err != nil {
+		if restoreErr := restore(); restoreErr != nil {
+			fmt.Fprintln(os.Stderr, restoreErr)
+		}
+		return err
+	}
 	return nil
 }
 
-func squashMigrations(ctx context.Context, migrations []string, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+// Applies migrations to a shadow database and writes the dumped schema to the last migration,
+// listing the created objects when objects is not nil.
+func squashInShadow(ctx context.Context, migrations []string, params RunParams, objects *[]string, phases *[]PhaseTiming, fsys afero.Fs, options ...func(*pgx.ConnConfig)) (err error) {
+	var metrics *squashMetrics
+	if len(params.MetricsPath) > 0 {
+		metrics = &squashMetrics{}
+	}
+	// Timed even without metrics so that a timeout reports the active phase
+	timer := &squashMetrics{}
+	startPhase := func(name string) {
+		timer.startPhase(name)
+		metrics.startPhase(name)
+	}
+	defer func() {
+		err = timeoutError(ctx, err, timer.currentPhase)
+		timer.endPhase()
+		if phases != nil {
+			*phases = timer.Phases
+		}
+	}()
 	// 1. Start shadow database
-	shadow, err := diff.CreateShadowDatabase(ctx)
+	startPhase("start")
+	args, err := getTuningArgs(ctx)
+	if err != nil {
+		return err
+	}
+	shadow, err := diff.CreateShadowDatabase(ctx, args...)
 	if err != nil {
 		return err
 	}
 	defer utils.DockerRemove(shadow)
 	if !start.WaitForHealthyService(ctx, shadow, start.HealthTimeout) {
-		return errors.New(start.ErrDatabase)
+		return withExitCode(errors.New(start.ErrDatabase), ExitShadowTimeout)
 	}
+	var notices noticeCollector
+	options = append(options, func(cc *pgx.ConnConfig) {
+		cc.OnNotice = notices.Handle
+	})
 	conn, err := diff.ConnectShadowDatabase(ctx, 10*time.Second, options...)
 	if err != nil {
 		return err
 	}
 	defer conn.Close(context.Background())
-	if err := start.SetupDatabase(ctx, conn, shadow[:12], os.Stderr, fsys); err != nil {
+	if err := start.SetupShadowDatabase(ctx, conn, shadow[:12], os.Stderr, fsys); err != nil {
 		return err
 	}
-	// Assuming entities in managed schemas are not altered, we can simply diff the dumps before and after migrations.
-	schemas := []string{"auth", "storage"}
+	if !start.WaitForMigrationsReady(ctx, conn, start.HealthTimeout) {
+		return withExitCode(errors.New(start.ErrDatabase), ExitShadowTimeout)
+	}
 	config := pgconn.Config{
 		Host:     utils.Config.Hostname,
 		Port:     uint16(utils.Config.Db.ShadowPort),
 		User:     "postgres",
 		Password: utils.Config.Db.Password,
-		Database: "postgres",
+		Database: utils.Config.Db.Name,
 	}
-	var before, after bytes.Buffer
-	if err := dump.DumpSchema(ctx, config, schemas, false, false, &before); err != nil {
+	if err := diff.EnsureDatabase(ctx, conn, config.Database); err != nil {
+		return err
+	}
+	if len(params.Owner) > 0 {
+		if err := assertRoleExists(ctx, conn, params.Owner); err != nil {
+			return err
+		}
+	}
+	if config.Database != conn.Config().Database {
+		if conn, err = utils.ConnectLocalPostgres(ctx, config, options...); err != nil {
+			return err
+		}
+		defer conn.Close(context.Background())
+	}
+	if err := assertExtensionsAvailable(ctx, conn, migrations, fsys); err != nil {
 		return err
 	}
+	// Earlier migrations are kept so that only changes made by the window are diffed
+	if len(params.Since) > 0 {
+		target := utils.MigrateFilePattern.FindStringSubmatch(migrations[len(migrations)-1])[1]
+		prior, _, err := splitSince(target, params.Since, fsys)
+		if err != nil {
+			return err
+		}
+		if err := apply.MigrateUp(ctx, conn, prior, fsys); err != nil {
+			return withExitCode(err, ExitMigrationFailed)
+		}
+	}
+	// Assuming entities in managed schemas are not altered, we can simply diff the dumps before and after migrations.
+	schemas := managedSchemas()
+	differ := managedDiffer()
+	var before, after bytes.Buffer
+	if len(params.Since) > 0 || !params.NoManagedDiff && differ != nil {
+		// Reconnected because sessions are terminated to copy the database
+		conn.Close(context.Background())
+		if err := snapshotDatabase(ctx, config, options...); err != nil {
+			return err
+		}
+		if conn, err = utils.ConnectLocalPostgres(ctx, config, options...); err != nil {
+			return err
+		}
+		defer conn.Close(context.Background())
+	}
+	if !params.NoManagedDiff && differ == nil {
+		if err := dump.DumpSchema(ctx, config, schemas, false, false, &before, labelOptions(params)...); err != nil {
+			return err
+		}
+	}
 	// 2. Migrate to target version
-	if err := apply.MigrateUp(ctx, conn, migrations, fsys); err != nil {
+	startPhase("migrate")
+	notices.enabled = true
+	defer notices.Print(os.Stderr)
+	var profile *apply.Profile
+	if params.Profile {
+		profile = &apply.Profile{}
+		defer profile.Print(os.Stderr)
+	} else if metrics != nil {
+		profile = &apply.Profile{}
+	}
+	// Objects created by migrations should be owned by the same role as in production
+	if err := setRole(ctx, conn, params.Role); err != nil {
 		return err
 	}
-	if err := dump.DumpSchema(ctx, config, schemas, false, false, &after); err != nil {
+	if params.Cache {
+		if err := migrateWithCache(ctx, conn, config, migrations, params.Role, profile, params.ContinueOnError, fsys); err != nil {
+			return withExitCode(err, ExitMigrationFailed)
+		}
+	} else if err := apply.MigrateUpWithProfile(ctx, conn, migrations, profile, params.ContinueOnError, fsys); err != nil {
+		return withExitCode(err, ExitMigrationFailed)
+	}
+	// Some objects are only created by triggers when seed data is inserted
+	if params.Seed {
+		if err := apply.SeedDatabase(ctx, conn, fsys); err != nil {
+			return withExitCode(err, ExitMigrationFailed)
+		}
+	}
+	notices.enabled = false
+	metrics.sampleMemory(ctx, shadow)
+	if err := runAssertions(ctx, conn, fsys); err != nil {
 		return err
 	}
+	if objects != nil {
+		if *objects, err = listObjects(ctx, conn, params.ExcludeSchemas); err != nil {
+			return err
+		}
+	}
+	if metrics != nil {
+		metrics.Statements = profile.Statements
+	}
+	if params.Analyze {
+		startPhase("analyze")
+		if err := analyzeDatabase(ctx, conn); err != nil {
+			return err
+		}
+	}
 	// 3. Dump migrated schema
-	path := filepath.Join(utils.MigrationsDir, migrations[len(migrations)-1])
-	f, err := fsys.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	startPhase("dump")
+	if !params.NoManagedDiff && differ == nil {
+		if err := dump.DumpSchema(ctx, config, schemas, false, false, &after, labelOptions(params)...); err != nil {
+			return err
+		}
+	}
+	name := migrations[len(migrations)-1]
+	if params.Format == FormatCustom {
+		version := utils.MigrateFilePattern.FindStringSubmatch(name)[1]
+		if err := writeCustomDump(ctx, config, repair.GetCustomDumpPath(version), fsys, squashedOptions(params)...); err != nil {
+			return err
+		}
+	}
+	var out bytes.Buffer
+	if params.Full {
+		if err := dump.DumpCluster(ctx, config, &out); err != nil {
+			return err
+		}
+	}
+	if params.Format != FormatCustom {
+		if err := dumpForeignWrappers(ctx, conn, &out); err != nil {
+			return err
+		}
+		var schema bytes.Buffer
+		if len(params.Since) > 0 {
+			if err := diffSince(ctx, sinceDiffer(), conn, config, params.ExcludeSchemas, &schema); err != nil {
+				return err
+			}
+		} else {
+			dumpSchema := dump.DumpSchema
+			if params.FromEmpty {
+				dumpSchema = dump.DumpAllSchemas
+			}
+			if err := dumpSchema(ctx, config, nil, false, false, &schema, squashedOptions(params)...); err != nil {
+				return err
+			}
+		}
+		if err := writeOrderedSchema(&schema, &out, params); err != nil {
+			return err
+		}
+	}
+	metrics.sampleMemory(ctx, shadow)
+	// 4. Append managed schema diffs
+	startPhase("diff")
+	if !params.NoManagedDiff {
+		var stats diffStats
+		if differ != nil {
+			stats, err = appendEngineDiff(ctx, differ, config, schemas, params.DiffFormat, &out)
+		} else {
+			stats, err = appendManagedDiff(&before, &after, schemas, params.DiffFormat, params.IgnoreWhitespace, &out)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Managed schema diff:", stats.Summary(schemas))
+	}
+	// 5. Preserve data statements dropped by the schema dump
+	var data []string
+	if len(params.Data) > 0 {
+		if data, err = collectData(migrations, fsys); err != nil {
+			return err
+		}
+	}
+	if params.Data == DataSection && len(data) > 0 {
+		out.WriteString(dataComment)
+		out.Write(formatData(data))
+	}
+	// Checked before writing so that drift leaves migration files untouched
+	if params.CheckTypes {
+		startPhase("types")
+		if err := checkTypes(ctx, config, params.GenTypes, fsys); err != nil {
+			return err
+		}
+	}
+	startPhase("write")
+	processed, err := postProcess(out.Bytes(), newPostProcessors(params))
 	if err != nil {
-		return errors.Errorf("failed to open migration file: %w", err)
+		return err
+	}
+	// Prepended after post-processing so that minifying does not strip the comments
+	if params.Metadata {
+		processed = append([]byte(metadataHeader(migrations, time.Now())), processed...)
+	}
+	if params.Validate {
+		if err := validateSyntax(string(processed)); err != nil {
+			return err
+		}
+	}
+	path := filepath.Join(utils.MigrationsDir, name)
+	if params.Stdout {
+		path = stdoutPath
 	}
-	defer f.Close()
-	if err := dump.DumpSchema(ctx, config, nil, false, false, f); err != nil {
+	if err := writeSquashed(path, processed, params, fsys); err != nil {
 		return err
 	}
-	// 4. Append managed schema diffs
-	fmt.Fprint(f, separatorComment)
-	return lineByLineDiff(&before, &after, f)
+	if params.Data == DataSeed && len(data) > 0 {
+		if err := writeSeedData(data, fsys); err != nil {
+			return err
+		}
+	}
+	// 6. Generate types while the shadow database is still running
+	if len(params.GenTypes) > 0 && !params.CheckTypes {
+		startPhase("types")
+		if err := writeTypes(ctx, config, params.GenTypes, fsys); err != nil {
+			return err
+		}
+	}
+	if metrics != nil {
+		return metrics.write(params.MetricsPath, params.MetricsFormat, fsys)
+	}
+	return nil
+}
+
+// Both dumps are ordered before diffing because added lines may only be part of a statement.
+func appendManagedDiff(before, after io.Reader, schemas []string, format string, ignoreSpace bool, w io.Writer) (diffStats, error) {
+	sorted := utils.Config.Db.Squash.SortManagedDiff
+	var orderedBefore, orderedAfter, added bytes.Buffer
+	if err := orderManagedDiff(before, schemas, sorted, &orderedBefore); err != nil {
+		return nil, err
+	}
+	if err := orderManagedDiff(after, schemas, sorted, &orderedAfter); err != nil {
+		return nil, err
+	}
+	stats, err := lineByLineDiff(&orderedBefore, &orderedAfter, ignoreSpace, &added)
+	if err != nil {
+		return nil, err
+	}
+	return stats, writeManagedDiff(&added, format, w)
+}
+
+// Diffs the managed schemas of the migrated database against the snapshot taken before migrations.
+func appendEngineDiff(ctx context.Context, differ diff.DiffFunc, config pgconn.Config, schemas []string, format string, w io.Writer) (diffStats, error) {
+	source := config
+	source.Database = managedSnapshot
+	out, err := differ(ctx, utils.ToPostgresURL(source), utils.ToPostgresURL(config), schemas)
+	if err != nil {
+		return nil, err
+	}
+	stats := diffStats{}
+	added := statsCounter{stats: stats}
+	for _, line := range strings.Split(out, "\n") {
+		added.count(line)
+	}
+	// Engines output whole statements so they can be ordered after diffing
+	var diffs bytes.Buffer
+	if err := orderManagedDiff(strings.NewReader(out), schemas, utils.Config.Db.Squash.SortManagedDiff, &diffs); err != nil {
+		return nil, err
+	}
+	return stats, writeManagedDiff(&diffs, format, w)
+}
+
+func writeManagedDiff(diffs io.Reader, format string, w io.Writer) error {
+	fmt.Fprint(w, separatorComment)
+	if format != utils.OutputJson {
+		return filterGrants(diffs, utils.Config.Db.Squash.ExcludeGrants, w)
+	}
+	// The migration file always keeps the sql diff so that it can be applied
+	var filtered bytes.Buffer
+	if err := filterGrants(diffs, utils.Config.Db.Squash.ExcludeGrants, io.MultiWriter(w, &filtered)); err != nil {
+		return err
+	}
+	return diff.WriteJson(filtered.String(), os.Stdout)
+}
+
+// Writing to this path prints the squashed migration to stdout instead.
+const stdoutPath = "-"
+
+// Confirms before writing a squashed migration larger than db.squash.max_file_size,
+// which usually means too broad a schema set was dumped.
+func writeSquashed(path string, contents []byte, params RunParams, fsys afero.Fs) error {
+	// Printed schemas are not migration files so they are never treated as a baseline
+	var header string
+	if path != stdoutPath {
+		header = list.BaselineMarker + "\n"
+	}
+	if len(params.Release) > 0 {
+		header += releasePrefix + params.Release + "\n"
+	}
+	if params.unnormalized {
+		header += fallbackComment + "\n"
+	}
+	contents = append([]byte(header), contents...)
+	if path == stdoutPath {
+		if _, err := os.Stdout.Write(contents); err != nil {
+			return errors.Errorf("failed to write to stdout: %w", err)
+		}
+		return nil
+	}
+	if limit := int64(utils.Config.Db.Squash.MaxFileSize); !params.Force && limit > 0 && int64(len(contents)) > limit {
+		msg := fmt.Sprintf("Squashed migration is %s which exceeds the limit of %s. Write it to %s anyway?", units.BytesSize(float64(len(contents))), units.BytesSize(float64(limit)), utils.Bold(path))
+		if !utils.PromptYesNo(msg, false, os.Stdin) {
+			return errors.Errorf("%w: use --force to write it anyway", ErrFileTooLarge)
+		}
+	}
+	return newAuditLog(fsys).writeFile(path, contents, fsys)
+}
+
+// Renames the squashed migration to <version>_<name>.sql so baselining records the new name.
+func renameSquashed(path, name string, fsys afero.Fs) (string, error) {
+	version := utils.MigrateFilePattern.FindStringSubmatch(filepath.Base(path))[1]
+	renamed := filepath.Join(filepath.Dir(path), version+"_"+name+".sql")
+	if renamed == path {
+		return path, nil
+	}
+	if err := newAuditLog(fsys).rename(path, renamed, fsys); err != nil {
+		return "", errors.Errorf("failed to rename migration: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Renamed squashed migration to", utils.Bold(renamed))
+	return renamed, nil
+}
+
+// Serialises concurrent squashes of the same migrations directory on this host, because they
+// rewrite the same files and start shadow databases on the same port.
+func lockMigrations(ctx context.Context, timeout time.Duration) (*flock.Flock, error) {
+	dir, err := filepath.Abs(utils.MigrationsDir)
+	if err != nil {
+		return nil, errors.Errorf("failed to resolve migrations dir: %w", err)
+	}
+	name := fmt.Sprintf("supabase-squash-%x.lock", sha256.Sum256([]byte(dir)))
+	lock := flock.New(filepath.Join(os.TempDir(), name))
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if ok, err := lock.TryLockContext(timeoutCtx, 100*time.Millisecond); ok {
+		return lock, nil
+	} else if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return nil, errors.Errorf("failed to lock migrations: %w", err)
+	}
+	return nil, errors.New(ErrInProgress)
+}
+
+func setRole(ctx context.Context, conn *pgx.Conn, role string) error {
+	if len(role) == 0 || role == "postgres" {
+		return nil
+	}
+	sql := "SET ROLE " + pgx.Identifier{role}.Sanitize()
+	if _, err := conn.Exec(ctx, sql); err != nil {
+		return errors.Errorf("failed to set role: %w", err)
+	}
+	return nil
+}
+
+func analyzeDatabase(ctx context.Context, conn *pgx.Conn) error {
+	fmt.Fprintln(os.Stderr, "Analyzing shadow database...")
+	if _, err := conn.Exec(ctx, "ANALYZE"); err != nil {
+		return errors.Errorf("failed to analyze shadow database: %w", err)
+	}
+	return nil
+}
+
+// Applied to both managed schema dumps so that labels do not show up as diffs.
+func labelOptions(params RunParams) []dump.DumpOption {
+	if params.NoSecurityLabels {
+		return []dump.DumpOption{dump.WithNoSecurityLabels}
+	}
+	return nil
+}
+
+// Options for dumping the squashed schema, in either plain or custom format. Identifiers are only
+// quoted on request for custom format because plain sql dumps always quote them.
+func squashedOptions(params RunParams) []dump.DumpOption {
+	opts := append(labelOptions(params), dump.WithExcludeSchemas(params.ExcludeSchemas))
+	if params.QuoteAllIdentifiers && params.Format == FormatCustom {
+		opts = append(opts, dump.WithQuoteAllIdentifiers)
+	}
+	return opts
+}
+
+func writeCustomDump(ctx context.Context, config pgconn.Config, path string, fsys afero.Fs, opts ...dump.DumpOption) error {
+	var out bytes.Buffer
+	if err := dump.DumpSchemaCustom(ctx, config, nil, &out, opts...); err != nil {
+		return err
+	}
+	if err := newAuditLog(fsys).writeFile(path, out.Bytes(), fsys); err != nil {
+		return errors.Errorf("failed to write dump file: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Wrote custom dump to", utils.Bold(path))
+	return nil
+}
+
+// Collects notices and warnings raised by Postgres while applying migrations.
+type noticeCollector struct {
+	enabled bool
+	notices []*pgconn.Notice
+}
+
+func (c *noticeCollector) Handle(_ *pgconn.PgConn, n *pgconn.Notice) {
+	if c.enabled && (n.Severity == "NOTICE" || n.Severity == "WARNING") {
+		c.notices = append(c.notices, n)
+	}
+}
+
+func (c *noticeCollector) Print(w io.Writer) {
+	if len(c.notices) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Postgres raised the following messages while applying migrations:")
+	for _, n := range c.notices {
+		fmt.Fprintln(w, utils.Yellow(n.Severity+": "+n.Message))
+	}
 }
 
 const separatorComment = `
@@ -138,28 +1079,131 @@ const separatorComment = `
 
 `
 
-func lineByLineDiff(before, after io.Reader, f io.Writer) error {
-	anchor := bufio.NewScanner(before)
-	anchor.Scan()
+// With ignoreSpace, lines are compared after trimming and collapsing whitespace but
+// differing lines are still written as they appear in after.
+func lineByLineDiff(before, after io.Reader, ignoreSpace bool, f io.Writer) (diffStats, error) {
+	stats := diffStats{}
+	added := statsCounter{stats: stats}
+	anchor := newLineScanner(before)
+	hasAnchor := anchor.Scan()
+	equal := func(a, b []byte) bool {
+		if ignoreSpace {
+			return bytes.Equal(normalizeSpace(a), normalizeSpace(b))
+		}
+		return bytes.Equal(a, b)
+	}
 	// Assuming before is always a subset of after
-	scanner := bufio.NewScanner(after)
+	scanner := newLineScanner(after)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if equal(line, anchor.Bytes()) {
+			hasAnchor = anchor.Scan()
+			continue
+		}
+		added.count(string(line))
+		// Written verbatim because dumps may contain invalid UTF-8 in binary defaults
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			return nil, errors.Errorf("failed to write line: %w", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	// Lines left in before are no longer present after migrations
+	removed := statsCounter{stats: stats, removed: true}
+	for ; hasAnchor; hasAnchor = anchor.Scan() {
+		removed.count(anchor.Text())
+	}
+	if err := anchor.Err(); err != nil {
+		return nil, err
+	}
+	stats.pairChanges()
+	return stats, nil
+}
+
+// Invalid UTF-8 bytes are never treated as space so they are kept in the normalized line.
+func normalizeSpace(line []byte) []byte {
+	return bytes.Join(bytes.Fields(line), []byte(" "))
+}
+
+var grantPattern = regexp.MustCompile(`^(GRANT|REVOKE|ALTER DEFAULT PRIVILEGES) `)
+
+// Drops grant and revoke statements matching any excluded pattern so the output is stable across machines.
+func filterGrants(diffs io.Reader, exclude []string, f io.Writer) error {
+	var patterns []*regexp.Regexp
+	for _, p := range exclude {
+		// Patterns are already validated when loading config
+		if r, err := regexp.Compile(p); err == nil {
+			patterns = append(patterns, r)
+		}
+	}
+	scanner := newLineScanner(diffs)
 	for scanner.Scan() {
 		line := scanner.Text()
-		if line == anchor.Text() {
-			anchor.Scan()
+		if grantPattern.MatchString(line) && matchAny(patterns, line) {
 			continue
 		}
 		if _, err := fmt.Fprintln(f, line); err != nil {
 			return errors.Errorf("failed to write line: %w", err)
 		}
 	}
+	return scanner.Err()
+}
+
+func matchAny(patterns []*regexp.Regexp, line string) bool {
+	for _, r := range patterns {
+		if r.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// Baselines the migration history of a non-empty target before pushing pending migrations.
+func pushMigrations(ctx context.Context, config pgconn.Config, version string, window []string, historyRole string, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	remote, err := loadRemoteMigrations(ctx, config, options...)
+	if err != nil {
+		return errors.Errorf("failed to baseline migration history: %w", err)
+	}
+	// A fresh target has no history so the squashed migration is applied by push instead
+	if len(remote) > 0 {
+		if err := updateHistory(ctx, config, version, window, historyRole, fsys, options...); err != nil {
+			return errors.Errorf("failed to baseline migration history: %w", err)
+		}
+	}
+	if err := push.Run(ctx, false, false, false, false, config, fsys, options...); err != nil {
+		return errors.Errorf("failed to push migrations: %w", err)
+	}
 	return nil
 }
 
-func baselineMigrations(ctx context.Context, config pgconn.Config, version string, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+func loadRemoteMigrations(ctx context.Context, config pgconn.Config, options ...func(*pgx.ConnConfig)) ([]string, error) {
+	conn, err := utils.ConnectByConfig(ctx, config, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(context.Background())
+	return list.LoadRemoteMigrations(ctx, conn)
+}
+
+// Remote targets may be reached over flaky networks or through a transaction pooler,
+// where named prepared statements do not survive between transactions.
+var remoteOptions = []func(*pgx.ConnConfig){
+	utils.WithKeepAlive(15 * time.Second),
+	utils.WithStatementCacheMode(stmtcache.ModeDescribe),
+}
+
+// Caller options come last so that they can override the remote defaults.
+func withRemoteOptions(options []func(*pgx.ConnConfig)) []func(*pgx.ConnConfig) {
+	result := make([]func(*pgx.ConnConfig), 0, len(remoteOptions)+len(options))
+	result = append(result, remoteOptions...)
+	return append(result, options...)
+}
+
+func baselineMigrations(ctx context.Context, config pgconn.Config, version, role string, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
 	if len(version) == 0 {
 		// Expecting no errors here because the caller should have handled them
-		if migrations, err := list.LoadPartialMigrations(version, fsys); len(migrations) > 0 {
+		if migrations, err := list.LoadOrderedMigrations(version, fsys); len(migrations) > 0 {
 			if matches := utils.MigrateFilePattern.FindStringSubmatch(migrations[0]); len(matches) > 1 {
 				version = matches[1]
 			}
@@ -169,11 +1213,18 @@ func baselineMigrations(ctx context.Context, config pgconn.Config, version strin
 		}
 	}
 	fmt.Fprintln(os.Stderr, "Baselining migration history to", version)
+	if !utils.IsLocalDatabase(config) {
+		options = withRemoteOptions(options)
+	}
 	conn, err := utils.ConnectByConfig(ctx, config, options...)
 	if err != nil {
 		return err
 	}
 	defer conn.Close(context.Background())
+	diff.WarnLocaleMismatch(ctx, conn, os.Stderr)
+	if err := setRole(ctx, conn, role); err != nil {
+		return err
+	}
 	if err := history.CreateMigrationTable(ctx, conn); err != nil {
 		return err
 	}
@@ -185,7 +1236,24 @@ func baselineMigrations(ctx context.Context, config pgconn.Config, version strin
 	batch := pgx.Batch{}
 	batch.Queue(history.DELETE_MIGRATION_BEFORE, m.Version)
 	batch.Queue(history.INSERT_MIGRATION_VERSION, m.Version, m.Name, m.Lines)
-	if err := conn.SendBatch(ctx, &batch).Close(); err != nil {
+	setup, err := queueRelease(&batch, m.Version, fsys)
+	if err != nil {
+		return err
+	}
+	return replaceHistory(ctx, conn, setup, &batch)
+}
+
+// Runs the setup statements and batch in an explicit transaction so that deleted rows are restored
+// if the insert fails.
+func replaceHistory(ctx context.Context, conn *pgx.Conn, setup []string, batch *pgx.Batch) error {
+	if err := conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+		for _, sql := range setup {
+			if _, err := tx.Exec(ctx, sql); err != nil {
+				return err
+			}
+		}
+		return tx.SendBatch(ctx, batch).Close()
+	}); err != nil {
 		return errors.Errorf("failed to update migration history: %w", err)
 	}
 	return nil
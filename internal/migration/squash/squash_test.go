@@ -18,10 +18,13 @@ import (
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v4"
 	"github.com/spf13/afero"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/db/diff"
 	"github.com/supabase/cli/internal/db/start"
 	"github.com/supabase/cli/internal/migration/history"
+	"github.com/supabase/cli/internal/migration/list"
 	"github.com/supabase/cli/internal/migration/repair"
 	"github.com/supabase/cli/internal/testing/apitest"
 	"github.com/supabase/cli/internal/testing/fstest"
@@ -53,6 +56,9 @@ func TestSquashCommand(t *testing.T) {
 		// Setup mock docker
 		require.NoError(t, apitest.MockDocker(utils.Docker))
 		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Head("/_ping").
+			Reply(http.StatusOK)
 		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-shadow-db")
 		gock.New(utils.Docker.DaemonHost()).
 			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db/json").
@@ -81,6 +87,10 @@ func TestSquashCommand(t *testing.T) {
 		// Setup mock postgres
 		conn := pgtest.NewConn()
 		defer conn.Close(t)
+		conn.Query(start.SELECT_MISSING_SCHEMAS, start.BaselineSchemas).
+			Reply("SELECT 0")
+		conn.Query(diff.SELECT_DATABASE_NAME, "postgres").
+			Reply("SELECT 1", []interface{}{"postgres"})
 		pgtest.MockMigrationHistory(conn)
 		conn.Query(sql).
 			Reply("CREATE SCHEMA").
@@ -88,11 +98,13 @@ func TestSquashCommand(t *testing.T) {
 			Reply("INSERT 0 1").
 			Query(history.INSERT_MIGRATION_VERSION, "1", "target", nil).
 			Reply("INSERT 0 1")
+		conn.Query(LIST_FDW_EXTENSIONS).
+			Reply("SELECT 0")
 		// Run test
 		err := Run(context.Background(), "", pgconn.Config{
 			Host: "127.0.0.1",
 			Port: 54322,
-		}, fsys, conn.Intercept)
+		}, RunParams{}, fsys, conn.Intercept)
 		// Check error
 		assert.NoError(t, err)
 		assert.Empty(t, apitest.ListUnmatchedRequests())
@@ -104,7 +116,142 @@ func TestSquashCommand(t *testing.T) {
 		assert.True(t, match)
 	})
 
+	t.Run("skips managed schema diff", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		paths := []string{
+			filepath.Join(utils.MigrationsDir, "0_init.sql"),
+			filepath.Join(utils.MigrationsDir, "1_target.sql"),
+		}
+		sql := "create schema test"
+		require.NoError(t, afero.WriteFile(fsys, paths[0], []byte(sql), 0644))
+		require.NoError(t, afero.WriteFile(fsys, paths[1], []byte{}, 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Head("/_ping").
+			Reply(http.StatusOK)
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-shadow-db")
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db/json").
+			Reply(http.StatusOK).
+			JSON(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+				State: &types.ContainerState{
+					Running: true,
+					Health:  &types.Health{Status: "healthy"},
+				},
+			}})
+		gock.New(utils.Docker.DaemonHost()).
+			Delete("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db").
+			Reply(http.StatusOK)
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.RealtimeImage), "test-realtime")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-realtime", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.StorageImage), "test-storage")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-storage", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.GotrueImage), "test-auth")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-auth", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-db")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-db", sql))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(start.SELECT_MISSING_SCHEMAS, start.BaselineSchemas).
+			Reply("SELECT 0")
+		conn.Query(diff.SELECT_DATABASE_NAME, "postgres").
+			Reply("SELECT 1", []interface{}{"postgres"})
+		pgtest.MockMigrationHistory(conn)
+		conn.Query(sql).
+			Reply("CREATE SCHEMA").
+			Query(history.INSERT_MIGRATION_VERSION, "0", "init", []string{sql}).
+			Reply("INSERT 0 1").
+			Query(history.INSERT_MIGRATION_VERSION, "1", "target", nil).
+			Reply("INSERT 0 1")
+		conn.Query(LIST_FDW_EXTENSIONS).
+			Reply("SELECT 0")
+		// Run test
+		err := Run(context.Background(), "", pgconn.Config{
+			Host: "127.0.0.1",
+			Port: 54322,
+		}, RunParams{NoManagedDiff: true}, fsys, conn.Intercept)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+		contents, err := afero.ReadFile(fsys, paths[1])
+		assert.NoError(t, err)
+		assert.Equal(t, list.BaselineMarker+"\n"+sql, string(contents))
+	})
+
+	t.Run("replaces only squashed history with partial baseline", func(t *testing.T) {
+		viper.Set("YES", true)
+		defer viper.Set("YES", false)
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		for _, name := range []string{"1_init.sql", "2_target.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte("create schema a;"), 0644))
+		}
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		pgtest.MockMigrationHistory(conn)
+		// Remote row 0 has no local file so it is kept
+		conn.Query("begin").Reply("BEGIN")
+		conn.Query(fmt.Sprintf("DELETE FROM supabase_migrations.schema_migrations WHERE version = ANY( '{1,2}' );INSERT INTO supabase_migrations.schema_migrations(version, name, statements) VALUES( '2' ,  'target' ,  '{%s\ncreate schema a,create schema a}' )", list.BaselineMarker)).
+			Reply("INSERT 0 1").
+			Query("commit").Reply("COMMIT")
+		// Run test
+		err := Run(context.Background(), "2", dbConfig, RunParams{Textual: true, Force: true, PartialBaseline: true}, fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
+			cc.PreferSimpleProtocol = true
+		})
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("replaces only squashed history in manifest order", func(t *testing.T) {
+		viper.Set("YES", true)
+		defer viper.Set("YES", false)
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		for _, name := range []string{"1_init.sql", "2_later.sql", "3_target.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte("create schema a;"), 0644))
+		}
+		manifest := "migrations:\n  - 1_init.sql\n  - 3_target.sql\n  - 2_later.sql\n"
+		require.NoError(t, afero.WriteFile(fsys, utils.MigrationsManifestPath, []byte(manifest), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		pgtest.MockMigrationHistory(conn)
+		// Version 2 is ordered after the target so its row is kept
+		conn.Query("begin").Reply("BEGIN")
+		conn.Query(fmt.Sprintf("DELETE FROM supabase_migrations.schema_migrations WHERE version = ANY( '{1,3}' );INSERT INTO supabase_migrations.schema_migrations(version, name, statements) VALUES( '3' ,  'target' ,  '{%s\ncreate schema a,create schema a}' )", list.BaselineMarker)).
+			Reply("INSERT 0 1").
+			Query("commit").Reply("COMMIT")
+		// Run test
+		err := Run(context.Background(), "3", dbConfig, RunParams{Textual: true, Force: true}, fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
+			cc.PreferSimpleProtocol = true
+		})
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on remote history without terminal", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema test"), 0644))
+		// Run test
+		err := Run(context.Background(), "0", dbConfig, RunParams{}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, utils.ErrNotTerminal)
+	})
+
 	t.Run("baselines migration history", func(t *testing.T) {
+		viper.Set("YES", true)
+		defer viper.Set("YES", false)
 		// Setup in-memory fs
 		fsys := afero.NewMemMapFs()
 		require.NoError(t, utils.WriteConfig(fsys, false))
@@ -115,10 +262,12 @@ func TestSquashCommand(t *testing.T) {
 		conn := pgtest.NewConn()
 		defer conn.Close(t)
 		pgtest.MockMigrationHistory(conn)
+		conn.Query("begin").Reply("BEGIN")
 		conn.Query(fmt.Sprintf("DELETE FROM supabase_migrations.schema_migrations WHERE version <=  '0' ;INSERT INTO supabase_migrations.schema_migrations(version, name, statements) VALUES( '0' ,  'init' ,  '{%s}' )", sql)).
-			Reply("INSERT 0 1")
+			Reply("INSERT 0 1").
+			Query("commit").Reply("COMMIT")
 		// Run test
-		err := Run(context.Background(), "0", dbConfig, fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
+		err := Run(context.Background(), "0", dbConfig, RunParams{}, fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
 			cc.PreferSimpleProtocol = true
 		})
 		// Check error
@@ -128,11 +277,105 @@ func TestSquashCommand(t *testing.T) {
 		assert.True(t, match)
 	})
 
+	t.Run("throws error on squash stage failure", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		// Run test
+		err := Run(context.Background(), "", dbConfig, RunParams{Push: true}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrMissingVersion)
+		assert.ErrorContains(t, err, "failed to squash migrations:")
+	})
+
+	t.Run("throws error on baseline stage failure", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema test"), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(list.LIST_MIGRATION_VERSION).
+			ReplyError(pgerrcode.InsufficientPrivilege, "permission denied for relation schema_migrations")
+		// Run test
+		err := Run(context.Background(), "0", dbConfig, RunParams{Push: true}, fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
+			cc.PreferSimpleProtocol = true
+		})
+		// Check error
+		assert.ErrorContains(t, err, "failed to baseline migration history:")
+	})
+
+	t.Run("throws error on missing docker", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		for _, name := range []string{"0_init.sql", "1_target.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte{}, 0644))
+		}
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Head("/_ping").
+			ReplyError(errors.New("network error"))
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/_ping").
+			ReplyError(errors.New("network error"))
+		// Run test
+		err := Run(context.Background(), "", dbConfig, RunParams{}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrDockerRequired)
+		assert.ErrorContains(t, err, "network error")
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("throws error on full custom format", func(t *testing.T) {
+		params := RunParams{Full: true, Format: FormatCustom}
+		// Run test
+		err := Run(context.Background(), "", pgconn.Config{}, params, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, ErrFullCustom)
+	})
+
+	t.Run("throws error on from empty custom format", func(t *testing.T) {
+		params := RunParams{FromEmpty: true, Format: FormatCustom}
+		// Run test
+		err := Run(context.Background(), "", pgconn.Config{}, params, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, ErrFromEmptyCustom)
+	})
+
+	t.Run("throws error on stdout with push", func(t *testing.T) {
+		params := RunParams{Stdout: true, Push: true}
+		// Run test
+		err := Run(context.Background(), "", pgconn.Config{}, params, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, ErrStdoutFiles)
+	})
+
+	t.Run("throws error on stdout with json diff", func(t *testing.T) {
+		params := RunParams{Stdout: true, DiffFormat: utils.OutputJson}
+		// Run test
+		err := Run(context.Background(), "", pgconn.Config{}, params, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, ErrStdoutFiles)
+	})
+
+	t.Run("throws error on invalid rename", func(t *testing.T) {
+		params := RunParams{Rename: "../baseline"}
+		// Run test
+		err := Run(context.Background(), "", pgconn.Config{}, params, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, ErrInvalidName)
+	})
+
 	t.Run("throws error on invalid version", func(t *testing.T) {
 		// Setup in-memory fs
 		fsys := afero.NewMemMapFs()
 		// Run test
-		err := Run(context.Background(), "0_init", pgconn.Config{}, fsys)
+		err := Run(context.Background(), "0_init", pgconn.Config{}, RunParams{}, fsys)
 		// Check error
 		assert.ErrorIs(t, err, repair.ErrInvalidVersion)
 	})
@@ -141,7 +384,7 @@ func TestSquashCommand(t *testing.T) {
 		// Setup in-memory fs
 		fsys := afero.NewMemMapFs()
 		// Run test
-		err := Run(context.Background(), "0", pgconn.Config{}, fsys)
+		err := Run(context.Background(), "0", pgconn.Config{}, RunParams{}, fsys)
 		// Check error
 		assert.ErrorIs(t, err, os.ErrNotExist)
 	})
@@ -152,7 +395,7 @@ func TestSquashVersion(t *testing.T) {
 		// Setup in-memory fs
 		fsys := &fstest.OpenErrorFs{DenyPath: utils.MigrationsDir}
 		// Run test
-		err := squashToVersion(context.Background(), "0", fsys)
+		_, err := squashToVersion(context.Background(), "0", RunParams{}, fsys)
 		// Check error
 		assert.ErrorIs(t, err, os.ErrPermission)
 	})
@@ -161,11 +404,52 @@ func TestSquashVersion(t *testing.T) {
 		// Setup in-memory fs
 		fsys := afero.NewMemMapFs()
 		// Run test
-		err := squashToVersion(context.Background(), "0", fsys)
+		_, err := squashToVersion(context.Background(), "0", RunParams{}, fsys)
 		// Check error
 		assert.ErrorIs(t, err, ErrMissingVersion)
 	})
 
+	t.Run("throws error on read only merged migration", func(t *testing.T) {
+		// Setup in-memory fs
+		base := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(base, path, []byte("create schema a;"), 0644))
+		require.NoError(t, afero.WriteFile(base, filepath.Join(utils.MigrationsDir, "1_target.sql"), []byte("create schema b;"), 0644))
+		fsys := afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(base), afero.NewMemMapFs())
+		// Run test
+		_, err := squashToVersion(context.Background(), "1", RunParams{Textual: true}, fsys)
+		// Check error
+		assert.ErrorContains(t, err, "failed to remove merged migration:")
+		exists, err := afero.Exists(fsys, path)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("renames squashed migration", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema a;"), 0644))
+		path = filepath.Join(utils.MigrationsDir, "1_target.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema b;"), 0644))
+		params := RunParams{Textual: true, Rename: "squashed_baseline", Checksum: true}
+		// Run test
+		result, err := squashToVersion(context.Background(), "1", params, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, &SquashResult{
+			Version: "1",
+			Path:    filepath.Join(utils.MigrationsDir, "1_squashed_baseline.sql"),
+			Merged:  []string{"0_init.sql"},
+		}, result)
+		local, err := list.LoadLocalMigrations(fsys)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1_squashed_baseline.sql"}, local)
+		checksum, err := afero.ReadFile(fsys, filepath.Join(utils.MigrationsDir, "1.sql.sha256"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(checksum), "  1_squashed_baseline.sql\n")
+	})
+
 	t.Run("throws error on shadow create failure", func(t *testing.T) {
 		// Setup in-memory fs
 		fsys := afero.NewMemMapFs()
@@ -180,14 +464,81 @@ func TestSquashVersion(t *testing.T) {
 			Get("/v" + utils.Docker.ClientVersion() + "/images/" + utils.GetRegistryImageUrl(utils.Config.Db.Image) + "/json").
 			ReplyError(errors.New("network error"))
 		// Run test
-		err := squashToVersion(context.Background(), "1", fsys)
+		_, err := squashToVersion(context.Background(), "1", RunParams{}, fsys)
 		// Check error
 		assert.ErrorContains(t, err, "network error")
 		assert.Empty(t, apitest.ListUnmatchedRequests())
 	})
 }
 
+func TestResolveVersion(t *testing.T) {
+	t.Run("reads trimmed version from stdin", func(t *testing.T) {
+		version, err := resolveVersion("-", strings.NewReader(" 20230101000000\n"))
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "20230101000000", version)
+	})
+
+	t.Run("keeps explicit version", func(t *testing.T) {
+		version, err := resolveVersion("1", strings.NewReader("2"))
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "1", version)
+	})
+
+	t.Run("throws error on empty stdin", func(t *testing.T) {
+		_, err := resolveVersion("-", strings.NewReader("\n"))
+		// Check error
+		assert.ErrorIs(t, err, repair.ErrInvalidVersion)
+	})
+}
+
+func TestKeepRecent(t *testing.T) {
+	setup := func(t *testing.T) afero.Fs {
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"0_init.sql", "1_users.sql", "2_posts.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte{}, 0644))
+		}
+		return fsys
+	}
+
+	t.Run("computes target from recent count", func(t *testing.T) {
+		fsys := setup(t)
+		// Run test
+		version, err := keepRecentVersion("", 2, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "0", version)
+	})
+
+	t.Run("accepts matching explicit version", func(t *testing.T) {
+		fsys := setup(t)
+		// Run test
+		version, err := keepRecentVersion("1", 1, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "1", version)
+	})
+
+	t.Run("throws error on conflicting version", func(t *testing.T) {
+		fsys := setup(t)
+		// Run test
+		_, err := keepRecentVersion("2", 1, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+
+	t.Run("throws error on too few migrations", func(t *testing.T) {
+		fsys := setup(t)
+		// Run test
+		_, err := keepRecentVersion("", 3, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrKeepRecent)
+	})
+}
+
 func TestSquashMigrations(t *testing.T) {
+	utils.Config.Db.Name = "postgres"
 	utils.Config.Db.MajorVersion = 15
 	utils.Config.Db.Image = utils.Pg15Image
 	utils.Config.Db.ShadowPort = 54320
@@ -202,7 +553,7 @@ func TestSquashMigrations(t *testing.T) {
 			Get("/v" + utils.Docker.ClientVersion() + "/images/" + utils.GetRegistryImageUrl(utils.Config.Db.Image) + "/json").
 			ReplyError(errors.New("network error"))
 		// Run test
-		err := squashMigrations(context.Background(), nil, fsys)
+		err := squashMigrations(context.Background(), nil, RunParams{}, nil, fsys)
 		// Check error
 		assert.ErrorContains(t, err, "network error")
 		assert.Empty(t, apitest.ListUnmatchedRequests())
@@ -223,7 +574,7 @@ func TestSquashMigrations(t *testing.T) {
 			Delete("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db").
 			Reply(http.StatusOK)
 		// Run test
-		err := squashMigrations(context.Background(), nil, fsys)
+		err := squashMigrations(context.Background(), nil, RunParams{}, nil, fsys)
 		// Check error
 		assert.ErrorIs(t, err, start.ErrDatabase)
 		assert.Empty(t, apitest.ListUnmatchedRequests())
@@ -255,7 +606,7 @@ func TestSquashMigrations(t *testing.T) {
 		conn := pgtest.NewConn()
 		defer conn.Close(t)
 		// Run test
-		err := squashMigrations(context.Background(), nil, fsys, conn.Intercept)
+		err := squashMigrations(context.Background(), nil, RunParams{}, nil, fsys, conn.Intercept)
 		// Check error
 		assert.ErrorContains(t, err, "network error")
 		assert.Empty(t, apitest.ListUnmatchedRequests())
@@ -293,54 +644,393 @@ func TestSquashMigrations(t *testing.T) {
 		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-db", sql))
 		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-db")
 		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-db", sql))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-db")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-db", sql))
 		// Setup mock postgres
 		conn := pgtest.NewConn()
 		defer conn.Close(t)
+		conn.Query(start.SELECT_MISSING_SCHEMAS, start.BaselineSchemas).
+			Reply("SELECT 0")
+		conn.Query(diff.SELECT_DATABASE_NAME, "postgres").
+			Reply("SELECT 1", []interface{}{"postgres"})
 		pgtest.MockMigrationHistory(conn)
 		conn.Query(sql).
 			Reply("CREATE SCHEMA").
 			Query(history.INSERT_MIGRATION_VERSION, "0", "init", []string{sql}).
 			Reply("INSERT 0 1")
+		conn.Query(LIST_FDW_EXTENSIONS).
+			Reply("SELECT 0")
 		// Run test
-		err := squashMigrations(context.Background(), []string{filepath.Base(path)}, afero.NewReadOnlyFs(fsys), conn.Intercept)
+		err := squashMigrations(context.Background(), []string{filepath.Base(path)}, RunParams{}, nil, afero.NewReadOnlyFs(fsys), conn.Intercept)
 		// Check error
 		assert.ErrorIs(t, err, os.ErrPermission)
 		assert.Empty(t, apitest.ListUnmatchedRequests())
 	})
-}
 
-func TestBaselineMigration(t *testing.T) {
-	t.Run("baselines earliest version", func(t *testing.T) {
+	t.Run("throws error on create database failure", func(t *testing.T) {
+		utils.Config.Db.Name = "app"
+		defer func() { utils.Config.Db.Name = "postgres" }()
 		// Setup in-memory fs
 		fsys := afero.NewMemMapFs()
-		paths := []string{
-			filepath.Join(utils.MigrationsDir, "0_init.sql"),
-			filepath.Join(utils.MigrationsDir, "1_target.sql"),
-		}
-		sql := "create schema test"
-		require.NoError(t, afero.WriteFile(fsys, paths[0], []byte(sql), 0644))
-		require.NoError(t, afero.WriteFile(fsys, paths[1], []byte{}, 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Config.Db.Image), "test-shadow-db")
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db/json").
+			Reply(http.StatusOK).
+			JSON(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+				State: &types.ContainerState{
+					Running: true,
+					Health:  &types.Health{Status: "healthy"},
+				},
+			}})
+		gock.New(utils.Docker.DaemonHost()).
+			Delete("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db").
+			Reply(http.StatusOK)
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.RealtimeImage), "test-realtime")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-realtime", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.StorageImage), "test-storage")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-storage", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.GotrueImage), "test-auth")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-auth", ""))
 		// Setup mock postgres
 		conn := pgtest.NewConn()
 		defer conn.Close(t)
-		pgtest.MockMigrationHistory(conn)
-		conn.Query(fmt.Sprintf("DELETE FROM supabase_migrations.schema_migrations WHERE version <=  '0' ;INSERT INTO supabase_migrations.schema_migrations(version, name, statements) VALUES( '0' ,  'init' ,  '{%s}' )", sql)).
-			Reply("INSERT 0 1")
+		conn.Query(start.SELECT_MISSING_SCHEMAS, start.BaselineSchemas).
+			Reply("SELECT 0")
+		conn.Query(diff.SELECT_DATABASE_NAME, "app").
+			Reply("SELECT 0").
+			Query(`CREATE DATABASE "app"`).
+			ReplyError(pgerrcode.InsufficientPrivilege, "permission denied to create database")
 		// Run test
-		err := baselineMigrations(context.Background(), dbConfig, "", fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
-			cc.PreferSimpleProtocol = true
-		})
+		err := squashMigrations(context.Background(), nil, RunParams{}, nil, fsys, conn.Intercept)
 		// Check error
-		assert.NoError(t, err)
+		assert.ErrorContains(t, err, "failed to create database: ERROR: permission denied to create database (SQLSTATE 42501)")
+		assert.Empty(t, apitest.ListUnmatchedRequests())
 	})
 
-	t.Run("throws error on connect failure", func(t *testing.T) {
+	t.Run("includes cluster objects", func(t *testing.T) {
 		// Setup in-memory fs
 		fsys := afero.NewMemMapFs()
-		// Run test
-		err := baselineMigrations(context.Background(), pgconn.Config{}, "0", fsys)
-		// Check error
-		assert.ErrorContains(t, err, "invalid port (outside range)")
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		sql := "create schema test"
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(sql), 0644))
+		cluster := "COMMENT ON ROLE \"app\" IS 'application role';\n"
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Config.Db.Image), "test-shadow-db")
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db/json").
+			Reply(http.StatusOK).
+			JSON(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+				State: &types.ContainerState{
+					Running: true,
+					Health:  &types.Health{Status: "healthy"},
+				},
+			}})
+		gock.New(utils.Docker.DaemonHost()).
+			Delete("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db").
+			Reply(http.StatusOK)
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.RealtimeImage), "test-realtime")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-realtime", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.StorageImage), "test-storage")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-storage", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.GotrueImage), "test-auth")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-auth", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-cluster")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-cluster", cluster))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-db")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-db", sql))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(start.SELECT_MISSING_SCHEMAS, start.BaselineSchemas).
+			Reply("SELECT 0")
+		conn.Query(diff.SELECT_DATABASE_NAME, "postgres").
+			Reply("SELECT 1", []interface{}{"postgres"})
+		pgtest.MockMigrationHistory(conn)
+		conn.Query(sql).
+			Reply("CREATE SCHEMA").
+			Query(history.INSERT_MIGRATION_VERSION, "0", "init", []string{sql}).
+			Reply("INSERT 0 1")
+		conn.Query(LIST_FDW_EXTENSIONS).
+			Reply("SELECT 0")
+		// Run test
+		params := RunParams{Full: true, NoManagedDiff: true}
+		err := squashMigrations(context.Background(), []string{filepath.Base(path)}, params, nil, fsys, conn.Intercept)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		assert.Equal(t, list.BaselineMarker+"\n"+cluster+sql, string(contents))
+	})
+
+	t.Run("removes custom dump on assertion failure", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		sql := "create schema test"
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(sql), 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Config.Db.Image), "test-shadow-db")
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db/json").
+			Reply(http.StatusOK).
+			JSON(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+				State: &types.ContainerState{
+					Running: true,
+					Health:  &types.Health{Status: "healthy"},
+				},
+			}})
+		gock.New(utils.Docker.DaemonHost()).
+			Delete("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db").
+			Reply(http.StatusOK)
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.RealtimeImage), "test-realtime")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-realtime", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.StorageImage), "test-storage")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-storage", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.GotrueImage), "test-auth")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-auth", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-db")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-db", "PGDMP"))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(start.SELECT_MISSING_SCHEMAS, start.BaselineSchemas).
+			Reply("SELECT 0")
+		conn.Query(diff.SELECT_DATABASE_NAME, "postgres").
+			Reply("SELECT 1", []interface{}{"postgres"})
+		pgtest.MockMigrationHistory(conn)
+		conn.Query(sql).
+			Reply("CREATE SCHEMA").
+			Query(history.INSERT_MIGRATION_VERSION, "0", "init", []string{sql}).
+			Reply("INSERT 0 1")
+		conn.Query(LIST_SCHEMA_OBJECTS).
+			Reply("SELECT 0")
+		// Run test
+		params := RunParams{Format: FormatCustom, AssertObjects: true, NoManagedDiff: true}
+		err := squashMigrations(context.Background(), []string{filepath.Base(path)}, params, nil, fsys, conn.Intercept)
+		// Check error
+		assert.ErrorContains(t, err, "failed to inspect docker image")
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		assert.Equal(t, sql, string(contents))
+		exists, err := afero.Exists(fsys, repair.GetCustomDumpPath("0"))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("dumps managed schemas from empty", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		sql := "create schema test"
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(sql), 0644))
+		schema := "CREATE TABLE IF NOT EXISTS \"auth\".\"users\" ();\n\n" + sql
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Config.Db.Image), "test-shadow-db")
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db/json").
+			Reply(http.StatusOK).
+			JSON(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+				State: &types.ContainerState{
+					Running: true,
+					Health:  &types.Health{Status: "healthy"},
+				},
+			}})
+		gock.New(utils.Docker.DaemonHost()).
+			Delete("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db").
+			Reply(http.StatusOK)
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.RealtimeImage), "test-realtime")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-realtime", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.StorageImage), "test-storage")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-storage", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.GotrueImage), "test-auth")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-auth", ""))
+		// Only the full schema is dumped without before and after managed dumps
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-db")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-db", schema))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(start.SELECT_MISSING_SCHEMAS, start.BaselineSchemas).
+			Reply("SELECT 0")
+		conn.Query(diff.SELECT_DATABASE_NAME, "postgres").
+			Reply("SELECT 1", []interface{}{"postgres"})
+		pgtest.MockMigrationHistory(conn)
+		conn.Query(sql).
+			Reply("CREATE SCHEMA").
+			Query(history.INSERT_MIGRATION_VERSION, "0", "init", []string{sql}).
+			Reply("INSERT 0 1")
+		conn.Query(LIST_FDW_EXTENSIONS).
+			Reply("SELECT 0")
+		// Run test
+		params := RunParams{FromEmpty: true, NoManagedDiff: true}
+		err := squashMigrations(context.Background(), []string{filepath.Base(path)}, params, nil, fsys, conn.Intercept)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		assert.Equal(t, list.BaselineMarker+"\n"+schema, string(contents))
+	})
+
+	t.Run("throws error on seed failure", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		sql := "create schema test"
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(sql), 0644))
+		seed := "INSERT INTO test.employees(name) VALUES ('Alice')"
+		require.NoError(t, afero.WriteFile(fsys, utils.SeedDataPath, []byte(seed), 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Config.Db.Image), "test-shadow-db")
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db/json").
+			Reply(http.StatusOK).
+			JSON(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+				State: &types.ContainerState{
+					Running: true,
+					Health:  &types.Health{Status: "healthy"},
+				},
+			}})
+		gock.New(utils.Docker.DaemonHost()).
+			Delete("/v" + utils.Docker.ClientVersion() + "/containers/test-shadow-db").
+			Reply(http.StatusOK)
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.RealtimeImage), "test-realtime")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-realtime", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.StorageImage), "test-storage")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-storage", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.GotrueImage), "test-auth")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-auth", ""))
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-db")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-db", sql))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(start.SELECT_MISSING_SCHEMAS, start.BaselineSchemas).
+			Reply("SELECT 0")
+		conn.Query(diff.SELECT_DATABASE_NAME, "postgres").
+			Reply("SELECT 1", []interface{}{"postgres"})
+		pgtest.MockMigrationHistory(conn)
+		conn.Query(sql).
+			Reply("CREATE SCHEMA").
+			Query(history.INSERT_MIGRATION_VERSION, "0", "init", []string{sql}).
+			Reply("INSERT 0 1").
+			Query(seed).
+			ReplyError(pgerrcode.UndefinedTable, `relation "test.employees" does not exist`)
+		// Run test
+		err := squashMigrations(context.Background(), []string{filepath.Base(path)}, RunParams{Seed: true}, nil, fsys, conn.Intercept)
+		// Check error
+		assert.ErrorContains(t, err, `ERROR: relation "test.employees" does not exist (SQLSTATE 42P01)`)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
+
+func TestBaselineMigration(t *testing.T) {
+	t.Run("baselines earliest version", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		paths := []string{
+			filepath.Join(utils.MigrationsDir, "0_init.sql"),
+			filepath.Join(utils.MigrationsDir, "1_target.sql"),
+		}
+		sql := "create schema test"
+		require.NoError(t, afero.WriteFile(fsys, paths[0], []byte(sql), 0644))
+		require.NoError(t, afero.WriteFile(fsys, paths[1], []byte{}, 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		pgtest.MockMigrationHistory(conn)
+		conn.Query("begin").Reply("BEGIN")
+		conn.Query(fmt.Sprintf("DELETE FROM supabase_migrations.schema_migrations WHERE version <=  '0' ;INSERT INTO supabase_migrations.schema_migrations(version, name, statements) VALUES( '0' ,  'init' ,  '{%s}' )", sql)).
+			Reply("INSERT 0 1").
+			Query("commit").Reply("COMMIT")
+		// Run test
+		err := baselineMigrations(context.Background(), dbConfig, "", "", fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
+			cc.PreferSimpleProtocol = true
+		})
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on connect failure", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		err := baselineMigrations(context.Background(), pgconn.Config{}, "0", "", fsys)
+		// Check error
+		assert.ErrorContains(t, err, "invalid port (outside range)")
+	})
+
+	t.Run("keeps newer migrations in history", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"1_target.sql", "2_newer.sql"} {
+			path := filepath.Join(utils.MigrationsDir, name)
+			require.NoError(t, afero.WriteFile(fsys, path, []byte(""), 0644))
+		}
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		pgtest.MockMigrationHistory(conn)
+		// Remote history has 0, 1 and 2 applied, only 0 and 1 are reset
+		conn.Query("begin").Reply("BEGIN")
+		conn.Query("DELETE FROM supabase_migrations.schema_migrations WHERE version <=  '1' ;INSERT INTO supabase_migrations.schema_migrations(version, name, statements) VALUES( '1' ,  'target' ,  null )").
+			Reply("INSERT 0 1").
+			Query("commit").Reply("COMMIT")
+		// Run test
+		err := baselineMigrations(context.Background(), dbConfig, "1", "", fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
+			cc.PreferSimpleProtocol = true
+		})
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("updates history as separate role", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(""), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(`SET ROLE "migrations_admin"`).
+			Reply("SET")
+		pgtest.MockMigrationHistory(conn)
+		conn.Query("begin").Reply("BEGIN")
+		conn.Query("DELETE FROM supabase_migrations.schema_migrations WHERE version <=  '0' ;INSERT INTO supabase_migrations.schema_migrations(version, name, statements) VALUES( '0' ,  'init' ,  null )").
+			Reply("INSERT 0 1").
+			Query("commit").Reply("COMMIT")
+		// Run test
+		err := baselineMigrations(context.Background(), dbConfig, "0", "migrations_admin", fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
+			cc.PreferSimpleProtocol = true
+		})
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on missing history role", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(`SET ROLE "migrations_admin"`).
+			ReplyError(pgerrcode.InvalidParameterValue, `role "migrations_admin" does not exist`)
+		// Run test
+		err := baselineMigrations(context.Background(), dbConfig, "0", "migrations_admin", fsys, conn.Intercept)
+		// Check error
+		assert.ErrorContains(t, err, `failed to set role: ERROR: role "migrations_admin" does not exist`)
 	})
 
 	t.Run("throws error on query failure", func(t *testing.T) {
@@ -352,16 +1042,42 @@ func TestBaselineMigration(t *testing.T) {
 		conn := pgtest.NewConn()
 		defer conn.Close(t)
 		pgtest.MockMigrationHistory(conn)
+		conn.Query("begin").Reply("BEGIN")
 		conn.Query(fmt.Sprintf("DELETE FROM supabase_migrations.schema_migrations WHERE version <=  '%[1]s' ;INSERT INTO supabase_migrations.schema_migrations(version, name, statements) VALUES( '%[1]s' ,  'init' ,  null )", "0")).
-			ReplyError(pgerrcode.InsufficientPrivilege, "permission denied for relation supabase_migrations")
+			ReplyError(pgerrcode.InsufficientPrivilege, "permission denied for relation supabase_migrations").
+			Query("rollback").Reply("ROLLBACK")
 		// Run test
-		err := baselineMigrations(context.Background(), dbConfig, "0", fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
+		err := baselineMigrations(context.Background(), dbConfig, "0", "", fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
 			cc.PreferSimpleProtocol = true
 		})
 		// Check error
 		assert.ErrorContains(t, err, `ERROR: permission denied for relation supabase_migrations (SQLSTATE 42501)`)
 	})
 
+	t.Run("rolls back delete on insert failure", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "1_target.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte(""), 0644))
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		pgtest.MockMigrationHistory(conn)
+		conn.Query("begin").Reply("BEGIN")
+		conn.Query("DELETE FROM supabase_migrations.schema_migrations WHERE version <=  '1' ;INSERT INTO supabase_migrations.schema_migrations(version, name, statements) VALUES( '1' ,  'target' ,  null )").
+			Reply("DELETE 1").
+			Reply("DELETE 0").
+			ReplyError(pgerrcode.UniqueViolation, `duplicate key value violates unique constraint "schema_migrations_pkey"`).
+			Query("rollback").Reply("ROLLBACK")
+		// Run test
+		err := baselineMigrations(context.Background(), dbConfig, "1", "", fsys, conn.Intercept, func(cc *pgx.ConnConfig) {
+			cc.PreferSimpleProtocol = true
+		})
+		// Check error
+		assert.ErrorContains(t, err, "failed to update migration history")
+		assert.ErrorContains(t, err, "(SQLSTATE 23505)")
+	})
+
 	t.Run("throws error on missing file", func(t *testing.T) {
 		// Setup in-memory fs
 		fsys := afero.NewMemMapFs()
@@ -370,7 +1086,7 @@ func TestBaselineMigration(t *testing.T) {
 		defer conn.Close(t)
 		pgtest.MockMigrationHistory(conn)
 		// Run test
-		err := baselineMigrations(context.Background(), dbConfig, "0", fsys, conn.Intercept)
+		err := baselineMigrations(context.Background(), dbConfig, "0", "", fsys, conn.Intercept)
 		// Check error
 		assert.ErrorIs(t, err, os.ErrNotExist)
 	})
@@ -389,18 +1105,30 @@ func TestLineByLine(t *testing.T) {
 		require.NoError(t, err)
 		// Run test
 		var out bytes.Buffer
-		err = lineByLineDiff(before, after, &out)
+		_, err = lineByLineDiff(before, after, false, &out)
 		// Check error
 		assert.NoError(t, err)
 		assert.Equal(t, expected, out.Bytes())
 	})
 
+	t.Run("diffs multi megabyte line", func(t *testing.T) {
+		long := "select '" + strings.Repeat("x", 4*1024*1024) + "';"
+		before := strings.NewReader("select 1;\n")
+		after := strings.NewReader("select 1;\n" + long + "\nselect 2;\n")
+		// Run test
+		var out bytes.Buffer
+		_, err := lineByLineDiff(before, after, false, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, long+"\nselect 2;\n", out.String())
+	})
+
 	t.Run("diffs shorter before", func(t *testing.T) {
 		before := strings.NewReader("select 1;")
 		after := strings.NewReader("select 0;\nselect 1;\nselect 2;")
 		// Run test
 		var out bytes.Buffer
-		err := lineByLineDiff(before, after, &out)
+		_, err := lineByLineDiff(before, after, false, &out)
 		// Check error
 		assert.NoError(t, err)
 		assert.Equal(t, "select 0;\nselect 2;\n", out.String())
@@ -411,7 +1139,7 @@ func TestLineByLine(t *testing.T) {
 		after := strings.NewReader("select 1;")
 		// Run test
 		var out bytes.Buffer
-		err := lineByLineDiff(before, after, &out)
+		_, err := lineByLineDiff(before, after, false, &out)
 		// Check error
 		assert.NoError(t, err)
 		assert.Equal(t, "", out.String())
@@ -422,9 +1150,420 @@ func TestLineByLine(t *testing.T) {
 		after := strings.NewReader("select 1;")
 		// Run test
 		var out bytes.Buffer
-		err := lineByLineDiff(before, after, &out)
+		_, err := lineByLineDiff(before, after, false, &out)
 		// Check error
 		assert.NoError(t, err)
 		assert.Equal(t, "select 1;\n", out.String())
 	})
+
+	t.Run("preserves invalid utf8 bytes", func(t *testing.T) {
+		line := []byte("ALTER TABLE \"auth\".\"keys\" ALTER COLUMN \"raw\" SET DEFAULT '\xff\xfe\xc3';\n")
+		before := strings.NewReader("select 1;\n")
+		after := bytes.NewReader(append([]byte("select 1;\n"), line...))
+		// Run test
+		var out bytes.Buffer
+		_, err := lineByLineDiff(before, after, true, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, line, out.Bytes())
+	})
+
+	t.Run("matches invalid utf8 bytes", func(t *testing.T) {
+		before := strings.NewReader("SET DEFAULT '\xff';\n")
+		after := strings.NewReader("SET DEFAULT '\xff';\nSET DEFAULT '\xfe';\n")
+		// Run test
+		var out bytes.Buffer
+		_, err := lineByLineDiff(before, after, false, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("SET DEFAULT '\xfe';\n"), out.Bytes())
+	})
+
+	t.Run("ignores whitespace only changes", func(t *testing.T) {
+		before := strings.NewReader("CREATE TABLE \"auth\".\"users\" ();\n    ADD CONSTRAINT \"users_pkey\"  PRIMARY KEY (\"id\");\n")
+		after := strings.NewReader("CREATE TABLE \"auth\".\"users\" ();  \n\tADD CONSTRAINT \"users_pkey\" PRIMARY KEY (\"id\");\n")
+		// Run test
+		var out bytes.Buffer
+		stats, err := lineByLineDiff(before, after, true, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, out.String())
+		assert.Empty(t, stats)
+	})
+
+	t.Run("keeps original line on content change", func(t *testing.T) {
+		before := strings.NewReader("select  1;\n")
+		after := strings.NewReader("select 1;\n  select  2;\n")
+		// Run test
+		var out bytes.Buffer
+		_, err := lineByLineDiff(before, after, true, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, "  select  2;\n", out.String())
+	})
+
+	t.Run("counts changes per schema", func(t *testing.T) {
+		before := strings.NewReader(`CREATE TABLE "auth"."users" ();
+GRANT ALL ON TABLE "storage"."objects" TO "anon";
+`)
+		after := strings.NewReader(`CREATE TABLE "auth"."users" ();
+ALTER TABLE ONLY "auth"."users"
+    ADD CONSTRAINT "users_pkey" PRIMARY KEY ("id");
+
+CREATE POLICY "test" ON "storage"."objects" USING ("auth"."uid"() = "owner");
+`)
+		// Run test
+		var out bytes.Buffer
+		stats, err := lineByLineDiff(before, after, false, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, diffStats{
+			"auth":    {Added: 2},
+			"storage": {Changed: 1},
+		}, stats)
+		assert.Equal(t, "2 changes to auth, 1 to storage, 0 to public", stats.Summary([]string{"auth", "storage", "public"}))
+	})
+}
+
+func TestPairChanges(t *testing.T) {
+	t.Run("counts replaced lines as changed", func(t *testing.T) {
+		stats := diffStats{
+			"auth":    {Added: 3, Removed: 1},
+			"storage": {Removed: 2},
+		}
+		// Run test
+		stats.pairChanges()
+		// Check output
+		assert.Equal(t, diffStats{
+			"auth":    {Added: 2, Changed: 1},
+			"storage": {Removed: 2},
+		}, stats)
+	})
+}
+
+func TestFilterGrants(t *testing.T) {
+	t.Run("drops default grants on managed schemas", func(t *testing.T) {
+		diffs := strings.NewReader(`GRANT USAGE ON SCHEMA "auth" TO "anon";
+GRANT ALL ON ALL TABLES IN SCHEMA "storage" TO "service_role";
+ALTER DEFAULT PRIVILEGES FOR ROLE "supabase_auth_admin" IN SCHEMA "auth" GRANT ALL ON TABLES  TO "authenticated";
+GRANT SELECT ON TABLE "auth"."users" TO "anon";
+CREATE TABLE "auth"."test" ();
+`)
+		// Run test
+		var out bytes.Buffer
+		err := filterGrants(diffs, utils.DefaultExcludedGrants, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, `GRANT SELECT ON TABLE "auth"."users" TO "anon";
+CREATE TABLE "auth"."test" ();
+`, out.String())
+	})
+
+	t.Run("ignores non grant statements", func(t *testing.T) {
+		diffs := strings.NewReader(`COMMENT ON SCHEMA "public" IS 'TO "anon"';` + "\n")
+		// Run test
+		var out bytes.Buffer
+		err := filterGrants(diffs, []string{`"anon"`}, &out)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, `COMMENT ON SCHEMA "public" IS 'TO "anon"';`+"\n", out.String())
+	})
+}
+
+func TestNoticeCollector(t *testing.T) {
+	t.Run("collects notices while enabled", func(t *testing.T) {
+		var notices noticeCollector
+		notices.Handle(nil, &pgconn.Notice{Severity: "NOTICE", Message: "setup"})
+		notices.enabled = true
+		notices.Handle(nil, &pgconn.Notice{Severity: "NOTICE", Message: `relation "test" already exists, skipping`})
+		notices.Handle(nil, &pgconn.Notice{Severity: "WARNING", Message: "there is no transaction in progress"})
+		notices.Handle(nil, &pgconn.Notice{Severity: "DEBUG", Message: "ignored"})
+		// Run test
+		var out bytes.Buffer
+		notices.Print(&out)
+		// Check output
+		assert.Len(t, notices.notices, 2)
+		assert.Contains(t, out.String(), `NOTICE: relation "test" already exists, skipping`)
+		assert.Contains(t, out.String(), "WARNING: there is no transaction in progress")
+		assert.NotContains(t, out.String(), "setup")
+	})
+
+	t.Run("prints nothing without notices", func(t *testing.T) {
+		var notices noticeCollector
+		// Run test
+		var out bytes.Buffer
+		notices.Print(&out)
+		// Check output
+		assert.Empty(t, out.String())
+	})
+}
+
+func TestSquashList(t *testing.T) {
+	t.Run("lists migrations to merge", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"0_init.sql", "1_target.sql", "2_after.sql"} {
+			path := filepath.Join(utils.MigrationsDir, name)
+			require.NoError(t, afero.WriteFile(fsys, path, []byte{}, 0644))
+		}
+		// Run test
+		err := RunList("1", fsys)
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on invalid version", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		err := RunList("0_init", fsys)
+		// Check error
+		assert.ErrorIs(t, err, repair.ErrInvalidVersion)
+	})
+
+	t.Run("throws error on missing version", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		err := RunList("", fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrMissingVersion)
+	})
+}
+
+func TestWriteCustomDump(t *testing.T) {
+	t.Run("writes custom format archive", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		path := filepath.Join(utils.MigrationsDir, "0.dump")
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-db")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-db", "PGDMP"))
+		// Run test
+		err := writeCustomDump(context.Background(), dbConfig, path, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("PGDMP"), contents)
+	})
+
+	t.Run("throws error on permission denied", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewReadOnlyFs(afero.NewMemMapFs())
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.Pg15Image), "test-db")
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, "test-db", "PGDMP"))
+		// Run test
+		err := writeCustomDump(context.Background(), dbConfig, filepath.Join(utils.MigrationsDir, "0.dump"), fsys)
+		// Check error
+		assert.ErrorIs(t, err, os.ErrPermission)
+	})
+}
+
+func TestSetRole(t *testing.T) {
+	t.Run("sets role on shadow connection", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(`SET ROLE "app_owner"`).
+			Reply("SET")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = setRole(ctx, mock, "app_owner")
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("skips default role", func(t *testing.T) {
+		// Run test
+		err := setRole(context.Background(), nil, "postgres")
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on missing role", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query(`SET ROLE "app_owner"`).
+			ReplyError(pgerrcode.InvalidParameterValue, `role "app_owner" does not exist`)
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = setRole(ctx, mock, "app_owner")
+		// Check error
+		assert.ErrorContains(t, err, `role "app_owner" does not exist`)
+	})
+}
+
+func TestWritesFiles(t *testing.T) {
+	t.Run("allows default sql diff format", func(t *testing.T) {
+		assert.False(t, writesFiles(RunParams{Format: FormatSql, DiffFormat: "sql"}))
+	})
+
+	t.Run("detects file outputs", func(t *testing.T) {
+		assert.True(t, writesFiles(RunParams{Checksum: true}))
+		assert.True(t, writesFiles(RunParams{Data: DataSeed}))
+		assert.True(t, writesFiles(RunParams{Branch: "feature", Verify: true}))
+	})
+}
+
+func TestAnalyzeDatabase(t *testing.T) {
+	t.Run("analyzes shadow database", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query("ANALYZE").
+			Reply("ANALYZE")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = analyzeDatabase(ctx, mock)
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on failure", func(t *testing.T) {
+		// Setup mock postgres
+		conn := pgtest.NewConn()
+		defer conn.Close(t)
+		conn.Query("ANALYZE").
+			ReplyError(pgerrcode.QueryCanceled, "canceling statement due to statement timeout")
+		// Connect to mock
+		ctx := context.Background()
+		mock, err := utils.ConnectLocalPostgres(ctx, pgconn.Config{Port: 5432}, conn.Intercept)
+		require.NoError(t, err)
+		defer mock.Close(ctx)
+		// Run test
+		err = analyzeDatabase(ctx, mock)
+		// Check error
+		assert.ErrorContains(t, err, "canceling statement due to statement timeout")
+	})
+}
+
+func TestWriteSquashed(t *testing.T) {
+	utils.Config.Db.Squash.MaxFileSize = 8
+	defer func() { utils.Config.Db.Squash.MaxFileSize = 0 }()
+	path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+
+	t.Run("throws error on file too large", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		err := writeSquashed(path, []byte("create schema test"), RunParams{}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrFileTooLarge)
+		exists, err := afero.Exists(fsys, path)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("writes large file with force", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		err := writeSquashed(path, []byte("create schema test"), RunParams{Force: true}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, path)
+		assert.NoError(t, err)
+		assert.Equal(t, list.BaselineMarker+"\ncreate schema test", string(contents))
+	})
+}
+
+func TestLockMigrations(t *testing.T) {
+	t.Run("throws error on lock timeout", func(t *testing.T) {
+		lock, err := lockMigrations(context.Background(), time.Second)
+		require.NoError(t, err)
+		defer lock.Unlock()
+		// Run test
+		_, err = lockMigrations(context.Background(), 200*time.Millisecond)
+		// Check error
+		assert.ErrorIs(t, err, ErrInProgress)
+	})
+
+	t.Run("locks again after release", func(t *testing.T) {
+		lock, err := lockMigrations(context.Background(), time.Second)
+		require.NoError(t, err)
+		require.NoError(t, lock.Unlock())
+		// Run test
+		lock, err = lockMigrations(context.Background(), time.Second)
+		// Check error
+		assert.NoError(t, err)
+		assert.NoError(t, lock.Unlock())
+	})
+}
+
+func TestLabelOptions(t *testing.T) {
+	t.Run("omits security labels", func(t *testing.T) {
+		opts := labelOptions(RunParams{NoSecurityLabels: true})
+		// Check output
+		require.Len(t, opts, 1)
+		assert.Equal(t, []string{"--no-security-labels"}, opts[0](nil))
+	})
+
+	t.Run("keeps security labels by default", func(t *testing.T) {
+		assert.Empty(t, labelOptions(RunParams{}))
+	})
+}
+
+func TestSquashedOptions(t *testing.T) {
+	t.Run("quotes all identifiers in custom dumps", func(t *testing.T) {
+		opts := squashedOptions(RunParams{QuoteAllIdentifiers: true, Format: FormatCustom})
+		// Check output
+		var flags []string
+		for _, apply := range opts {
+			flags = apply(flags)
+		}
+		assert.Contains(t, flags, "--quote-all-identifiers")
+	})
+
+	t.Run("omits flag for plain dumps", func(t *testing.T) {
+		var flags []string
+		for _, apply := range squashedOptions(RunParams{QuoteAllIdentifiers: true}) {
+			flags = apply(flags)
+		}
+		// Check output
+		assert.NotContains(t, flags, "--quote-all-identifiers")
+	})
+
+	t.Run("omits quoting by default", func(t *testing.T) {
+		var flags []string
+		for _, apply := range squashedOptions(RunParams{}) {
+			flags = apply(flags)
+		}
+		// Check output
+		assert.NotContains(t, flags, "--quote-all-identifiers")
+	})
+}
+
+func TestRemoteOptions(t *testing.T) {
+	t.Run("does not share backing array", func(t *testing.T) {
+		noop := func(*pgx.ConnConfig) {}
+		before := len(remoteOptions)
+		// Run test
+		first := withRemoteOptions([]func(*pgx.ConnConfig){noop})
+		second := withRemoteOptions(nil)
+		// Check output
+		assert.Len(t, first, before+1)
+		assert.Len(t, second, before)
+		assert.Len(t, remoteOptions, before)
+		assert.Equal(t, before, cap(second))
+	})
 }
@@ -0,0 +1,82 @@
+package squash
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Counts lines added, removed, and changed per schema by the managed schema diff.
+type diffStats map[string]*schemaStats
+
+type schemaStats struct {
+	Added   int
+	Removed int
+	// Removed lines replaced by an added line in the same schema
+	Changed int
+}
+
+func (s *schemaStats) Changes() int {
+	if s == nil {
+		return 0
+	}
+	return s.Added + s.Removed + s.Changed
+}
+
+// Pairs removed lines with added lines of the same schema, counting each pair as one change.
+func (s diffStats) pairChanges() {
+	for _, stats := range s {
+		stats.Changed = min(stats.Added, stats.Removed)
+		stats.Added -= stats.Changed
+		stats.Removed -= stats.Changed
+	}
+}
+
+var qualifiedPattern = regexp.MustCompile(`"(\w+)"\."`)
+
+// Attributes each line to the first schema qualified in its statement.
+type statsCounter struct {
+	stats   diffStats
+	schema  string
+	removed bool
+}
+
+func (c *statsCounter) count(line string) {
+	trimmed := strings.TrimSpace(line)
+	if len(trimmed) == 0 || strings.HasPrefix(trimmed, "--") {
+		return
+	}
+	if len(c.schema) == 0 {
+		if matches := qualifiedPattern.FindStringSubmatch(line); len(matches) > 1 {
+			c.schema = matches[1]
+		}
+	}
+	if len(c.schema) > 0 {
+		s, ok := c.stats[c.schema]
+		if !ok {
+			s = &schemaStats{}
+			c.stats[c.schema] = s
+		}
+		if c.removed {
+			s.Removed++
+		} else {
+			s.Added++
+		}
+	}
+	if strings.HasSuffix(trimmed, ";") {
+		c.schema = ""
+	}
+}
+
+// Formats a summary such as "3 changes to auth, 0 to storage".
+func (s diffStats) Summary(schemas []string) string {
+	parts := make([]string, len(schemas))
+	for i, name := range schemas {
+		format := "%d to %s"
+		if i == 0 {
+			format = "%d changes to %s"
+		}
+		parts[i] = fmt.Sprintf(format, s[name].Changes(), name)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,37 @@
+package squash
+
+import (
+	"strings"
+
+	"github.com/go-errors/errors"
+	pgquery "github.com/wasilibs/go-pgquery"
+	"github.com/wasilibs/go-pgquery/parser"
+)
+
+var ErrSyntax = errors.New("invalid syntax in squashed migration")
+
+// Parses the squashed migration with the Postgres parser, which catches post-processing
+// transforms that produce invalid SQL without requiring a running database.
+func validateSyntax(sql string) error {
+	if _, err := pgquery.Parse(sql); err != nil {
+		var parseErr *parser.Error
+		if errors.As(err, &parseErr) && parseErr.Cursorpos > 0 {
+			line, column := cursorPosition(sql, parseErr.Cursorpos)
+			return errors.Errorf("%w at line %d, column %d: %s", ErrSyntax, line, column, parseErr.Message)
+		}
+		return errors.Errorf("%w: %s", ErrSyntax, err.Error())
+	}
+	return nil
+}
+
+// Converts the 1-based byte offset reported by the parser to a line and column.
+func cursorPosition(sql string, cursor int) (int, int) {
+	end := cursor - 1
+	if end > len(sql) {
+		end = len(sql)
+	}
+	prefix := sql[:end]
+	line := strings.Count(prefix, "\n") + 1
+	column := len([]rune(prefix[strings.LastIndex(prefix, "\n")+1:])) + 1
+	return line, column
+}
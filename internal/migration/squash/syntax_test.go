@@ -0,0 +1,55 @@
+package squash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSyntax(t *testing.T) {
+	t.Run("accepts valid schema", func(t *testing.T) {
+		sql := `-- it's a comment (
+/* nested /* block */ comment ) */
+CREATE FUNCTION "public"."f"("a" text) RETURNS text LANGUAGE plpgsql AS $fn$
+begin
+  return format('%s)', $1) || E'\'(';
+end;
+$fn$;
+CREATE TABLE "public"."t" ("cost$" numeric(10, 2) DEFAULT 'it''s');
+`
+		// Run test
+		assert.NoError(t, validateSyntax(sql))
+	})
+
+	t.Run("throws error on unclosed parenthesis", func(t *testing.T) {
+		sql := "CREATE TABLE \"public\".\"t\" (\n    \"id\" bigint;\n"
+		// Run test
+		err := validateSyntax(sql)
+		// Check error
+		assert.ErrorIs(t, err, ErrSyntax)
+		assert.ErrorContains(t, err, `at line 2, column 16: syntax error at or near ";"`)
+	})
+
+	t.Run("throws error on unterminated string", func(t *testing.T) {
+		sql := "select 1;\nselect 'abc;\n"
+		// Run test
+		err := validateSyntax(sql)
+		// Check error
+		assert.ErrorContains(t, err, "at line 2, column 8: unterminated quoted string")
+	})
+
+	t.Run("throws error on unterminated dollar quote", func(t *testing.T) {
+		sql := "CREATE FUNCTION f() RETURNS void AS $$ select 1;"
+		// Run test
+		err := validateSyntax(sql)
+		// Check error
+		assert.ErrorContains(t, err, "unterminated dollar-quoted string")
+	})
+
+	t.Run("throws error on misspelled keyword", func(t *testing.T) {
+		// Run test
+		err := validateSyntax("create schema a;\ncreat table b ();")
+		// Check error
+		assert.ErrorContains(t, err, `at line 2, column 1: syntax error at or near "creat"`)
+	})
+}
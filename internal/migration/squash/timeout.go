@@ -0,0 +1,17 @@
+package squash
+
+import (
+	"context"
+
+	"github.com/go-errors/errors"
+)
+
+var ErrTimeout = errors.New("squash timed out")
+
+// Names the phase that was active when the squash deadline was exceeded.
+func timeoutError(ctx context.Context, err error, phase string) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, ErrTimeout) {
+		return err
+	}
+	return errors.Errorf("%w during %s phase: %w", ErrTimeout, phase, err)
+}
@@ -0,0 +1,49 @@
+package squash
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutError(t *testing.T) {
+	t.Run("reports active phase on deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+		defer cancel()
+		<-ctx.Done()
+		// Run test
+		err := timeoutError(ctx, errors.Errorf("failed to dump: %w", ctx.Err()), "dump")
+		// Check error
+		assert.ErrorIs(t, err, ErrTimeout)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "squash timed out during dump phase")
+	})
+
+	t.Run("keeps innermost phase", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+		defer cancel()
+		<-ctx.Done()
+		// Run test
+		err := timeoutError(ctx, timeoutError(ctx, ctx.Err(), "migrate"), "squash")
+		// Check error
+		assert.ErrorContains(t, err, "during migrate phase")
+		assert.NotContains(t, err.Error(), "squash phase")
+	})
+
+	t.Run("ignores errors before deadline", func(t *testing.T) {
+		expected := errors.New("network error")
+		// Run test
+		err := timeoutError(context.Background(), expected, "start")
+		// Check error
+		assert.Equal(t, expected, err)
+	})
+
+	t.Run("ignores nil error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.NoError(t, timeoutError(ctx, nil, "start"))
+	})
+}
@@ -0,0 +1,73 @@
+package squash
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	createViewPattern    = regexp.MustCompile(`(?i)^\s*CREATE (?:OR REPLACE )?(?:MATERIALIZED )?VIEW (?:IF NOT EXISTS )?("[^"]+"\."[^"]+")`)
+	createTriggerPattern = regexp.MustCompile(`(?is)^\s*CREATE (?:OR REPLACE )?(?:CONSTRAINT )?TRIGGER "[^"]+" .*? ON ("[^"]+"\."[^"]+") .*?EXECUTE (?:FUNCTION|PROCEDURE) ("[^"]+"\."[^"]+")\(`)
+)
+
+// Defers triggers until their table and function are created, and functions until the views
+// they reference are created. Statements altering a deferred object follow it. Passes repeat
+// until stable because deferring a function may in turn defer the triggers calling it.
+func orderTriggers(stats []string) []string {
+	for range stats {
+		result, moved := deferTriggers(stats)
+		if !moved {
+			break
+		}
+		stats = result
+	}
+	return stats
+}
+
+func deferTriggers(stats []string) ([]string, bool) {
+	bodies := make([]string, len(stats))
+	created := map[string]int{}
+	views := map[string]int{}
+	for i, sql := range stats {
+		bodies[i] = commentLinePattern.ReplaceAllString(sql, "")
+		if matches := createViewPattern.FindStringSubmatch(bodies[i]); len(matches) > 1 {
+			created[matches[1]] = i
+			views[matches[1]] = i
+		} else if matches := createFunctionPattern.FindStringSubmatch(bodies[i]); len(matches) > 1 {
+			created[matches[1]] = i
+		} else if matches := createTablePattern.FindStringSubmatch(bodies[i]); len(matches) > 1 {
+			created[matches[1]] = i
+		}
+	}
+	deferred := map[int][]string{}
+	result := make([]string, 0, len(stats))
+	for i, sql := range stats {
+		var deps []string
+		if matches := createTriggerPattern.FindStringSubmatch(bodies[i]); len(matches) > 2 {
+			deps = append(deps, matches[1:]...)
+		} else if matches := createFunctionPattern.FindStringSubmatch(bodies[i]); len(matches) > 1 {
+			for _, name := range qualifiedNamePattern.FindAllString(bodies[i], -1) {
+				// Views calling the function must still be created after it
+				if j, ok := views[name]; ok && !strings.Contains(bodies[j], matches[1]+"(") {
+					deps = append(deps, name)
+				}
+			}
+		} else if name := qualifiedNamePattern.FindString(bodies[i]); len(name) > 0 {
+			deps = append(deps, name)
+		}
+		last := i
+		for _, name := range deps {
+			if j, ok := created[name]; ok && j > last {
+				last = j
+			}
+		}
+		if last > i {
+			// Statements waiting on this one move along with it
+			deferred[last] = append(append(deferred[last], sql), deferred[i]...)
+			continue
+		}
+		result = append(result, sql)
+		result = append(result, deferred[i]...)
+	}
+	return result, len(deferred) > 0
+}
@@ -0,0 +1,75 @@
+package squash
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTriggerOrder(t *testing.T) {
+	t.Run("moves trigger after function referencing view", func(t *testing.T) {
+		sql := `CREATE TABLE IF NOT EXISTS "public"."orders" (
+    "id" bigint
+);
+
+CREATE OR REPLACE TRIGGER "on_order" AFTER INSERT ON "public"."orders" FOR EACH ROW EXECUTE FUNCTION "public"."refresh_totals"();
+
+CREATE OR REPLACE FUNCTION "public"."refresh_totals"() RETURNS "trigger"
+    LANGUAGE "sql"
+    BEGIN ATOMIC
+ SELECT count(*) FROM "public"."totals";
+END;
+
+ALTER FUNCTION "public"."refresh_totals"() OWNER TO "postgres";
+
+CREATE OR REPLACE VIEW "public"."totals" AS
+ SELECT count(*) AS "count"
+   FROM "public"."orders";
+
+RESET ALL;
+`
+		var out bytes.Buffer
+		// Run test
+		err := writeOrderedSchema(strings.NewReader(sql), &out, RunParams{})
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, `CREATE TABLE IF NOT EXISTS "public"."orders" (
+    "id" bigint
+);
+
+CREATE OR REPLACE VIEW "public"."totals" AS
+ SELECT count(*) AS "count"
+   FROM "public"."orders";
+
+CREATE OR REPLACE FUNCTION "public"."refresh_totals"() RETURNS "trigger"
+    LANGUAGE "sql"
+    BEGIN ATOMIC
+ SELECT count(*) FROM "public"."totals";
+END;
+
+ALTER FUNCTION "public"."refresh_totals"() OWNER TO "postgres";
+
+CREATE OR REPLACE TRIGGER "on_order" AFTER INSERT ON "public"."orders" FOR EACH ROW EXECUTE FUNCTION "public"."refresh_totals"();
+
+RESET ALL;
+`, out.String())
+	})
+
+	t.Run("keeps function before view calling it", func(t *testing.T) {
+		sql := `CREATE OR REPLACE FUNCTION "public"."total"() RETURNS bigint
+    LANGUAGE "sql"
+    AS $$ SELECT count(*) FROM "public"."totals" $$;
+
+CREATE OR REPLACE VIEW "public"."totals" AS
+ SELECT "public"."total"() AS "count";
+`
+		var out bytes.Buffer
+		// Run test
+		err := writeOrderedSchema(strings.NewReader(sql), &out, RunParams{})
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, sql, out.String())
+	})
+}
@@ -0,0 +1,45 @@
+package squash
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/go-units"
+	"github.com/go-errors/errors"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var ErrTuningMemory = errors.New("tuning exceeds docker memory")
+
+// Converts db.squash.tuning to postgres command args, checking that the total fits in docker memory.
+func getTuningArgs(ctx context.Context) ([]string, error) {
+	tuning := utils.Config.Db.Squash.Tuning
+	settings := []struct {
+		name  string
+		value int64
+	}{
+		{"shared_buffers", int64(tuning.SharedBuffers)},
+		{"work_mem", int64(tuning.WorkMem)},
+		{"maintenance_work_mem", int64(tuning.MaintenanceWorkMem)},
+	}
+	var args []string
+	var total int64
+	for _, s := range settings {
+		if s.value > 0 {
+			// Postgres memory units are in multiples of 1024
+			args = append(args, "-c", fmt.Sprintf("%s=%dkB", s.name, s.value/units.KiB))
+			total += s.value
+		}
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+	info, err := utils.Docker.Info(ctx)
+	if err != nil {
+		return nil, errors.Errorf("failed to inspect docker: %w", err)
+	}
+	if info.MemTotal > 0 && total > info.MemTotal {
+		return nil, errors.Errorf("%w: %s > %s", ErrTuningMemory, units.BytesSize(float64(total)), units.BytesSize(float64(info.MemTotal)))
+	}
+	return args, nil
+}
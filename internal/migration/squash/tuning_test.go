@@ -0,0 +1,85 @@
+package squash
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/go-units"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/testing/apitest"
+	"github.com/supabase/cli/internal/utils"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestTuningArgs(t *testing.T) {
+	t.Run("skips default tuning", func(t *testing.T) {
+		// Run test
+		args, err := getTuningArgs(context.Background())
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, args)
+	})
+
+	t.Run("converts settings to postgres args", func(t *testing.T) {
+		utils.Config.Db.Squash.Tuning.SharedBuffers = 256 * units.MiB
+		utils.Config.Db.Squash.Tuning.MaintenanceWorkMem = 512 * units.MiB
+		defer func() {
+			utils.Config.Db.Squash.Tuning.SharedBuffers = 0
+			utils.Config.Db.Squash.Tuning.MaintenanceWorkMem = 0
+		}()
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/info").
+			Reply(http.StatusOK).
+			JSON(system.Info{MemTotal: 2 * units.GiB})
+		// Run test
+		args, err := getTuningArgs(context.Background())
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"-c", "shared_buffers=262144kB", "-c", "maintenance_work_mem=524288kB"}, args)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("throws error on insufficient memory", func(t *testing.T) {
+		utils.Config.Db.Squash.Tuning.SharedBuffers = 4 * units.GiB
+		defer func() {
+			utils.Config.Db.Squash.Tuning.SharedBuffers = 0
+		}()
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/info").
+			Reply(http.StatusOK).
+			JSON(system.Info{MemTotal: 2 * units.GiB})
+		// Run test
+		_, err := getTuningArgs(context.Background())
+		// Check error
+		assert.ErrorIs(t, err, ErrTuningMemory)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("throws error on docker failure", func(t *testing.T) {
+		utils.Config.Db.Squash.Tuning.WorkMem = 16 * units.MiB
+		defer func() {
+			utils.Config.Db.Squash.Tuning.WorkMem = 0
+		}()
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/info").
+			ReplyError(errors.New("network error"))
+		// Run test
+		_, err := getTuningArgs(context.Background())
+		// Check error
+		assert.ErrorContains(t, err, "network error")
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
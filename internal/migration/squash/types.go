@@ -0,0 +1,76 @@
+package squash
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/gen/types/typescript"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var (
+	ErrCheckTypesPath = errors.New("checking types requires a types file set by --gen-types")
+	ErrTypesDrift     = errors.New("squashed schema has drifted from committed types")
+)
+
+func generateTypes(ctx context.Context, config pgconn.Config) ([]byte, error) {
+	fmt.Fprintln(os.Stderr, "Generating types from shadow database...")
+	var types bytes.Buffer
+	if err := typescript.GenerateFromHost(ctx, config, nil, &types); err != nil {
+		return nil, err
+	}
+	return types.Bytes(), nil
+}
+
+func writeTypes(ctx context.Context, config pgconn.Config, path string, fsys afero.Fs) error {
+	types, err := generateTypes(ctx, config)
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFile(path, types, fsys); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Wrote types to", utils.Bold(path))
+	return nil
+}
+
+// Compares types generated from the squashed schema against the committed file, printing
+// a unified diff of any drift so that CI fails before the squash changes the schema shape.
+func checkTypes(ctx context.Context, config pgconn.Config, path string, fsys afero.Fs) error {
+	committed, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return errors.Errorf("failed to read committed types: %w", err)
+	}
+	generated, err := generateTypes(ctx, config)
+	if err != nil {
+		return err
+	}
+	drift, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitTypes(committed),
+		B:        splitTypes(generated),
+		FromFile: path,
+		ToFile:   "squashed",
+		Context:  3,
+	})
+	if err != nil {
+		return errors.Errorf("failed to diff types: %w", err)
+	}
+	if len(drift) == 0 {
+		fmt.Fprintln(os.Stderr, "Squashed schema matches types in", utils.Bold(path))
+		return nil
+	}
+	fmt.Fprint(os.Stderr, drift)
+	return errors.Errorf("%w: %s", ErrTypesDrift, path)
+}
+
+func splitTypes(types []byte) []string {
+	// Avoids reporting an extra blank line since difflib appends a trailing newline
+	return difflib.SplitLines(strings.TrimSuffix(string(types), "\n"))
+}
@@ -0,0 +1,109 @@
+package squash
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/testing/apitest"
+	"github.com/supabase/cli/internal/utils"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestWriteTypes(t *testing.T) {
+	t.Run("writes types from shadow database", func(t *testing.T) {
+		const containerId = "test-pgmeta"
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.PgmetaImage), containerId)
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, containerId, "export type Json = string\n"))
+		// Run test
+		err := writeTypes(context.Background(), dbConfig, "types/supabase.ts", fsys)
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, "types/supabase.ts")
+		assert.NoError(t, err)
+		assert.Equal(t, "export type Json = string\n", string(contents))
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("throws error on docker failure", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/images/" + utils.GetRegistryImageUrl(utils.PgmetaImage) + "/json").
+			ReplyError(errors.New("network error"))
+		// Run test
+		err := writeTypes(context.Background(), dbConfig, "types/supabase.ts", fsys)
+		// Check error
+		assert.ErrorContains(t, err, "network error")
+		exists, err := afero.Exists(fsys, "types/supabase.ts")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestCheckTypes(t *testing.T) {
+	const containerId = "test-pgmeta"
+
+	t.Run("passes when types match", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fsys, "types/supabase.ts", []byte("export type Json = string\n"), 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.PgmetaImage), containerId)
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, containerId, "export type Json = string\n"))
+		// Run test
+		err := checkTypes(context.Background(), dbConfig, "types/supabase.ts", fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("throws error on drift", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		committed := "export type Json = string\n"
+		require.NoError(t, afero.WriteFile(fsys, "types/supabase.ts", []byte(committed), 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		apitest.MockDockerStart(utils.Docker, utils.GetRegistryImageUrl(utils.PgmetaImage), containerId)
+		require.NoError(t, apitest.MockDockerLogs(utils.Docker, containerId, "export type Json = number\n"))
+		// Run test
+		err := checkTypes(context.Background(), dbConfig, "types/supabase.ts", fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrTypesDrift)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+		contents, err := afero.ReadFile(fsys, "types/supabase.ts")
+		assert.NoError(t, err)
+		assert.Equal(t, committed, string(contents))
+	})
+
+	t.Run("throws error on missing types file", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		// Run test
+		err := checkTypes(context.Background(), dbConfig, "types/supabase.ts", fsys)
+		// Check error
+		assert.ErrorContains(t, err, "failed to read committed types")
+	})
+
+	t.Run("throws error without types path", func(t *testing.T) {
+		// Run test
+		_, err := RunWithResult(context.Background(), "", dbConfig, RunParams{CheckTypes: true}, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, ErrCheckTypesPath)
+	})
+}
@@ -0,0 +1,279 @@
+package squash
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/migration/reorder"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+// Restores migration files from the last git commit after a squash.
+func RunUndo(fsys afero.Fs) error {
+	if err := utils.LoadConfigFS(fsys); err != nil {
+		return err
+	}
+	repo, err := git.PlainOpenWithOptions(".", &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return errors.Errorf("failed to open git repository: %w", err)
+	}
+	merged, err := loadMergedMigrations(utils.Config.Db.Squash.AuditPath, fsys)
+	if err != nil {
+		return err
+	}
+	restored, err := restoreMigrations(repo, utils.MigrationsDir, merged, fsys)
+	if err != nil {
+		return err
+	}
+	if len(restored) == 0 {
+		fmt.Fprintln(os.Stderr, "No migrations restored from git.")
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, "Restored migrations from git:")
+	for _, name := range restored {
+		fmt.Fprintln(os.Stderr, " •", utils.Bold(name))
+	}
+	return nil
+}
+
+// Returns the migrations removed by the latest squash recorded in the audit log, if one is configured.
+func loadMergedMigrations(auditPath string, fsys afero.Fs) ([]string, error) {
+	if len(auditPath) == 0 {
+		return nil, nil
+	}
+	entries, err := readAuditLog(auditPath, fsys)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	last := latestRun(entries)
+	if last < 0 {
+		return nil, nil
+	}
+	var merged []string
+	for _, entry := range entries[:last+1] {
+		if entry.RunId != entries[last].RunId || entry.Restored != nil || len(entry.Error) > 0 || entry.Operation != auditDelete {
+			continue
+		}
+		if name := filepath.Base(entry.Path); utils.MigrateFilePattern.MatchString(name) {
+			merged = append(merged, name)
+		}
+	}
+	return merged, nil
+}
+
+// Returns the names of restored migrations. Committed files with uncommitted edits are kept,
+// except for the squashed baseline, changelog and manifest which are rewritten by squash itself.
+// A baseline renamed by squash is removed with its sidecars, and merged migrations that were
+// never committed are reported as lost.
+func restoreMigrations(repo *git.Repository, dir string, merged []string, fsys afero.Fs) ([]string, error) {
+	prefix, err := reorder.GetRepoPath(repo, dir)
+	if err != nil {
+		return nil, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, errors.Errorf("failed to resolve git HEAD: %w", err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, errors.Errorf("failed to load HEAD commit: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, errors.Errorf("failed to load commit tree: %w", err)
+	}
+	sub, err := tree.Tree(prefix)
+	if errors.Is(err, object.ErrDirectoryNotFound) {
+		return nil, errors.Errorf("no migrations committed to git: %s", prefix)
+	} else if err != nil {
+		return nil, errors.Errorf("failed to load migrations tree: %w", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, errors.Errorf("failed to load git worktree: %w", err)
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return nil, errors.Errorf("failed to load git status: %w", err)
+	}
+	// Paths relative to the project root are committed under the same root as the migrations dir
+	root := strings.TrimSuffix(strings.TrimSuffix(prefix, filepath.ToSlash(utils.MigrationsDir)), "/")
+	written := map[string]bool{}
+	for _, name := range squashWrittenFiles() {
+		written[path.Join(root, filepath.ToSlash(name))] = true
+	}
+	var restored []string
+	committed := map[string]bool{}
+	versions := map[string]bool{}
+	// Versions of baselines written by squash, whose uncommitted sidecars are stale once restored
+	squashed := map[string]bool{}
+	for _, entry := range sub.Entries {
+		if !entry.Mode.IsFile() {
+			continue
+		}
+		committed[entry.Name] = true
+		matches := utils.MigrateFilePattern.FindStringSubmatch(entry.Name)
+		if len(matches) > 1 {
+			versions[matches[1]] = true
+		}
+		repoPath := path.Join(prefix, entry.Name)
+		if file, ok := status[repoPath]; ok && isModified(file) {
+			if len(matches) == 0 {
+				if !written[repoPath] {
+					fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "skipped restoring file with uncommitted changes", utils.Bold(entry.Name))
+					continue
+				}
+			} else if baseline, err := list.IsBaseline(entry.Name, fsys); err != nil {
+				return nil, err
+			} else if !baseline {
+				fmt.Fprintln(os.Stderr, utils.Yellow("WARNING:"), "skipped restoring migration with uncommitted changes", utils.Bold(entry.Name))
+				continue
+			} else {
+				squashed[matches[1]] = true
+			}
+		}
+		if ok, err := restoreFile(sub, entry.Name, filepath.Join(utils.MigrationsDir, entry.Name), fsys); err != nil {
+			return nil, err
+		} else if ok {
+			restored = append(restored, entry.Name)
+		}
+	}
+	// Files written by squash outside the migrations dir are restored from the root tree
+	for _, name := range squashWrittenFiles() {
+		repoPath := path.Join(root, filepath.ToSlash(name))
+		if path.Dir(repoPath) == prefix {
+			continue
+		}
+		if _, err := tree.File(repoPath); errors.Is(err, object.ErrFileNotFound) {
+			continue
+		}
+		if ok, err := restoreFile(tree, repoPath, name, fsys); err != nil {
+			return nil, err
+		} else if ok {
+			restored = append(restored, name)
+		}
+	}
+	// A renamed baseline would otherwise duplicate the version of the restored target
+	local, err := list.LoadLocalMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range local {
+		version := utils.MigrateFilePattern.FindStringSubmatch(name)[1]
+		if committed[name] || !versions[version] {
+			continue
+		}
+		if baseline, err := list.IsBaseline(name, fsys); err != nil {
+			return nil, err
+		} else if !baseline {
+			continue
+		}
+		if err := removeSquashed(filepath.Join(utils.MigrationsDir, name), fsys); err != nil {
+			return nil, err
+		}
+		squashed[version] = true
+	}
+	for version := range squashed {
+		for _, sidecar := range []string{
+			repair.GetCustomDumpPath(version),
+			repair.GetDownPath(version),
+			repair.GetChecksumPath(version),
+			repair.GetSignaturePath(version),
+		} {
+			if committed[filepath.Base(sidecar)] {
+				continue
+			}
+			if err := removeSquashed(sidecar, fsys); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+		}
+	}
+	printLostMigrations(findLostMigrations(prefix, status, committed, merged), os.Stderr)
+	return restored, nil
+}
+
+func removeSquashed(path string, fsys afero.Fs) error {
+	if err := fsys.Remove(path); err != nil {
+		return errors.Errorf("failed to remove squashed file: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Removed", utils.Bold(path))
+	return nil
+}
+
+// Merged migrations are lost when they were never committed, either as recorded in the audit
+// log or as staged files that git reports deleted from the worktree.
+func findLostMigrations(prefix string, status git.Status, committed map[string]bool, merged []string) []string {
+	lost := map[string]bool{}
+	for _, name := range merged {
+		if !committed[name] {
+			lost[name] = true
+		}
+	}
+	for file, s := range status {
+		if name := path.Base(file); path.Dir(file) == prefix && s.Worktree == git.Deleted && !committed[name] {
+			lost[name] = true
+		}
+	}
+	result := make([]string, 0, len(lost))
+	for name := range lost {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func printLostMigrations(lost []string, w io.Writer) {
+	if len(lost) == 0 {
+		return
+	}
+	fmt.Fprintln(w, utils.Yellow("WARNING:"), "uncommitted migrations merged by squash cannot be recovered from git:")
+	for _, name := range lost {
+		fmt.Fprintln(w, " •", utils.Bold(name))
+	}
+}
+
+// Paths relative to the project root of files that squash rewrites besides migrations.
+func squashWrittenFiles() []string {
+	result := []string{utils.MigrationsManifestPath}
+	if changelog := utils.Config.Db.Squash.ChangelogPath; len(changelog) > 0 {
+		result = append(result, changelog)
+	}
+	return result
+}
+
+func isModified(file *git.FileStatus) bool {
+	return file.Worktree == git.Modified || file.Staging == git.Modified
+}
+
+// Returns false if the file already matches the committed contents.
+func restoreFile(tree *object.Tree, name, path string, fsys afero.Fs) (bool, error) {
+	file, err := tree.File(name)
+	if err != nil {
+		return false, errors.Errorf("failed to load committed file: %w", err)
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return false, errors.Errorf("failed to read committed file: %w", err)
+	}
+	if current, err := afero.ReadFile(fsys, path); err == nil && bytes.Equal(current, []byte(contents)) {
+		return false, nil
+	}
+	if err := utils.WriteFile(path, []byte(contents), fsys); err != nil {
+		return false, err
+	}
+	return true, nil
+}
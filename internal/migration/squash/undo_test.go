@@ -0,0 +1,224 @@
+package squash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/migration/repair"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestRestoreMigrations(t *testing.T) {
+	t.Run("restores committed migrations", func(t *testing.T) {
+		// Setup git repo
+		root := t.TempDir()
+		repo, err := git.PlainInit(root, false)
+		require.NoError(t, err)
+		wt, err := repo.Worktree()
+		require.NoError(t, err)
+		dir := filepath.Join(root, utils.MigrationsDir)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		for _, name := range []string{"0_init.sql", "1_target.sql", "2_edit.sql"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+			_, err = wt.Add(filepath.ToSlash(filepath.Join(utils.MigrationsDir, name)))
+			require.NoError(t, err)
+		}
+		_, err = wt.Commit("add migrations", &git.CommitOptions{
+			Author: &object.Signature{Name: "test", When: time.Now()},
+		})
+		require.NoError(t, err)
+		// Setup squashed worktree
+		fsys := afero.NewBasePathFs(afero.NewOsFs(), root)
+		require.NoError(t, fsys.Remove(filepath.Join(utils.MigrationsDir, "0_init.sql")))
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "1_target.sql"), []byte(list.BaselineMarker+"\nsquashed"), 0644))
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "2_edit.sql"), []byte("edited"), 0644))
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "3_local.sql"), []byte{}, 0644))
+		// Run test
+		restored, err := restoreMigrations(repo, dir, nil, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"0_init.sql", "1_target.sql"}, restored)
+		for _, name := range restored {
+			contents, err := afero.ReadFile(fsys, filepath.Join(utils.MigrationsDir, name))
+			assert.NoError(t, err)
+			assert.Equal(t, name, string(contents))
+		}
+		contents, err := afero.ReadFile(fsys, filepath.Join(utils.MigrationsDir, "2_edit.sql"))
+		assert.NoError(t, err)
+		assert.Equal(t, "edited", string(contents))
+		exists, err := afero.Exists(fsys, filepath.Join(utils.MigrationsDir, "3_local.sql"))
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("removes renamed baseline and its sidecars", func(t *testing.T) {
+		// Setup git repo
+		root := t.TempDir()
+		repo, err := git.PlainInit(root, false)
+		require.NoError(t, err)
+		wt, err := repo.Worktree()
+		require.NoError(t, err)
+		dir := filepath.Join(root, utils.MigrationsDir)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		for _, name := range []string{"0_init.sql", "1_target.sql"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+			_, err = wt.Add(filepath.ToSlash(filepath.Join(utils.MigrationsDir, name)))
+			require.NoError(t, err)
+		}
+		_, err = wt.Commit("add migrations", &git.CommitOptions{
+			Author: &object.Signature{Name: "test", When: time.Now()},
+		})
+		require.NoError(t, err)
+		// Setup squashed worktree
+		fsys := afero.NewBasePathFs(afero.NewOsFs(), root)
+		require.NoError(t, fsys.Remove(filepath.Join(utils.MigrationsDir, "0_init.sql")))
+		require.NoError(t, fsys.Rename(filepath.Join(utils.MigrationsDir, "1_target.sql"), filepath.Join(utils.MigrationsDir, "1_baseline.sql")))
+		require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, "1_baseline.sql"), []byte(list.BaselineMarker+"\nsquashed"), 0644))
+		require.NoError(t, afero.WriteFile(fsys, repair.GetChecksumPath("1"), []byte("checksum"), 0644))
+		// Run test
+		restored, err := restoreMigrations(repo, dir, []string{"0_init.sql", "0_local.sql"}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"0_init.sql", "1_target.sql"}, restored)
+		for _, path := range []string{filepath.Join(utils.MigrationsDir, "1_baseline.sql"), repair.GetChecksumPath("1")} {
+			exists, err := afero.Exists(fsys, path)
+			assert.NoError(t, err)
+			assert.False(t, exists)
+		}
+	})
+
+	t.Run("restores files rewritten by squash", func(t *testing.T) {
+		utils.Config.Db.Squash.ChangelogPath = filepath.Join(utils.MigrationsDir, "CHANGELOG.md")
+		defer func() { utils.Config.Db.Squash.ChangelogPath = "" }()
+		// Setup git repo
+		root := t.TempDir()
+		repo, err := git.PlainInit(root, false)
+		require.NoError(t, err)
+		wt, err := repo.Worktree()
+		require.NoError(t, err)
+		dir := filepath.Join(root, utils.MigrationsDir)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		files := []string{
+			filepath.Join(utils.MigrationsDir, "0_init.sql"),
+			utils.Config.Db.Squash.ChangelogPath,
+			utils.MigrationAssertionsPath,
+			utils.MigrationsManifestPath,
+		}
+		for _, name := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte("committed"), 0644))
+			_, err = wt.Add(filepath.ToSlash(name))
+			require.NoError(t, err)
+		}
+		_, err = wt.Commit("add migrations", &git.CommitOptions{
+			Author: &object.Signature{Name: "test", When: time.Now()},
+		})
+		require.NoError(t, err)
+		// Setup squashed worktree
+		fsys := afero.NewBasePathFs(afero.NewOsFs(), root)
+		for _, name := range files[1:] {
+			require.NoError(t, afero.WriteFile(fsys, name, []byte("edited"), 0644))
+		}
+		// Run test
+		restored, err := restoreMigrations(repo, dir, nil, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"CHANGELOG.md", utils.MigrationsManifestPath}, restored)
+		for _, name := range []string{utils.Config.Db.Squash.ChangelogPath, utils.MigrationsManifestPath} {
+			contents, err := afero.ReadFile(fsys, name)
+			assert.NoError(t, err)
+			assert.Equal(t, "committed", string(contents))
+		}
+		contents, err := afero.ReadFile(fsys, utils.MigrationAssertionsPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "edited", string(contents))
+	})
+
+	t.Run("throws error on missing migrations", func(t *testing.T) {
+		// Setup git repo
+		root := t.TempDir()
+		repo, err := git.PlainInit(root, false)
+		require.NoError(t, err)
+		wt, err := repo.Worktree()
+		require.NoError(t, err)
+		_, err = wt.Commit("empty", &git.CommitOptions{
+			AllowEmptyCommits: true,
+			Author:            &object.Signature{Name: "test", When: time.Now()},
+		})
+		require.NoError(t, err)
+		// Run test
+		_, err = restoreMigrations(repo, filepath.Join(root, utils.MigrationsDir), nil, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorContains(t, err, "no migrations committed to git")
+	})
+
+	t.Run("throws error on empty repo", func(t *testing.T) {
+		root := t.TempDir()
+		repo, err := git.PlainInit(root, false)
+		require.NoError(t, err)
+		// Run test
+		_, err = restoreMigrations(repo, filepath.Join(root, utils.MigrationsDir), nil, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorContains(t, err, "failed to resolve git HEAD")
+	})
+}
+
+func TestFindLostMigrations(t *testing.T) {
+	t.Run("reports uncommitted merges", func(t *testing.T) {
+		status := git.Status{
+			"supabase/migrations/0_staged.sql":   &git.FileStatus{Staging: git.Added, Worktree: git.Deleted},
+			"supabase/migrations/1_init.sql":     &git.FileStatus{Staging: git.Unmodified, Worktree: git.Deleted},
+			"supabase/migrations/3_baseline.sql": &git.FileStatus{Staging: git.Untracked, Worktree: git.Untracked},
+		}
+		committed := map[string]bool{"1_init.sql": true}
+		// Run test
+		lost := findLostMigrations("supabase/migrations", status, committed, []string{"1_init.sql", "2_local.sql"})
+		// Check output
+		assert.Equal(t, []string{"0_staged.sql", "2_local.sql"}, lost)
+	})
+
+	t.Run("ignores committed merges", func(t *testing.T) {
+		status := git.Status{
+			"supabase/migrations/1_init.sql": &git.FileStatus{Staging: git.Unmodified, Worktree: git.Deleted},
+		}
+		// Run test
+		lost := findLostMigrations("supabase/migrations", status, map[string]bool{"1_init.sql": true}, nil)
+		// Check output
+		assert.Empty(t, lost)
+	})
+}
+
+func TestLoadMergedMigrations(t *testing.T) {
+	t.Run("loads deletes of latest run", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		restored := time.Now()
+		entries := []auditEntry{
+			{RunId: "a", Operation: auditDelete, Path: filepath.Join(utils.MigrationsDir, "0_old.sql"), Restored: &restored},
+			{RunId: "b", Operation: auditDelete, Path: filepath.Join(utils.MigrationsDir, "1_init.sql")},
+			{RunId: "b", Operation: auditDelete, Path: repair.GetChecksumPath("1")},
+			{RunId: "b", Operation: auditOverwrite, Path: filepath.Join(utils.MigrationsDir, "2_target.sql")},
+		}
+		require.NoError(t, writeAuditLog("audit.jsonl", entries, fsys))
+		// Run test
+		merged, err := loadMergedMigrations("audit.jsonl", fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1_init.sql"}, merged)
+	})
+
+	t.Run("ignores missing audit log", func(t *testing.T) {
+		// Run test
+		merged, err := loadMergedMigrations("audit.jsonl", afero.NewMemMapFs())
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, merged)
+	})
+}
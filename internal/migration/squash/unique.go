@@ -0,0 +1,38 @@
+package squash
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var ErrDuplicateVersion = errors.New("duplicate migration version")
+
+// Files sharing a version would be inserted twice by the baseline batch, failing on the
+// primary key only after the merged files are removed.
+func assertUniqueLocalVersions(fsys afero.Fs) error {
+	migrations, err := list.LoadLocalMigrations(fsys)
+	if err != nil {
+		return err
+	}
+	files := map[string][]string{}
+	for _, name := range migrations {
+		version := utils.MigrateFilePattern.FindStringSubmatch(name)[1]
+		files[version] = append(files[version], name)
+	}
+	var conflicts []string
+	for _, names := range files {
+		if len(names) > 1 {
+			conflicts = append(conflicts, strings.Join(names, ", "))
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(conflicts)
+	return errors.Errorf("%w:\n  %s", ErrDuplicateVersion, strings.Join(conflicts, "\n  "))
+}
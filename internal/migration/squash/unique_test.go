@@ -0,0 +1,55 @@
+package squash
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestUniqueLocalVersions(t *testing.T) {
+	t.Run("accepts distinct versions", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"0_init.sql", "1_target.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte{}, 0644))
+		}
+		// Run test
+		err := assertUniqueLocalVersions(fsys)
+		// Check error
+		assert.NoError(t, err)
+	})
+
+	t.Run("throws error on shared versions", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"0_init.sql", "1_a.sql", "1_b.sql", "2_target.sql", "2_other.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte{}, 0644))
+		}
+		// Run test
+		err := assertUniqueLocalVersions(fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrDuplicateVersion)
+		assert.ErrorContains(t, err, "\n  1_a.sql, 1_b.sql\n  2_other.sql, 2_target.sql")
+	})
+
+	t.Run("throws error before squashing", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		for _, name := range []string{"0_init.sql", "0_copy.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte{}, 0644))
+		}
+		// Run test
+		err := Run(context.Background(), "", dbConfig, RunParams{}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrDuplicateVersion)
+		exists, err := afero.Exists(fsys, filepath.Join(utils.MigrationsDir, "0_copy.sql"))
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+}
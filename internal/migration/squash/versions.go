@@ -0,0 +1,127 @@
+package squash
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/db/diff"
+	"github.com/supabase/cli/internal/db/start"
+	"github.com/supabase/cli/internal/migration/apply"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported postgres major version")
+	ErrVersionFailed      = errors.New("squashed migration failed to apply")
+)
+
+var versionImages = map[uint]string{
+	13: utils.Pg13Image,
+	14: utils.Pg14Image,
+	15: utils.Pg15Image,
+}
+
+func assertSupportedVersions(versions []uint) error {
+	for _, v := range versions {
+		if _, ok := versionImages[v]; !ok {
+			return errors.Errorf("%w: %d", ErrUnsupportedVersion, v)
+		}
+	}
+	return nil
+}
+
+// Applies the squashed migration to a fresh shadow database of each major version, running up to
+// parallel databases at once, and reports the outcome of every version after all have finished.
+func verifyVersions(ctx context.Context, name string, versions []uint, parallel uint, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	if parallel == 0 {
+		parallel = 1
+	}
+	results := make([]error, len(versions))
+	jq := utils.NewJobQueue(parallel)
+	for i, v := range versions {
+		fmt.Fprintf(os.Stderr, "Verifying squashed migration on Postgres %d...\n", v)
+		// Pulled one at a time because failed pulls set the global command suggestion
+		if results[i] = utils.DockerPullImageIfNotCached(ctx, versionImages[v]); results[i] != nil {
+			continue
+		}
+		// Jobs never fail so that every version is verified
+		_ = jq.Put(func() error {
+			results[i] = applyOnVersion(ctx, v, name, parallel > 1, fsys, options...)
+			return nil
+		})
+	}
+	_ = jq.Collect()
+	var failed []error
+	for i, v := range versions {
+		if err := results[i]; err != nil {
+			fmt.Fprintln(os.Stderr, utils.Red("FAILED:"), fmt.Sprintf("Postgres %d:", v), err)
+			failed = append(failed, errors.Errorf("postgres %d: %w", v, err))
+			continue
+		}
+		fmt.Fprintln(os.Stderr, utils.Aqua("PASSED:"), fmt.Sprintf("Postgres %d", v))
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("%w on %d of %d versions:\n%w", ErrVersionFailed, len(failed), len(versions), errors.Join(failed...))
+	}
+	return nil
+}
+
+// Concurrent shadow databases bind to ports picked by the OS, since the configured shadow
+// port can only be bound by one of them at a time.
+func applyOnVersion(ctx context.Context, version uint, name string, concurrent bool, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	shadow := diff.NewShadowOptions()
+	shadow.MajorVersion, shadow.Image = version, versionImages[version]
+	if concurrent {
+		port, err := allocatePort()
+		if err != nil {
+			return err
+		}
+		shadow.Port = port
+		if len(shadow.Name) > 0 {
+			shadow.Name = fmt.Sprintf("%s-pg%d", shadow.Name, version)
+		}
+	}
+	return applyOnShadow(ctx, shadow, []string{name}, nil, fsys, options...)
+}
+
+func allocatePort() (uint16, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, errors.Errorf("failed to allocate shadow port: %w", err)
+	}
+	defer l.Close()
+	return uint16(l.Addr().(*net.TCPAddr).Port), nil
+}
+
+// Applies migrations to a fresh shadow database, calling inspect before it is removed.
+func applyOnShadow(ctx context.Context, opts diff.ShadowOptions, migrations []string, inspect func(context.Context, *pgx.Conn) error, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	shadow, err := diff.CreateShadowContainer(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer utils.DockerRemove(shadow)
+	if !start.WaitForHealthyService(ctx, shadow, start.HealthTimeout) {
+		return errors.New(start.ErrDatabase)
+	}
+	conn, err := diff.ConnectShadowPort(ctx, opts.Port, 10*time.Second, options...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if err := start.SetupShadowDatabaseFor(ctx, conn, shadow[:12], opts.MajorVersion, os.Stderr, fsys); err != nil {
+		return err
+	}
+	if err := apply.MigrateUp(ctx, conn, migrations, fsys); err != nil {
+		return err
+	}
+	if inspect != nil {
+		return inspect(ctx, conn)
+	}
+	return nil
+}
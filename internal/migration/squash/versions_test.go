@@ -0,0 +1,92 @@
+package squash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/testing/apitest"
+	"github.com/supabase/cli/internal/utils"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestVerifyVersions(t *testing.T) {
+	t.Run("throws error on unsupported version", func(t *testing.T) {
+		err := assertSupportedVersions([]uint{13, 12})
+		// Check error
+		assert.ErrorIs(t, err, ErrUnsupportedVersion)
+		assert.ErrorContains(t, err, "12")
+	})
+
+	t.Run("reports failure of every version", func(t *testing.T) {
+		image := utils.Config.Db.Image
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		for _, v := range []uint{13, 14} {
+			gock.New(utils.Docker.DaemonHost()).
+				Get("/v" + utils.Docker.ClientVersion() + "/images/" + utils.GetRegistryImageUrl(versionImages[v]) + "/json").
+				ReplyError(errors.New("network error"))
+		}
+		// Run test
+		err := verifyVersions(context.Background(), "1_target.sql", []uint{13, 14}, 1, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, ErrVersionFailed)
+		assert.ErrorContains(t, err, "on 2 of 2 versions")
+		assert.ErrorContains(t, err, "postgres 13: ")
+		assert.ErrorContains(t, err, "postgres 14: ")
+		assert.Equal(t, image, utils.Config.Db.Image)
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+
+	t.Run("reports versions in order when parallel", func(t *testing.T) {
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		for _, v := range []uint{13, 14, 15} {
+			// Inspected once when pulling and again when starting the shadow database
+			gock.New(utils.Docker.DaemonHost()).
+				Get("/v" + utils.Docker.ClientVersion() + "/images/" + utils.GetRegistryImageUrl(versionImages[v]) + "/json").
+				Times(2).
+				Reply(http.StatusOK).
+				JSON(types.ImageInspect{})
+		}
+		gock.New(utils.Docker.DaemonHost()).
+			Post("/v" + utils.Docker.ClientVersion() + "/networks/create").
+			Times(3).
+			ReplyError(errors.New("network error"))
+		// Run test
+		err := verifyVersions(context.Background(), "1_target.sql", []uint{13, 14, 15}, 2, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, ErrVersionFailed)
+		assert.ErrorContains(t, err, "on 3 of 3 versions")
+		msg := err.Error()
+		assert.Less(t, strings.Index(msg, "postgres 13: "), strings.Index(msg, "postgres 14: "))
+		assert.Less(t, strings.Index(msg, "postgres 14: "), strings.Index(msg, "postgres 15: "))
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
+
+func TestAllocatePort(t *testing.T) {
+	t.Run("allocates distinct free ports", func(t *testing.T) {
+		first, err := allocatePort()
+		require.NoError(t, err)
+		// Hold the first port so the next allocation cannot reuse it
+		l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", first))
+		require.NoError(t, err)
+		defer l.Close()
+		second, err := allocatePort()
+		// Check error
+		assert.NoError(t, err)
+		assert.NotZero(t, first)
+		assert.NotEqual(t, first, second)
+	})
+}
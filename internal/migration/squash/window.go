@@ -0,0 +1,40 @@
+package squash
+
+import (
+	"fmt"
+
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/utils"
+)
+
+// Loads the migrations to merge, reporting whether they are a window on top of
+// earlier migrations that are kept, either from the since version, the base branch or the latest baseline.
+func loadSquashWindow(version string, params RunParams, fsys afero.Fs) ([]string, bool, error) {
+	if len(params.Since) > 0 {
+		_, migrations, err := splitSince(version, params.Since, fsys)
+		return migrations, true, err
+	}
+	if len(params.Base) > 0 {
+		migrations, err := loadBranchMigrations(version, params.Base, fsys)
+		return migrations, true, err
+	}
+	migrations, err := list.LoadOrderedMigrations(version, fsys)
+	if err != nil {
+		return nil, false, err
+	}
+	// Custom format and cached dumps cannot exclude the objects of earlier migrations
+	if !params.Textual && (params.Format == FormatCustom || params.Cache) {
+		return migrations, false, nil
+	}
+	// The target itself may be a baseline, in which case everything before it is merged
+	for i := len(migrations) - 2; i >= 0; i-- {
+		if baseline, err := list.IsBaseline(migrations[i], fsys); err != nil {
+			return nil, false, err
+		} else if baseline {
+			fmt.Fprintln(utils.GetDebugLogger(), "Found squashed baseline", migrations[i])
+			return migrations[i+1:], true, nil
+		}
+	}
+	return migrations, false, nil
+}
@@ -0,0 +1,94 @@
+package squash
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/utils"
+)
+
+func TestSquashWindow(t *testing.T) {
+	t.Run("starts after latest baseline", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		files := map[string]string{
+			"0_squashed_baseline.sql": "create schema a;",
+			"1_init.sql":              "create schema b;",
+			"2_second.sql":            list.BaselineMarker + "\ncreate schema c;",
+			"3_third.sql":             "create schema d;",
+			"4_fourth.sql":            "create schema e;",
+		}
+		for name, sql := range files {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte(sql), 0644))
+		}
+		// Run test
+		migrations, partial, err := loadSquashWindow("", RunParams{}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.True(t, partial)
+		assert.Equal(t, []string{"3_third.sql", "4_fourth.sql"}, migrations)
+	})
+
+	t.Run("merges all without baseline", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"0_init.sql", "1_target.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte("select 1;"), 0644))
+		}
+		// Run test
+		migrations, partial, err := loadSquashWindow("", RunParams{}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.False(t, partial)
+		assert.Equal(t, []string{"0_init.sql", "1_target.sql"}, migrations)
+	})
+
+	t.Run("squashes window into second baseline", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		files := map[string]string{
+			"0_init.sql":   list.BaselineMarker + "\ncreate schema a;",
+			"1_second.sql": "create schema b;",
+			"2_third.sql":  "create schema c;",
+		}
+		for name, sql := range files {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte(sql), 0644))
+		}
+		// Run test
+		_, err := squashToVersion(context.Background(), "", RunParams{}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		contents, err := afero.ReadFile(fsys, filepath.Join(utils.MigrationsDir, "0_init.sql"))
+		assert.NoError(t, err)
+		assert.Equal(t, files["0_init.sql"], string(contents))
+		contents, err = afero.ReadFile(fsys, filepath.Join(utils.MigrationsDir, "2_third.sql"))
+		assert.NoError(t, err)
+		assert.Equal(t, list.BaselineMarker+"\ncreate schema b;\ncreate schema c;\n", string(contents))
+		exists, err := afero.Exists(fsys, filepath.Join(utils.MigrationsDir, "1_second.sql"))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("merges all for custom format", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		files := map[string]string{
+			"0_init.sql":   list.BaselineMarker + "\ncreate schema a;",
+			"1_second.sql": "create schema b;",
+		}
+		for name, sql := range files {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte(sql), 0644))
+		}
+		// Run test
+		migrations, partial, err := loadSquashWindow("", RunParams{Format: FormatCustom}, fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.False(t, partial)
+		assert.Equal(t, []string{"0_init.sql", "1_second.sql"}, migrations)
+	})
+}
@@ -0,0 +1,146 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/afero"
+	"github.com/supabase/cli/internal/db/diff"
+	"github.com/supabase/cli/internal/db/dump"
+	"github.com/supabase/cli/internal/db/start"
+	"github.com/supabase/cli/internal/migration/apply"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/utils"
+)
+
+var (
+	ErrMissingBaseline = errors.New("no local migrations found")
+	ErrSchemaDrift     = errors.New("remote schema has drifted from baseline migration")
+)
+
+// Verifies the baseline of the given version, or the latest squashed baseline when version is empty.
+func Run(ctx context.Context, version string, config pgconn.Config, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	if err := utils.LoadConfigFS(fsys); err != nil {
+		return err
+	}
+	migrations, err := list.LoadOrderedMigrations("", fsys)
+	if err != nil {
+		return err
+	}
+	if migrations, err = findBaseline(migrations, version, fsys); err != nil {
+		return err
+	}
+	baseline := migrations[len(migrations)-1]
+	var expected, actual bytes.Buffer
+	if err := dumpBaseline(ctx, migrations, &expected, fsys, options...); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Dumping schema from remote database...")
+	// Remote database may be altered while dumping
+	if err := dump.DumpSchemaWithRetry(ctx, config, nil, false, &actual, dump.WithConsistentSnapshot); err != nil {
+		return err
+	}
+	drift, err := diffSchema(baseline, &expected, &actual)
+	if err != nil {
+		return err
+	}
+	if len(drift) == 0 {
+		fmt.Fprintln(os.Stderr, "Remote schema matches baseline migration", utils.Bold(baseline))
+		return nil
+	}
+	fmt.Print(drift)
+	return errors.New(ErrSchemaDrift)
+}
+
+// Returns the migrations up to and including the baseline. Migrations kept before a windowed
+// baseline are included since the baseline cannot be applied without them. Without a squashed
+// baseline, the first migration is assumed to be one written by an earlier version of the CLI.
+func findBaseline(migrations []string, version string, fsys afero.Fs) ([]string, error) {
+	if len(migrations) == 0 {
+		return nil, errors.New(ErrMissingBaseline)
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if len(version) > 0 {
+			if matches := utils.MigrateFilePattern.FindStringSubmatch(migrations[i]); len(matches) > 1 && matches[1] == version {
+				return migrations[:i+1], nil
+			}
+		} else if baseline, err := list.IsBaseline(migrations[i], fsys); err != nil {
+			return nil, err
+		} else if baseline {
+			return migrations[:i+1], nil
+		}
+	}
+	if len(version) > 0 {
+		return nil, errors.Errorf("failed to find baseline migration: %s", version)
+	}
+	return migrations[:1], nil
+}
+
+// Applies migrations up to the baseline to a shadow database and dumps the resulting schema.
+func dumpBaseline(ctx context.Context, migrations []string, w io.Writer, fsys afero.Fs, options ...func(*pgx.ConnConfig)) error {
+	fmt.Fprintln(os.Stderr, "Creating shadow database...")
+	shadow, err := diff.CreateShadowDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	defer utils.DockerRemove(shadow)
+	if !start.WaitForHealthyService(ctx, shadow, start.HealthTimeout) {
+		return errors.New(start.ErrDatabase)
+	}
+	conn, err := diff.ConnectShadowDatabase(ctx, 10*time.Second, options...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if err := start.SetupShadowDatabase(ctx, conn, shadow[:12], os.Stderr, fsys); err != nil {
+		return err
+	}
+	config := pgconn.Config{
+		Host:     utils.Config.Hostname,
+		Port:     uint16(utils.Config.Db.ShadowPort),
+		User:     "postgres",
+		Password: utils.Config.Db.Password,
+		Database: utils.Config.Db.Name,
+	}
+	if err := diff.EnsureDatabase(ctx, conn, config.Database); err != nil {
+		return err
+	}
+	if config.Database != conn.Config().Database {
+		if conn, err = utils.ConnectLocalPostgres(ctx, config, options...); err != nil {
+			return err
+		}
+		defer conn.Close(context.Background())
+	}
+	if err := apply.MigrateUp(ctx, conn, migrations, fsys); err != nil {
+		return err
+	}
+	return dump.DumpSchema(ctx, config, nil, false, false, w)
+}
+
+func diffSchema(baseline string, expected, actual *bytes.Buffer) (string, error) {
+	result, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(expected.String()),
+		B:        splitLines(actual.String()),
+		FromFile: baseline,
+		ToFile:   "remote",
+		Context:  3,
+	})
+	if err != nil {
+		return "", errors.Errorf("failed to diff schema: %w", err)
+	}
+	return result, nil
+}
+
+func splitLines(schema string) []string {
+	// Avoids reporting an extra blank line since difflib appends a trailing newline
+	return difflib.SplitLines(strings.TrimSuffix(schema, "\n"))
+}
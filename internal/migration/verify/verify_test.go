@@ -0,0 +1,132 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/cli/internal/migration/list"
+	"github.com/supabase/cli/internal/testing/apitest"
+	"github.com/supabase/cli/internal/utils"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestVerifyCommand(t *testing.T) {
+	t.Run("throws error on missing config", func(t *testing.T) {
+		// Run test
+		err := Run(context.Background(), "", pgconn.Config{}, afero.NewMemMapFs())
+		// Check error
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("throws error on missing baseline", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		// Run test
+		err := Run(context.Background(), "", pgconn.Config{}, fsys)
+		// Check error
+		assert.ErrorIs(t, err, ErrMissingBaseline)
+	})
+
+	t.Run("throws error on shadow create failure", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		require.NoError(t, utils.WriteConfig(fsys, false))
+		path := filepath.Join(utils.MigrationsDir, "0_init.sql")
+		require.NoError(t, afero.WriteFile(fsys, path, []byte("create schema test"), 0644))
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(utils.Docker))
+		defer gock.OffAll()
+		gock.New(utils.Docker.DaemonHost()).
+			Get("/v" + utils.Docker.ClientVersion() + "/images/" + utils.GetRegistryImageUrl(utils.Config.Db.Image) + "/json").
+			ReplyError(errors.New("network error"))
+		// Run test
+		err := Run(context.Background(), "", pgconn.Config{}, fsys)
+		// Check error
+		assert.ErrorContains(t, err, "network error")
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
+
+func TestFindBaseline(t *testing.T) {
+	t.Run("finds latest squashed baseline", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		files := map[string]string{
+			"0_init.sql":   "create schema a;",
+			"1_squash.sql": list.BaselineMarker + "\ncreate schema b;",
+			"2_next.sql":   "create schema c;",
+		}
+		for name, sql := range files {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte(sql), 0644))
+		}
+		// Run test
+		migrations, err := findBaseline([]string{"0_init.sql", "1_squash.sql", "2_next.sql"}, "", fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"0_init.sql", "1_squash.sql"}, migrations)
+	})
+
+	t.Run("finds squashed version", func(t *testing.T) {
+		// Run test
+		migrations, err := findBaseline([]string{"0_init.sql", "1_squash.sql", "2_next.sql"}, "2", afero.NewMemMapFs())
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"0_init.sql", "1_squash.sql", "2_next.sql"}, migrations)
+	})
+
+	t.Run("defaults to first migration", func(t *testing.T) {
+		// Setup in-memory fs
+		fsys := afero.NewMemMapFs()
+		for _, name := range []string{"0_init.sql", "1_next.sql"} {
+			require.NoError(t, afero.WriteFile(fsys, filepath.Join(utils.MigrationsDir, name), []byte{}, 0644))
+		}
+		// Run test
+		migrations, err := findBaseline([]string{"0_init.sql", "1_next.sql"}, "", fsys)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"0_init.sql"}, migrations)
+	})
+
+	t.Run("throws error on missing version", func(t *testing.T) {
+		// Run test
+		_, err := findBaseline([]string{"0_init.sql"}, "1", afero.NewMemMapFs())
+		// Check error
+		assert.ErrorContains(t, err, "failed to find baseline migration: 1")
+	})
+}
+
+func TestDiffSchema(t *testing.T) {
+	t.Run("reports drift from baseline", func(t *testing.T) {
+		expected := bytes.NewBufferString("create table a();\ncreate table b();\n")
+		actual := bytes.NewBufferString("create table a();\ncreate table c();\n")
+		// Run test
+		drift, err := diffSchema("0_init.sql", expected, actual)
+		// Check error
+		assert.NoError(t, err)
+		assert.Equal(t, `--- 0_init.sql
++++ remote
+@@ -1,2 +1,2 @@
+ create table a();
+-create table b();
++create table c();
+`, drift)
+	})
+
+	t.Run("returns empty on matching schema", func(t *testing.T) {
+		schema := "create table a();\n"
+		// Run test
+		drift, err := diffSchema("0_init.sql", bytes.NewBufferString(schema), bytes.NewBufferString(schema))
+		// Check error
+		assert.NoError(t, err)
+		assert.Empty(t, drift)
+	})
+}
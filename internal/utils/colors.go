@@ -1,9 +1,21 @@
 package utils
 
 import (
+	"os"
+
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"golang.org/x/term"
 )
 
+// Renders plain text when forced, when NO_COLOR is set, or when stderr is redirected since most
+// styled messages are printed to stderr.
+func SetColorProfile(noColor bool) {
+	if noColor || len(os.Getenv("NO_COLOR")) > 0 || !term.IsTerminal(int(os.Stderr.Fd())) {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
 // For commands & names.
 func Aqua(str string) string {
 	return lipgloss.NewStyle().Foreground(lipgloss.Color("14")).Render(str)
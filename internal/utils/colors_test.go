@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetColorProfile(t *testing.T) {
+	t.Run("renders plain text without color", func(t *testing.T) {
+		defer lipgloss.SetColorProfile(lipgloss.ColorProfile())
+		lipgloss.SetColorProfile(termenv.ANSI256)
+		// Run test
+		SetColorProfile(true)
+		// Check output
+		assert.Equal(t, "supabase", Bold("supabase"))
+		assert.Equal(t, "supabase", Aqua("supabase"))
+	})
+
+	t.Run("respects NO_COLOR env", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+		defer lipgloss.SetColorProfile(lipgloss.ColorProfile())
+		lipgloss.SetColorProfile(termenv.ANSI256)
+		// Run test
+		SetColorProfile(false)
+		// Check output
+		assert.Equal(t, termenv.Ascii, lipgloss.ColorProfile())
+	})
+}
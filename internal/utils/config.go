@@ -121,6 +121,23 @@ const (
 	SessionMode     PoolMode = "session"
 )
 
+type DiffEngine string
+
+const (
+	DiffMigra    DiffEngine = "migra"
+	DiffPgSchema DiffEngine = "pg-schema"
+	DiffDump     DiffEngine = "dump"
+)
+
+// Managed schemas diffed by migration squash, in the order their changes are appended.
+var DefaultManagedSchemas = []string{"auth", "storage"}
+
+// Default privileges granted by the platform which differ between environments.
+var DefaultExcludedGrants = []string{
+	`^(GRANT|REVOKE) .+ ON (SCHEMA|ALL TABLES IN SCHEMA|ALL SEQUENCES IN SCHEMA|ALL FUNCTIONS IN SCHEMA) "(auth|storage)" (TO|FROM) "(anon|authenticated|service_role|postgres|PUBLIC)"`,
+	`^ALTER DEFAULT PRIVILEGES FOR ROLE "(postgres|supabase_admin|supabase_auth_admin|supabase_storage_admin)" IN SCHEMA "(auth|storage)" (GRANT|REVOKE) `,
+}
+
 type AddressFamily string
 
 const (
@@ -274,13 +291,46 @@ type (
 	}
 
 	db struct {
-		Image        string `toml:"-"`
-		Port         uint   `toml:"port"`
-		ShadowPort   uint   `toml:"shadow_port"`
-		MajorVersion uint   `toml:"major_version"`
-		Password     string `toml:"-"`
-		RootKey      string `toml:"-" mapstructure:"root_key"`
-		Pooler       pooler `toml:"pooler"`
+		Image        string     `toml:"-"`
+		Port         uint       `toml:"port"`
+		ShadowPort   uint       `toml:"shadow_port"`
+		MajorVersion uint       `toml:"major_version"`
+		Name         string     `toml:"name"`
+		Password     string     `toml:"-"`
+		RootKey      string     `toml:"-" mapstructure:"root_key"`
+		Pooler       pooler     `toml:"pooler"`
+		Diff         schemaDiff `toml:"diff"`
+		Shadow       shadow     `toml:"shadow"`
+		Squash       squash     `toml:"squash"`
+	}
+
+	squash struct {
+		ExcludeGrants   []string    `toml:"exclude_grants"`
+		MaxFileSize     sizeInBytes `toml:"max_file_size"`
+		MaskSecrets     []string    `toml:"mask_secrets"`
+		ChangelogPath   string      `toml:"changelog_path"`
+		AuditPath       string      `toml:"audit_path"`
+		ManagedSchemas  []string    `toml:"managed_schemas"`
+		SortManagedDiff bool        `toml:"sort_managed_diff"`
+		Tuning          tuning      `toml:"tuning"`
+	}
+
+	tuning struct {
+		SharedBuffers      sizeInBytes `toml:"shared_buffers"`
+		WorkMem            sizeInBytes `toml:"work_mem"`
+		MaintenanceWorkMem sizeInBytes `toml:"maintenance_work_mem"`
+	}
+
+	shadow struct {
+		Encoding      string   `toml:"encoding"`
+		LcCollate     string   `toml:"lc_collate"`
+		LcCtype       string   `toml:"lc_ctype"`
+		ContainerName string   `toml:"container_name"`
+		SearchPath    []string `toml:"search_path"`
+	}
+
+	schemaDiff struct {
+		Engine DiffEngine `toml:"engine"`
 	}
 
 	pooler struct {
@@ -572,6 +622,49 @@ func LoadConfigFS(fsys afero.Fs) error {
 				return errors.Errorf("Invalid config for db.pooler.pool_mode. Must be one of: %v", allowed)
 			}
 		}
+		if len(Config.Db.Name) == 0 {
+			Config.Db.Name = "postgres"
+		}
+		// Validate diff config
+		// An empty engine keeps the default of each command
+		if allowed := []DiffEngine{DiffMigra, DiffPgSchema, DiffDump}; len(Config.Db.Diff.Engine) > 0 && !SliceContains(allowed, Config.Db.Diff.Engine) {
+			return errors.Errorf("Invalid config for db.diff.engine. Must be one of: %v", allowed)
+		}
+		if name := Config.Db.Shadow.ContainerName; len(name) > 0 && !ContainerNamePattern.MatchString(name) {
+			return errors.Errorf("Invalid config for db.shadow.container_name. Must match: %s", ContainerNamePattern)
+		}
+		for _, schema := range Config.Db.Shadow.SearchPath {
+			if len(strings.TrimSpace(schema)) == 0 {
+				return errors.New("Invalid config for db.shadow.search_path. Schema names must not be empty.")
+			}
+		}
+		// Validate squash config
+		if Config.Db.Squash.ExcludeGrants == nil {
+			Config.Db.Squash.ExcludeGrants = append([]string{}, DefaultExcludedGrants...)
+		}
+		for _, pattern := range Config.Db.Squash.ExcludeGrants {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return errors.Errorf("Invalid config for db.squash.exclude_grants: %w", err)
+			}
+		}
+		for _, pattern := range Config.Db.Squash.MaskSecrets {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return errors.Errorf("Invalid config for db.squash.mask_secrets: %w", err)
+			}
+		}
+		if len(Config.Db.Squash.ManagedSchemas) == 0 {
+			Config.Db.Squash.ManagedSchemas = append([]string{}, DefaultManagedSchemas...)
+		}
+		seen := map[string]bool{}
+		for _, schema := range Config.Db.Squash.ManagedSchemas {
+			if len(strings.TrimSpace(schema)) == 0 || seen[schema] {
+				return errors.New("Invalid config for db.squash.managed_schemas. Schema names must be unique and not empty.")
+			}
+			seen[schema] = true
+		}
+		if Config.Db.Squash.MaxFileSize == 0 {
+			Config.Db.Squash.MaxFileSize = 50 * units.MiB
+		}
 		if connString, err := afero.ReadFile(fsys, PoolerUrlPath); err == nil && len(connString) > 0 {
 			Config.Db.Pooler.ConnectionString = string(connString)
 		}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	_ "embed"
 	"testing"
 	"text/template"
@@ -155,3 +156,91 @@ func TestSigningJWT(t *testing.T) {
 		assert.Equal(t, defaultServiceRoleKey, signed)
 	})
 }
+
+func TestDiffEngineConfig(t *testing.T) {
+	t.Run("throws error on invalid engine", func(t *testing.T) {
+		fsys := afero.NewMemMapFs()
+		assert.NoError(t, WriteConfig(fsys, false))
+		contents, err := afero.ReadFile(fsys, ConfigPath)
+		assert.NoError(t, err)
+		contents = bytes.Replace(contents, []byte(`# engine = "migra"`), []byte(`engine = "unknown"`), 1)
+		assert.NoError(t, afero.WriteFile(fsys, ConfigPath, contents, 0644))
+		// Run test
+		err = LoadConfigFS(fsys)
+		// Check error
+		assert.ErrorContains(t, err, "Invalid config for db.diff.engine")
+		Config.Db.Diff.Engine = ""
+	})
+}
+
+func TestSquashConfig(t *testing.T) {
+	t.Run("throws error on invalid grant pattern", func(t *testing.T) {
+		fsys := afero.NewMemMapFs()
+		assert.NoError(t, WriteConfig(fsys, false))
+		contents, err := afero.ReadFile(fsys, ConfigPath)
+		assert.NoError(t, err)
+		contents = bytes.Replace(contents, []byte(`# exclude_grants = ['^GRANT .+ TO "anon"']`), []byte(`exclude_grants = ['^GRANT (']`), 1)
+		assert.NoError(t, afero.WriteFile(fsys, ConfigPath, contents, 0644))
+		// Run test
+		err = LoadConfigFS(fsys)
+		// Check error
+		assert.ErrorContains(t, err, "Invalid config for db.squash.exclude_grants")
+		Config.Db.Squash.ExcludeGrants = nil
+	})
+
+	t.Run("throws error on invalid mask pattern", func(t *testing.T) {
+		fsys := afero.NewMemMapFs()
+		assert.NoError(t, WriteConfig(fsys, false))
+		contents, err := afero.ReadFile(fsys, ConfigPath)
+		assert.NoError(t, err)
+		contents = bytes.Replace(contents, []byte(`# mask_secrets = ['sk_live_[0-9a-zA-Z]+']`), []byte(`mask_secrets = ['sk_live_[']`), 1)
+		assert.NoError(t, afero.WriteFile(fsys, ConfigPath, contents, 0644))
+		// Run test
+		err = LoadConfigFS(fsys)
+		// Check error
+		assert.ErrorContains(t, err, "Invalid config for db.squash.mask_secrets")
+		Config.Db.Squash.MaskSecrets = nil
+	})
+
+	t.Run("throws error on duplicate managed schemas", func(t *testing.T) {
+		fsys := afero.NewMemMapFs()
+		assert.NoError(t, WriteConfig(fsys, false))
+		contents, err := afero.ReadFile(fsys, ConfigPath)
+		assert.NoError(t, err)
+		contents = bytes.Replace(contents, []byte(`# managed_schemas = ["auth", "storage"]`), []byte(`managed_schemas = ["storage", "storage"]`), 1)
+		assert.NoError(t, afero.WriteFile(fsys, ConfigPath, contents, 0644))
+		// Run test
+		err = LoadConfigFS(fsys)
+		// Check error
+		assert.ErrorContains(t, err, "Invalid config for db.squash.managed_schemas")
+		Config.Db.Squash.ManagedSchemas = nil
+	})
+
+	t.Run("throws error on invalid shadow container name", func(t *testing.T) {
+		fsys := afero.NewMemMapFs()
+		assert.NoError(t, WriteConfig(fsys, false))
+		contents, err := afero.ReadFile(fsys, ConfigPath)
+		assert.NoError(t, err)
+		contents = bytes.Replace(contents, []byte(`# container_name = "supabase_db_shadow"`), []byte(`container_name = "/shadow"`), 1)
+		assert.NoError(t, afero.WriteFile(fsys, ConfigPath, contents, 0644))
+		// Run test
+		err = LoadConfigFS(fsys)
+		// Check error
+		assert.ErrorContains(t, err, "Invalid config for db.shadow.container_name")
+		Config.Db.Shadow.ContainerName = ""
+	})
+
+	t.Run("throws error on empty shadow search path", func(t *testing.T) {
+		fsys := afero.NewMemMapFs()
+		assert.NoError(t, WriteConfig(fsys, false))
+		contents, err := afero.ReadFile(fsys, ConfigPath)
+		assert.NoError(t, err)
+		contents = bytes.Replace(contents, []byte(`# search_path = ["public", "extensions"]`), []byte(`search_path = ["public", " "]`), 1)
+		assert.NoError(t, afero.WriteFile(fsys, ConfigPath, contents, 0644))
+		// Run test
+		err = LoadConfigFS(fsys)
+		// Check error
+		assert.ErrorContains(t, err, "Invalid config for db.shadow.search_path")
+		Config.Db.Shadow.SearchPath = nil
+	})
+}
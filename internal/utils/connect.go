@@ -12,6 +12,7 @@ import (
 
 	"github.com/go-errors/errors"
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgconn/stmtcache"
 	"github.com/jackc/pgx/v4"
 	"github.com/spf13/viper"
 	"github.com/supabase/cli/internal/debug"
@@ -154,6 +155,23 @@ func ConnectByConfig(ctx context.Context, config pgconn.Config, options ...func(
 	return ConnectByConfigStream(ctx, config, os.Stderr, options...)
 }
 
+// Sends TCP keepalive probes at the given interval so that idle connections are not dropped by the network.
+func WithKeepAlive(interval time.Duration) func(*pgx.ConnConfig) {
+	return func(cc *pgx.ConnConfig) {
+		dialer := net.Dialer{KeepAlive: interval, Timeout: cc.ConnectTimeout}
+		cc.DialFunc = dialer.DialContext
+	}
+}
+
+// Caches statements in the given stmtcache mode, ie. ModeDescribe to avoid named prepared statements.
+func WithStatementCacheMode(mode int) func(*pgx.ConnConfig) {
+	return func(cc *pgx.ConnConfig) {
+		cc.BuildStatementCache = func(conn *pgconn.PgConn) stmtcache.Cache {
+			return stmtcache.New(conn, mode, 512)
+		}
+	}
+}
+
 func IsLocalDatabase(config pgconn.Config) bool {
 	return config.Host == Config.Hostname && config.Port == uint16(Config.Db.Port)
 }
@@ -5,9 +5,12 @@ import (
 	"net"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/go-errors/errors"
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgconn/stmtcache"
+	"github.com/jackc/pgx/v4"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -175,3 +178,25 @@ func TestPostgresURL(t *testing.T) {
 	})
 	assert.Equal(t, `postgresql://postgres:%21%40%23$%25%5E&%2A%28%29@[2406:da18:4fd:9b0d:80ec:9812:3e65:450b]:5432/?connect_timeout=10&options=test`, url)
 }
+
+func TestConnectOptions(t *testing.T) {
+	t.Run("sets keepalive dialer", func(t *testing.T) {
+		config, err := pgx.ParseConfig("postgres://postgres@127.0.0.1:5432/postgres")
+		require.NoError(t, err)
+		config.DialFunc = nil
+		// Run test
+		WithKeepAlive(time.Second)(config)
+		// Check output
+		assert.NotNil(t, config.DialFunc)
+	})
+
+	t.Run("builds describe statement cache", func(t *testing.T) {
+		config, err := pgx.ParseConfig("postgres://postgres@127.0.0.1:5432/postgres")
+		require.NoError(t, err)
+		// Run test
+		WithStatementCacheMode(stmtcache.ModeDescribe)(config)
+		// Check output
+		cache := config.BuildStatementCache(nil)
+		assert.Equal(t, stmtcache.ModeDescribe, cache.Mode())
+	})
+}
@@ -248,7 +248,31 @@ func DockerPullImageIfNotCached(ctx context.Context, imageName string) error {
 	} else if !client.IsErrNotFound(err) {
 		return errors.Errorf("failed to inspect docker image: %w", err)
 	}
-	return DockerImagePullWithRetry(ctx, imageUrl, 2)
+	if err := DockerImagePullWithRetry(ctx, imageUrl, 2); err != nil {
+		if suggestion := suggestPullFailure(err, imageUrl); len(suggestion) > 0 {
+			CmdSuggestion = suggestion
+		}
+		return err
+	}
+	return nil
+}
+
+var (
+	proxyErrorPattern = regexp.MustCompile(`(?i)proxyconnect|proxy authentication required|\b407\b`)
+	authErrorPattern  = regexp.MustCompile(`(?i)unauthorized|authentication required|access denied|denied:`)
+)
+
+// Images are pulled by the Docker daemon, so HTTP_PROXY, HTTPS_PROXY and NO_PROXY must be
+// configured for the daemon rather than only in the shell running the CLI.
+func suggestPullFailure(err error, imageUrl string) string {
+	msg := err.Error()
+	if proxyErrorPattern.MatchString(msg) {
+		return fmt.Sprintf("Failed to pull %s through the proxy. Configure the Docker daemon proxy: %s\nOr pre-pull the image with %s", Bold(imageUrl), Bold("https://docs.docker.com/engine/daemon/proxy/"), Aqua("docker pull "+imageUrl))
+	}
+	if authErrorPattern.MatchString(msg) {
+		return fmt.Sprintf("Registry denied pulling %s. Try running %s first.", Bold(imageUrl), Aqua("docker login"))
+	}
+	return ""
 }
 
 var suggestDockerInstall = "Docker Desktop is a prerequisite for local development. Follow the official docs to install: https://docs.docker.com/desktop"
@@ -368,6 +392,35 @@ func DockerRunOnceWithConfig(ctx context.Context, config container.Config, hostC
 	return DockerStreamLogs(ctx, container, stdout, stderr)
 }
 
+// Runs a container image exactly once like DockerRunOnceWithConfig, streaming stdin to the container
+// so that inputs need not be bind mounted from the host.
+func DockerRunOnceWithStdin(ctx context.Context, config container.Config, hostConfig container.HostConfig, networkingConfig network.NetworkingConfig, containerName string, stdin io.Reader, stdout, stderr io.Writer) error {
+	config.AttachStdin = true
+	config.OpenStdin = true
+	config.StdinOnce = true
+	containerId, err := DockerStart(ctx, config, hostConfig, networkingConfig, containerName)
+	if err != nil {
+		return err
+	}
+	defer DockerRemove(containerId)
+	resp, err := Docker.ContainerAttach(ctx, containerId, container.AttachOptions{
+		Stream: true,
+		Stdin:  true,
+	})
+	if err != nil {
+		return errors.Errorf("failed to attach docker container: %w", err)
+	}
+	defer resp.Close()
+	if _, err := io.Copy(resp.Conn, stdin); err != nil {
+		return errors.Errorf("failed to write docker stdin: %w", err)
+	}
+	// Closing our end signals EOF to the container process
+	if err := resp.CloseWrite(); err != nil {
+		return errors.Errorf("failed to close docker stdin: %w", err)
+	}
+	return DockerStreamLogs(ctx, containerId, stdout, stderr)
+}
+
 func DockerStreamLogs(ctx context.Context, containerId string, stdout, stderr io.Writer) error {
 	// Stream logs
 	logs, err := Docker.ContainerLogs(ctx, containerId, container.LogsOptions{
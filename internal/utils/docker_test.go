@@ -11,6 +11,7 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/go-errors/errors"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -103,6 +104,45 @@ func TestPullImage(t *testing.T) {
 		assert.ErrorContains(t, err, "no space left on device")
 		assert.Empty(t, apitest.ListUnmatchedRequests())
 	})
+
+	t.Run("suggests daemon proxy on proxy failure", func(t *testing.T) {
+		timeUnit = time.Duration(0)
+		CmdSuggestion = ""
+		defer func() { CmdSuggestion = "" }()
+		// Setup mock docker
+		require.NoError(t, apitest.MockDocker(Docker))
+		defer gock.OffAll()
+		gock.New(Docker.DaemonHost()).
+			Get("/v" + Docker.ClientVersion() + "/images/" + imageId + "/json").
+			Reply(http.StatusNotFound)
+		gock.New(Docker.DaemonHost()).
+			Post("/v" + Docker.ClientVersion() + "/images/create").
+			Times(3).
+			Reply(http.StatusInternalServerError).
+			JSON(map[string]string{"message": "Get \"https://registry-1.docker.io/v2/\": proxyconnect tcp: dial tcp 10.0.0.1:3128: i/o timeout"})
+		// Run test
+		err := DockerPullImageIfNotCached(context.Background(), imageId)
+		// Check error
+		assert.ErrorContains(t, err, "proxyconnect")
+		assert.Contains(t, CmdSuggestion, "Docker daemon proxy")
+		assert.Empty(t, apitest.ListUnmatchedRequests())
+	})
+}
+
+func TestSuggestPullFailure(t *testing.T) {
+	t.Run("suggests login on denied pull", func(t *testing.T) {
+		err := errors.New("pull access denied for supabase/postgres, repository does not exist or may require 'docker login': denied: requested access to the resource is denied")
+		assert.Contains(t, suggestPullFailure(err, imageId), "docker login")
+	})
+
+	t.Run("prefers proxy over auth suggestion", func(t *testing.T) {
+		err := errors.New("received unexpected HTTP status: 407 Proxy Authentication Required")
+		assert.Contains(t, suggestPullFailure(err, imageId), "proxy")
+	})
+
+	t.Run("skips unrelated errors", func(t *testing.T) {
+		assert.Empty(t, suggestPullFailure(errors.New("no space left on device"), imageId))
+	})
 }
 
 func TestRunOnce(t *testing.T) {
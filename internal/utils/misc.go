@@ -98,13 +98,14 @@ var (
 	//go:embed templates/globals.sql
 	GlobalsSql string
 
-	ProjectRefPattern  = regexp.MustCompile(`^[a-z]{20}$`)
-	UUIDPattern        = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
-	ProjectHostPattern = regexp.MustCompile(`^(db\.)([a-z]{20})\.supabase\.(co|red)$`)
-	MigrateFilePattern = regexp.MustCompile(`^([0-9]+)_(.*)\.sql$`)
-	BranchNamePattern  = regexp.MustCompile(`[[:word:]-]+`)
-	FuncSlugPattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
-	ImageNamePattern   = regexp.MustCompile(`\/(.*):`)
+	ProjectRefPattern    = regexp.MustCompile(`^[a-z]{20}$`)
+	UUIDPattern          = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	ProjectHostPattern   = regexp.MustCompile(`^(db\.)([a-z]{20})\.supabase\.(co|red)$`)
+	MigrateFilePattern   = regexp.MustCompile(`^([0-9]+)_(.*)\.sql$`)
+	BranchNamePattern    = regexp.MustCompile(`[[:word:]-]+`)
+	FuncSlugPattern      = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+	ImageNamePattern     = regexp.MustCompile(`\/(.*):`)
+	ContainerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
 
 	// These schemas are ignored from db diff and db dump
 	PgSchemas = []string{
@@ -168,25 +169,27 @@ var (
 		"track_io_timing",
 	}
 
-	SupabaseDirPath       = "supabase"
-	ConfigPath            = filepath.Join(SupabaseDirPath, "config.toml")
-	GitIgnorePath         = filepath.Join(SupabaseDirPath, ".gitignore")
-	TempDir               = filepath.Join(SupabaseDirPath, ".temp")
-	ImportMapsDir         = filepath.Join(TempDir, "import_maps")
-	ProjectRefPath        = filepath.Join(TempDir, "project-ref")
-	PoolerUrlPath         = filepath.Join(TempDir, "pooler-url")
-	PostgresVersionPath   = filepath.Join(TempDir, "postgres-version")
-	GotrueVersionPath     = filepath.Join(TempDir, "gotrue-version")
-	RestVersionPath       = filepath.Join(TempDir, "rest-version")
-	StorageVersionPath    = filepath.Join(TempDir, "storage-version")
-	CurrBranchPath        = filepath.Join(SupabaseDirPath, ".branches", "_current_branch")
-	MigrationsDir         = filepath.Join(SupabaseDirPath, "migrations")
-	FunctionsDir          = filepath.Join(SupabaseDirPath, "functions")
-	FallbackImportMapPath = filepath.Join(FunctionsDir, "import_map.json")
-	FallbackEnvFilePath   = filepath.Join(FunctionsDir, ".env")
-	DbTestsDir            = filepath.Join(SupabaseDirPath, "tests")
-	SeedDataPath          = filepath.Join(SupabaseDirPath, "seed.sql")
-	CustomRolesPath       = filepath.Join(SupabaseDirPath, "roles.sql")
+	SupabaseDirPath         = "supabase"
+	ConfigPath              = filepath.Join(SupabaseDirPath, "config.toml")
+	GitIgnorePath           = filepath.Join(SupabaseDirPath, ".gitignore")
+	TempDir                 = filepath.Join(SupabaseDirPath, ".temp")
+	ImportMapsDir           = filepath.Join(TempDir, "import_maps")
+	ProjectRefPath          = filepath.Join(TempDir, "project-ref")
+	PoolerUrlPath           = filepath.Join(TempDir, "pooler-url")
+	PostgresVersionPath     = filepath.Join(TempDir, "postgres-version")
+	GotrueVersionPath       = filepath.Join(TempDir, "gotrue-version")
+	RestVersionPath         = filepath.Join(TempDir, "rest-version")
+	StorageVersionPath      = filepath.Join(TempDir, "storage-version")
+	CurrBranchPath          = filepath.Join(SupabaseDirPath, ".branches", "_current_branch")
+	MigrationsDir           = filepath.Join(SupabaseDirPath, "migrations")
+	MigrationsManifestPath  = filepath.Join(SupabaseDirPath, "migrations.yaml")
+	MigrationAssertionsPath = filepath.Join(MigrationsDir, "assertions.sql")
+	FunctionsDir            = filepath.Join(SupabaseDirPath, "functions")
+	FallbackImportMapPath   = filepath.Join(FunctionsDir, "import_map.json")
+	FallbackEnvFilePath     = filepath.Join(FunctionsDir, ".env")
+	DbTestsDir              = filepath.Join(SupabaseDirPath, "tests")
+	SeedDataPath            = filepath.Join(SupabaseDirPath, "seed.sql")
+	CustomRolesPath         = filepath.Join(SupabaseDirPath, "roles.sql")
 
 	ErrNotLinked   = errors.Errorf("Cannot find project ref. Have you run %s?", Aqua("supabase link"))
 	ErrInvalidRef  = errors.New("Invalid project ref format. Must be like `abcdefghijklmnopqrst`.")
@@ -12,6 +12,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/go-errors/errors"
+	"github.com/spf13/viper"
 	"golang.org/x/term"
 )
 
@@ -180,6 +181,22 @@ func PromptYesNo(label string, def bool, stdin *os.File) bool {
 	}
 }
 
+var ErrNotTerminal = errors.New("cannot confirm without a terminal: pass --yes to proceed")
+
+// PromptDestructive confirms an operation that cannot be undone, such as rewriting remote history.
+// Without a terminal, it returns an error unless --yes is passed, so that false always means the
+// user declined. The interactive answer defaults to no unless SUPABASE_DESTRUCTIVE_DEFAULT is set.
+func PromptDestructive(label string, stdin *os.File) (bool, error) {
+	if viper.GetBool("YES") {
+		fmt.Fprintln(os.Stderr, label, "[y/N] y")
+		return true, nil
+	}
+	if !term.IsTerminal(int(stdin.Fd())) {
+		return false, errors.Errorf("%s %w", label, ErrNotTerminal)
+	}
+	return PromptYesNo(label, viper.GetBool("DESTRUCTIVE_DEFAULT"), stdin), nil
+}
+
 func PromptText(label string, stdin io.Reader) (string, error) {
 	fmt.Fprint(os.Stderr, label)
 	scanner := bufio.NewScanner(stdin)
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromptDestructive(t *testing.T) {
+	t.Run("throws error without terminal", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		assert.NoError(t, err)
+		defer r.Close()
+		_, err = w.WriteString("y\n")
+		assert.NoError(t, err)
+		w.Close()
+		// Run test
+		ok, err := PromptDestructive("Delete everything?", r)
+		// Check error
+		assert.ErrorIs(t, err, ErrNotTerminal)
+		assert.False(t, ok)
+	})
+
+	t.Run("ignores configured default without terminal", func(t *testing.T) {
+		viper.Set("DESTRUCTIVE_DEFAULT", true)
+		defer viper.Set("DESTRUCTIVE_DEFAULT", false)
+		r, w, err := os.Pipe()
+		assert.NoError(t, err)
+		defer r.Close()
+		w.Close()
+		// Run test
+		ok, err := PromptDestructive("Delete everything?", r)
+		// Check error
+		assert.ErrorIs(t, err, ErrNotTerminal)
+		assert.False(t, ok)
+	})
+
+	t.Run("confirms with yes flag", func(t *testing.T) {
+		viper.Set("YES", true)
+		defer viper.Set("YES", false)
+		// Run test
+		ok, err := PromptDestructive("Delete everything?", os.Stdin)
+		// Check error
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+}
+
+func TestPromptYesNo(t *testing.T) {
+	t.Run("ignores yes flag", func(t *testing.T) {
+		viper.Set("YES", true)
+		defer viper.Set("YES", false)
+		r, w, err := os.Pipe()
+		assert.NoError(t, err)
+		defer r.Close()
+		w.Close()
+		// Run test
+		ok := PromptYesNo("Write anyway?", false, r)
+		// Check output
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,99 @@
+package s3fs
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/go-errors/errors"
+	"github.com/spf13/afero"
+)
+
+// Subset of the S3 API required to download objects.
+type Client interface {
+	s3.ListObjectsV2APIClient
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// Loads credentials and region from the standard AWS environment and shared config. GCS and
+// other S3 compatible stores are supported by setting AWS_ENDPOINT_URL.
+func NewClientFromEnv(ctx context.Context) (*s3.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, errors.Errorf("failed to load aws config: %w", err)
+	}
+	if len(cfg.Region) == 0 {
+		cfg.Region = "us-east-1"
+	}
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		// Custom endpoints rarely support virtual hosted buckets
+		o.UsePathStyle = len(os.Getenv("AWS_ENDPOINT_URL")) > 0
+	}), nil
+}
+
+// Downloads all objects under s3://bucket/prefix into a read-only in-memory fs rooted at dir.
+func Load(ctx context.Context, client Client, rawUrl, dir string) (afero.Fs, error) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, errors.Errorf("failed to parse storage url: %w", err)
+	}
+	if parsed.Scheme != "s3" || len(parsed.Host) == 0 {
+		return nil, errors.Errorf("invalid storage url: %s (must be s3://bucket/prefix)", rawUrl)
+	}
+	bucket := parsed.Host
+	prefix := strings.TrimPrefix(parsed.Path, "/")
+	if len(prefix) > 0 && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	fsys := afero.NewMemMapFs()
+	if err := fsys.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Errorf("failed to create directory: %w", err)
+	}
+	pages := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Errorf("failed to list objects: %w", err)
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			name := strings.TrimPrefix(key, prefix)
+			// Only top level objects are treated as migration files
+			if len(name) == 0 || strings.Contains(name, "/") {
+				continue
+			}
+			if err := download(ctx, client, bucket, key, filepath.Join(dir, name), fsys); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return afero.NewReadOnlyFs(fsys), nil
+}
+
+func download(ctx context.Context, client Client, bucket, key, path string, fsys afero.Fs) error {
+	resp, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return errors.Errorf("failed to get object %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Errorf("failed to read object %s: %w", key, err)
+	}
+	if err := afero.WriteFile(fsys, path, body, 0644); err != nil {
+		return errors.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
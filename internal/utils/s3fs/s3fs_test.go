@@ -0,0 +1,108 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockClient struct {
+	pages   []*s3.ListObjectsV2Output
+	objects map[string]string
+	err     error
+	listed  []*s3.ListObjectsV2Input
+}
+
+func (m *mockClient) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.listed = append(m.listed, params)
+	return m.pages[len(m.listed)-1], nil
+}
+
+func (m *mockClient) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	body, ok := m.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)]
+	if !ok {
+		return nil, errors.New("NoSuchKey")
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func listPage(token string, keys ...string) *s3.ListObjectsV2Output {
+	page := &s3.ListObjectsV2Output{}
+	for _, key := range keys {
+		page.Contents = append(page.Contents, types.Object{Key: aws.String(key)})
+	}
+	if len(token) > 0 {
+		page.IsTruncated = aws.Bool(true)
+		page.NextContinuationToken = aws.String(token)
+	}
+	return page
+}
+
+func TestLoadMigrations(t *testing.T) {
+	dir := filepath.Join("supabase", "migrations")
+
+	t.Run("downloads objects under prefix", func(t *testing.T) {
+		client := &mockClient{
+			pages: []*s3.ListObjectsV2Output{
+				listPage("next", "repo/migrations/0_init.sql", "repo/migrations/archive/1_old.sql"),
+				listPage("", "repo/migrations/1_test.sql"),
+			},
+			objects: map[string]string{
+				"shared/repo/migrations/0_init.sql": "create schema a",
+				"shared/repo/migrations/1_test.sql": "create schema b",
+			},
+		}
+		// Run test
+		fsys, err := Load(context.Background(), client, "s3://shared/repo/migrations", dir)
+		// Check error
+		assert.NoError(t, err)
+		assert.Len(t, client.listed, 2)
+		assert.Equal(t, "repo/migrations/", aws.ToString(client.listed[0].Prefix))
+		assert.Equal(t, "next", aws.ToString(client.listed[1].ContinuationToken))
+		contents, err := afero.ReadFile(fsys, filepath.Join(dir, "1_test.sql"))
+		assert.NoError(t, err)
+		assert.Equal(t, "create schema b", string(contents))
+		exists, err := afero.Exists(fsys, filepath.Join(dir, "1_old.sql"))
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		// Check read only
+		err = afero.WriteFile(fsys, filepath.Join(dir, "2_new.sql"), []byte{}, 0644)
+		assert.ErrorIs(t, err, os.ErrPermission)
+	})
+
+	t.Run("throws error on invalid url", func(t *testing.T) {
+		_, err := Load(context.Background(), &mockClient{}, "gs://shared", dir)
+		// Check error
+		assert.ErrorContains(t, err, "invalid storage url: gs://shared")
+	})
+
+	t.Run("throws error on access denied", func(t *testing.T) {
+		errDenied := errors.New("AccessDenied")
+		// Run test
+		_, err := Load(context.Background(), &mockClient{err: errDenied}, "s3://shared", dir)
+		// Check error
+		assert.ErrorIs(t, err, errDenied)
+		assert.ErrorContains(t, err, "failed to list objects:")
+	})
+
+	t.Run("throws error on missing object", func(t *testing.T) {
+		client := &mockClient{pages: []*s3.ListObjectsV2Output{listPage("", "0_init.sql")}}
+		// Run test
+		_, err := Load(context.Background(), client, "s3://shared", dir)
+		// Check error
+		assert.ErrorContains(t, err, "failed to get object 0_init.sql: NoSuchKey")
+	})
+}